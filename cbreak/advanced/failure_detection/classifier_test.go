@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/failure"
+)
+
+func TestCustomErrorClassification(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{CustomError{Code: 400, Message: "Bad Request"}, false},
+		{CustomError{Code: 404, Message: "Not Found"}, false},
+		{CustomError{Code: 422, Message: "Unprocessable Entity"}, false},
+		{CustomError{Code: 500, Message: "Internal Server Error"}, true},
+		{CustomError{Code: 503, Message: "Service Unavailable"}, true},
+		{&CustomError{Code: 502, Message: "Bad Gateway"}, true},
+		{&CustomError{Code: 409, Message: "Conflict"}, false},
+		{fmt.Errorf("calling upstream: %w", CustomError{Code: 504, Message: "Gateway Timeout"}), true},
+		{fmt.Errorf("calling upstream: %w", CustomError{Code: 401, Message: "Unauthorized"}), false},
+	}
+	for _, tt := range tests {
+		if code, ok := failure.StatusCode(tt.err); !ok {
+			t.Errorf("StatusCode(%v) found no code", tt.err)
+		} else if got := failure.ServerErrors(tt.err); got != tt.want {
+			t.Errorf("ServerErrors(%v) with code %d = %t, want %t", tt.err, code, got, tt.want)
+		}
+	}
+}
+
+func TestOnlyServerErrorsOpenBreaker(t *testing.T) {
+	drive := func(codes ...int) cbreak.State {
+		config := cbreak.DefaultConfig("classifier-test")
+		config.FailureThreshold = 3
+		config.Timeout = time.Hour
+		config.ErrorClassifier = failure.ServerErrors
+		breaker, err := cbreak.NewBreaker[string](config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer breaker.Shutdown()
+		for _, code := range codes {
+			_, _ = breaker.Execute(context.Background(), func() (string, error) {
+				return "", CustomError{Code: code, Message: http.StatusText(code)}
+			})
+		}
+		return breaker.GetState()
+	}
+
+	if state := drive(400, 404, 409, 422, 400, 404); state != cbreak.Closed {
+		t.Errorf("breaker after 4xx CustomErrors is %s, want closed", state)
+	}
+	if state := drive(400, 500, 404, 503, 502); state != cbreak.Open {
+		t.Errorf("breaker after three 5xx CustomErrors is %s, want open", state)
+	}
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
-	"errors"
+	"os"
 	"time"
 
 	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/failure"
 	"github.com/gozephyr/examples/pkg/logger"
 )
 
@@ -19,32 +21,36 @@ func (e CustomError) Error() string {
 	return e.Message
 }
 
-// IsTemporaryError determines if an error is temporary based on its code
-func IsTemporaryError(err error) bool {
-	var customErr CustomError
-	if errors.As(err, &customErr) {
-		// Consider 5xx errors as temporary
-		return customErr.Code >= 500 && customErr.Code < 600
-	}
-	return false
+// StatusCode exposes the error code to failure.ServerErrors
+func (e CustomError) StatusCode() int {
+	return e.Code
 }
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("cbreak-advanced ")
 	log.Section("Advanced Circuit Breaker Example")
-	customFailureExample(log)
+	customFailureExample(log, clock.FromEnv())
+	return true
 }
 
-func customFailureExample(log *logger.Logger) {
+func customFailureExample(log *logger.Logger, clk clock.Clock) {
 	// Create a circuit breaker with custom failure detection
 	config := cbreak.DefaultConfig("custom-failure-example")
 	config.FailureThreshold = 3
 	config.Timeout = 5 * time.Second
 	config.CommandTimeout = 2 * time.Second
 	config.HalfOpenMaxRequests = 1
+	// Only 5xx errors count as failures; 4xx errors pass through to the caller
+	config.ErrorClassifier = failure.ServerErrors
 
-	breaker, err := cbreak.NewBreaker[string](config)
+	breaker, err := clock.NewBreaker[string](clk, config)
 	if err != nil {
 		log.Error("Error creating circuit breaker: %v", err)
 		return
@@ -53,11 +59,13 @@ func customFailureExample(log *logger.Logger) {
 
 	// Simulate various error types
 	errors := []error{
+		CustomError{Code: 400, Message: "Bad Request"},
+		CustomError{Code: 404, Message: "Not Found"},
 		CustomError{Code: 500, Message: "Internal Server Error"},
+		CustomError{Code: 422, Message: "Unprocessable Entity"},
 		CustomError{Code: 503, Message: "Service Unavailable"},
-		CustomError{Code: 400, Message: "Bad Request"},
+		CustomError{Code: 409, Message: "Conflict"},
 		CustomError{Code: 502, Message: "Bad Gateway"},
-		CustomError{Code: 404, Message: "Not Found"},
 	}
 
 	log.Info("Simulating operations with different error types...")
@@ -67,7 +75,8 @@ func customFailureExample(log *logger.Logger) {
 		})
 
 		if execErr != nil {
-			if IsTemporaryError(err) {
+			// Log with the classifier the breaker counts failures with
+			if failure.ServerErrors(err) {
 				log.Warn("Operation %d failed with temporary error: %v", i+1, err)
 			} else {
 				log.Error("Operation %d failed with permanent error: %v", i+1, err)
@@ -81,9 +90,13 @@ func customFailureExample(log *logger.Logger) {
 		log.Info("Circuit breaker state: %s", state)
 	}
 
+	// Permanent errors were returned to the caller but never counted as failures
+	metrics := breaker.GetMetrics()
+	log.Info("Failures counted by the breaker: %d of %d calls", metrics.FailedCalls, metrics.TotalRequests)
+
 	// Wait for the circuit breaker to reset
 	log.Info("Waiting for circuit breaker to reset...")
-	time.Sleep(6 * time.Second)
+	clk.Sleep(6 * time.Second)
 
 	// Try a successful operation
 	log.Info("Trying a successful operation...")
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/cbregistry"
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/logger"
+)
+
+const (
+	endpointCount = 300
+	callsPerKey   = 5
+	workers       = 8
+)
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("cbreak-registry ")
+	log.Section("Per-Key Breaker Registry Example")
+	registryExample(log, clock.FromEnv())
+	return true
+}
+
+// endpointName returns the key used for the i-th simulated endpoint
+func endpointName(i int) string {
+	return fmt.Sprintf("endpoint-%03d", i)
+}
+
+// isUnhealthy reports whether the simulated endpoint always fails
+func isUnhealthy(i int) bool {
+	return i%10 == 0
+}
+
+// countStates tallies breakers by state
+func countStates(states map[string]cbreak.State) map[cbreak.State]int {
+	counts := make(map[cbreak.State]int)
+	for _, state := range states {
+		counts[state]++
+	}
+	return counts
+}
+
+func registryExample(log *logger.Logger, clk clock.Clock) {
+	// Every breaker is created from this template
+	template := cbreak.DefaultConfig("endpoint")
+	template.FailureThreshold = 3
+	template.Timeout = 5 * time.Second
+	template.CommandTimeout = time.Second
+
+	var evictions atomic.Int64
+	registry, err := cbregistry.New[string](template,
+		cbregistry.WithClock(clk),
+		cbregistry.WithIdleTimeout(200*time.Millisecond),
+		cbregistry.WithSweepInterval(50*time.Millisecond),
+		cbregistry.WithOnEvict(func(key string) {
+			evictions.Add(1)
+		}),
+	)
+	if err != nil {
+		log.Error("Error creating registry: %v", err)
+		return
+	}
+	defer registry.Close()
+
+	log.Info("Registry starts empty: %d breakers", registry.Len())
+
+	// Drive every endpoint from a pool of workers; breakers are created on first use
+	log.Info("Calling %d endpoints %d times each from %d workers...", endpointCount, callsPerKey, workers)
+	var (
+		wg       sync.WaitGroup
+		rejected atomic.Int64
+		jobs     = make(chan int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				for call := 0; call < callsPerKey; call++ {
+					_, err := registry.Execute(context.Background(), endpointName(i), func() (string, error) {
+						if isUnhealthy(i) {
+							return "", errors.New("endpoint unavailable")
+						}
+						return "ok", nil
+					})
+					if errors.Is(err, cbreak.ErrCircuitOpen) {
+						rejected.Add(1)
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < endpointCount; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	counts := countStates(registry.GetState())
+	log.Success("Registry holds %d breakers: %d closed, %d open", registry.Len(), counts[cbreak.Closed], counts[cbreak.Open])
+	log.Info("Calls rejected by open breakers: %d", rejected.Load())
+
+	// Leave everything idle long enough for the background sweep to run
+	log.Info("Leaving all endpoints idle...")
+	clk.Sleep(400 * time.Millisecond)
+
+	// Sweep once more so the result does not depend on when the ticker last fired
+	registry.Sweep()
+	counts = countStates(registry.GetState())
+	log.Success("Evicted %d idle breakers", evictions.Load())
+	log.Info("Registry holds %d breakers: %d closed, %d open (open breakers are kept for now)",
+		registry.Len(), counts[cbreak.Closed], counts[cbreak.Open])
+
+	// A breaker only closes again when called, so one left unused past its
+	// timeout as well is evicted whatever its state
+	log.Info("Leaving the failing endpoints idle past the breaker timeout...")
+	clk.Sleep(template.Timeout)
+	registry.Sweep()
+	log.Success("Evicted %d idle breakers in total, registry holds %d", evictions.Load(), registry.Len())
+
+	// Breakers are recreated on demand after eviction
+	_, err = registry.Execute(context.Background(), endpointName(1), func() (string, error) {
+		return "ok", nil
+	})
+	if err != nil {
+		log.Error("Call to %s failed: %v", endpointName(1), err)
+	} else {
+		log.Success("Call to %s recreated its breaker, registry holds %d breakers", endpointName(1), registry.Len())
+	}
+}
@@ -3,20 +3,29 @@ package main
 import (
 	"context"
 	"errors"
+	"os"
 	"time"
 
 	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
 	"github.com/gozephyr/examples/pkg/logger"
 )
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("cbreak-simple ")
 	log.Section("Simple Circuit Breaker Example")
-	simpleExample(log)
+	simpleExample(log, clock.FromEnv())
+	return true
 }
 
-func simpleExample(log *logger.Logger) {
+func simpleExample(log *logger.Logger, clk clock.Clock) {
 	// Create a circuit breaker with basic configuration
 	config := cbreak.DefaultConfig("simple-example")
 	config.FailureThreshold = 3
@@ -24,7 +33,7 @@ func simpleExample(log *logger.Logger) {
 	config.CommandTimeout = 2 * time.Second
 	config.HalfOpenMaxRequests = 1
 
-	breaker, err := cbreak.NewBreaker[string](config)
+	breaker, err := clock.NewBreaker[string](clk, config)
 	if err != nil {
 		log.Error("Error creating circuit breaker: %v", err)
 		return
@@ -52,7 +61,7 @@ func simpleExample(log *logger.Logger) {
 
 	// Wait for the circuit breaker to reset
 	log.Info("Waiting for circuit breaker to reset...")
-	time.Sleep(6 * time.Second)
+	clk.Sleep(6 * time.Second)
 
 	// Try a successful operation
 	log.Info("Trying a successful operation...")
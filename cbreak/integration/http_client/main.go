@@ -2,64 +2,134 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	neturl "net/url"
+	"os"
 	"time"
 
 	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
 	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/upstream"
 )
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("cbreak-http ")
 	log.Section("HTTP Client Integration Example")
-	httpClientExample(log)
+	httpClientExample(log, clock.FromEnv())
+	return true
+}
+
+// newUpstream starts a fake upstream scripted with the failure modes used by the example
+func newUpstream() *upstream.Server {
+	server := upstream.New()
+	server.Script("/error", upstream.Fail(http.StatusInternalServerError))
+	server.Script("/timeout", upstream.Hang(3*time.Second))
+	// The first request is turned away with a 503, after which the service recovers
+	server.Script("/unavailable", upstream.Unavailable(5*time.Second), upstream.OK("recovered"))
+	server.Script("/health", upstream.OK("healthy"))
+	return server
+}
+
+// fetch performs a GET request and maps 5xx responses to errors
+func fetch(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Report the failure without the upstream address, which changes every run
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			return "", urlErr.Err
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode >= 500 {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			return "", fmt.Errorf("server error: %d (retry after %ss)", resp.StatusCode, retryAfter)
+		}
+		return "", fmt.Errorf("server error: %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("Status: %d, Body: %s", resp.StatusCode, body), nil
 }
 
-func httpClientExample(log *logger.Logger) {
+func httpClientExample(log *logger.Logger, clk clock.Clock) {
+	// Start the fake upstream serving the endpoints used below
+	server := newUpstream()
+	defer server.Close()
+
 	// Create a circuit breaker for HTTP client
 	config := cbreak.DefaultConfig("http-client-example")
-	config.FailureThreshold = 3
+	// cbreak counts a CommandTimeout in its metrics but not toward
+	// FailureThreshold, so the hanging endpoint does not add to the two
+	// failures that open the circuit
+	config.FailureThreshold = 2
+	config.SuccessThreshold = 2
 	config.Timeout = 5 * time.Second
 	config.CommandTimeout = 2 * time.Second
 	config.HalfOpenMaxRequests = 1
+	breakerLog := log.With("breaker", config.Name)
+	config.OnStateChange = func(from, to cbreak.State, reason string) {
+		breakerLog.With("from", from, "to", to).Warn("Circuit breaker transition (%s)", reason)
+	}
 
-	breaker, err := cbreak.NewBreaker[string](config)
+	breaker, err := clock.NewBreaker[string](clk, config)
 	if err != nil {
 		log.Error("Error creating circuit breaker: %v", err)
 		return
 	}
 	defer breaker.Shutdown()
 
-	// Create an HTTP client
+	// Create an HTTP client. Its own timeout is longer than CommandTimeout,
+	// so the breaker is the one that gives up on the hanging endpoint.
 	client := &http.Client{
-		Timeout: 2 * time.Second,
+		Timeout: 5 * time.Second,
+	}
+
+	// execute runs one request through the breaker, cancelling the request
+	// once the breaker has returned, whether it finished or timed out
+	execute := func(path string) (string, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		return breaker.Execute(ctx, func() (string, error) {
+			return fetch(ctx, client, server.URL(path))
+		})
 	}
 
 	// Simulate HTTP requests to failing endpoints
 	endpoints := []string{
-		"http://localhost:8080/error",
-		"http://localhost:8080/timeout",
-		"http://localhost:8080/unavailable",
+		"/error",
+		"/timeout",
+		"/unavailable",
 	}
 
 	log.Info("Simulating HTTP requests to failing endpoints...")
 	for _, endpoint := range endpoints {
-		result, err := breaker.Execute(context.Background(), func() (string, error) {
-			resp, err := client.Get(endpoint)
-			if err != nil {
-				return "", err
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode >= 500 {
-				return "", fmt.Errorf("server error: %d", resp.StatusCode)
-			}
-			return fmt.Sprintf("Status: %d", resp.StatusCode), nil
-		})
+		result, err := execute(endpoint)
 
-		if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.Error("Request to %s failed: %v after CommandTimeout (breaker timeouts: %d)",
+				endpoint, err, breaker.GetMetrics().TimeoutCalls)
+		} else if err != nil {
 			log.Error("Request to %s failed: %v", endpoint, err)
 		} else {
 			log.Success("Request to %s succeeded: %s", endpoint, result)
@@ -70,29 +140,31 @@ func httpClientExample(log *logger.Logger) {
 		log.Info("Circuit breaker state: %s", state)
 	}
 
+	// While open, requests are rejected without reaching the upstream
+	log.Info("Trying the healthy endpoint while the circuit is open...")
+	_, err = execute("/health")
+	if err != nil {
+		log.Error("Request to /health rejected: %v (upstream hits: %d)", err, server.Hits("/health"))
+	}
+
 	// Wait for the circuit breaker to reset
 	log.Info("Waiting for circuit breaker to reset...")
-	time.Sleep(6 * time.Second)
+	clk.Sleep(6 * time.Second)
 
-	// Try a request to a working endpoint
-	log.Info("Trying a request to a working endpoint...")
-	result, err := breaker.Execute(context.Background(), func() (string, error) {
-		resp, err := client.Get("http://localhost:8080/health")
-		if err != nil {
-			return "", err
-		}
-		defer resp.Body.Close()
+	// Probe the recovered endpoint until the circuit closes again
+	log.Info("Probing the recovered endpoint...")
+	for i := 0; i < config.SuccessThreshold; i++ {
+		result, err := execute("/unavailable")
 
-		if resp.StatusCode >= 500 {
-			return "", fmt.Errorf("server error: %d", resp.StatusCode)
+		if err != nil {
+			log.Error("Probe %d failed: %v", i+1, err)
+		} else {
+			log.Success("Probe %d succeeded: %s", i+1, result)
 		}
-		return fmt.Sprintf("Status: %d", resp.StatusCode), nil
-	})
 
-	if err != nil {
-		log.Error("Request failed: %v", err)
-	} else {
-		log.Success("Request succeeded: %s", result)
+		// Log the current state
+		state := breaker.GetState()
+		log.Info("Circuit breaker state: %s", state)
 	}
 
 	// Log the final state
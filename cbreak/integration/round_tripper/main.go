@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/cbhttp"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/upstream"
+)
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("cbreak-transport ")
+	log.Section("Circuit Breaking RoundTripper Example")
+	roundTripperExample(log)
+	return true
+}
+
+func roundTripperExample(log *logger.Logger) {
+	// Start two fake upstreams: one failing, one healthy
+	payments := upstream.New()
+	defer payments.Close()
+	payments.Script("/charge", upstream.Fail(http.StatusInternalServerError))
+
+	catalog := upstream.New()
+	defer catalog.Close()
+	catalog.Script("/items", upstream.OK("[]"))
+
+	// Name each upstream by its host so the log reads nicely
+	names := map[string]string{
+		hostOf(payments.URL("/")): "payments",
+		hostOf(catalog.URL("/")):  "catalog",
+	}
+
+	// Any http.Client gains breaker protection by swapping its Transport
+	transport := cbhttp.NewTransport(
+		cbhttp.WithConfig(func(host string) *cbreak.Config {
+			config := cbreak.DefaultConfig("http-" + names[host])
+			config.FailureThreshold = 3
+			config.Timeout = 5 * time.Second
+			config.CommandTimeout = 2 * time.Second
+			return config
+		}),
+	)
+	defer transport.Close()
+	client := &http.Client{Transport: transport}
+
+	requests := []struct {
+		service string
+		url     string
+	}{
+		{"payments", payments.URL("/charge")},
+		{"catalog", catalog.URL("/items")},
+		{"payments", payments.URL("/charge")},
+		{"payments", payments.URL("/charge")},
+		{"catalog", catalog.URL("/items")},
+		{"payments", payments.URL("/charge")},
+		{"payments", payments.URL("/charge")},
+	}
+
+	rejected := 0
+	log.Info("Sending requests through the circuit breaking transport...")
+	for i, r := range requests {
+		resp, err := client.Get(r.url)
+
+		var openErr *cbhttp.CircuitOpenError
+		var statusErr *cbhttp.StatusError
+		switch {
+		case errors.As(err, &openErr):
+			rejected++
+			log.Warn("Request %d to %s rejected without reaching the upstream", i+1, r.service)
+		case errors.As(err, &statusErr):
+			log.Error("Request %d to %s failed with status %d", i+1, r.service, statusErr.StatusCode)
+		case err != nil:
+			log.Error("Request %d to %s failed: %v", i+1, r.service, err)
+		default:
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			log.Success("Request %d to %s succeeded: %d %s", i+1, r.service, resp.StatusCode, body)
+		}
+	}
+
+	// Each host has its own breaker
+	log.SubSection("Breaker States")
+	states := transport.States()
+	for _, host := range []string{hostOf(payments.URL("/")), hostOf(catalog.URL("/"))} {
+		log.Info("%s: %s", names[host], states[host])
+	}
+	log.Info("Payments upstream received %d requests, %d were rejected by the breaker",
+		payments.Hits("/charge"), rejected)
+}
+
+// hostOf returns the host:port part of a URL
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/cbcache"
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/gencache"
+)
+
+const (
+	ttl            = 2 * time.Second
+	breakerTimeout = 5 * time.Second
+)
+
+// inventory is a stock service that can be taken down. Every successful
+// load returns a new stock level so refreshed values are easy to spot.
+type inventory struct {
+	mu    sync.Mutex
+	down  bool
+	calls int
+	stock map[string]int
+}
+
+func (s *inventory) load(ctx context.Context, sku string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.down {
+		return "", errors.New("inventory service unavailable")
+	}
+	s.stock[sku] += 10
+	return fmt.Sprintf("%d in stock", s.stock[sku]), nil
+}
+
+func (s *inventory) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *inventory) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("cbreak-serve-stale ")
+	log.Section("Serve Stale on Open Circuit Example")
+	return serveStaleExample(log, clock.FromEnv())
+}
+
+func serveStaleExample(log *logger.Logger, clk clock.Clock) bool {
+	config := cbreak.DefaultConfig("inventory")
+	config.FailureThreshold = 3
+	config.SuccessThreshold = 1
+	config.Timeout = breakerTimeout
+	config.CommandTimeout = time.Second
+	config.HalfOpenMaxRequests = 1
+	config.OnStateChange = func(from, to cbreak.State, reason string) {
+		log.Warn("Circuit %s -> %s (%s)", from, to, reason)
+	}
+
+	breaker, err := clock.NewBreaker[string](clk, config)
+	if err != nil {
+		log.Error("Error creating circuit breaker: %v", err)
+		return false
+	}
+	defer breaker.Shutdown()
+
+	cache := gencache.New[string, cbcache.Entry[string]]()
+	defer cache.Close()
+
+	backend := &inventory{stock: make(map[string]int)}
+	stock := cbcache.New(cache, breaker, backend.load,
+		cbcache.WithTTL(ttl),
+		cbcache.WithClock(clk),
+	)
+
+	ctx := context.Background()
+	get := func(sku string) cbcache.Result[string] {
+		result, err := stock.Get(ctx, sku)
+		switch {
+		case err != nil:
+			log.Error("%s: %v", sku, err)
+		case result.Stale:
+			log.Warn("%s: %s (stale, %v old: %v)", sku, result.Value, result.Age, result.Cause)
+		default:
+			log.Info("%s: %s", sku, result.Value)
+		}
+		return result
+	}
+
+	log.SubSection("Healthy backend")
+	get("sku-1")
+	get("sku-1")
+	log.Info("Backend calls so far: %d", backend.callCount())
+
+	log.SubSection("Backend outage")
+	backend.setDown(true)
+	clk.Sleep(ttl + time.Second)
+	for i := 0; i < 3; i++ {
+		get("sku-1")
+	}
+
+	callsBefore := backend.callCount()
+	log.Info("Circuit is %s, reading again...", breaker.GetState())
+	for i := 0; i < 2; i++ {
+		get("sku-1")
+	}
+	if _, err := stock.Get(ctx, "sku-2"); errors.Is(err, cbreak.ErrCircuitOpen) {
+		log.Error("sku-2 has no last known good value: %v", err)
+	}
+	if calls := backend.callCount(); calls != callsBefore {
+		log.Error("The open circuit let %d call(s) through to the backend", calls-callsBefore)
+		return false
+	}
+	log.Success("The open circuit kept every request off the backend")
+
+	log.SubSection("Recovery")
+	backend.setDown(false)
+	clk.Sleep(breakerTimeout + time.Second)
+	log.Info("Circuit is %s, the next read probes the backend", breaker.GetState())
+	result := get("sku-1")
+	get("sku-1")
+
+	m := stock.Metrics()
+	log.Info("Metrics: hits=%d loads=%d stale=%d failures=%d", m.Hits, m.Loads, m.Stale, m.Failures)
+	if result.Stale || breaker.GetState() != cbreak.Closed {
+		log.Error("The Half-Open probe should have refreshed sku-1 and closed the circuit")
+		return false
+	}
+	log.Success("The probe refreshed the cache and closed the circuit")
+	return true
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/gozephyr/examples/pkg/logger"
@@ -9,10 +10,17 @@ import (
 )
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-batch ")
 	log.Section("Batch Operations Example")
 	batchExample(log)
+	return true
 }
 
 func batchExample(log *logger.Logger) {
@@ -57,8 +65,8 @@ func batchExample(log *logger.Logger) {
 	// Batch get operation
 	log.Info("Performing batch get operation...")
 	retrievedValues := batchCache.GetMany(ctx, keys)
-	for key, value := range retrievedValues {
-		if value != "" {
+	for _, key := range keys {
+		if value := retrievedValues[key]; value != "" {
 			log.Success("Retrieved %s = %s", key, value)
 		} else {
 			log.Warn("No value found for key: %s", key)
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/examples/pkg/filestore"
+	"github.com/gozephyr/examples/pkg/logger"
+)
+
+const (
+	orderCount = 6
+	trials     = 500
+)
+
+// fault damages the file of an entry the way a crash or a bad disk would
+type fault struct {
+	key         string
+	description string
+	apply       func(path string) error
+}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-crash ")
+	log.Section("Crash Safety and Corruption Recovery Example")
+
+	dir, err := os.MkdirTemp("", "gencache-crash")
+	if err != nil {
+		log.Error("Error creating store directory: %v", err)
+		return false
+	}
+	ok := faultInjectionExample(log, filepath.Join(dir, "orders")) &&
+		randomFaultsExample(log, filepath.Join(dir, "random"))
+	os.RemoveAll(dir)
+	return ok
+}
+
+// orderValue is the value stored for the i-th order
+func orderValue(i int) string {
+	return fmt.Sprintf("order %d: %d items", i, i*3)
+}
+
+// truncate cuts the file to half its size, like a write interrupted in place
+func truncate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(path, info.Size()/2)
+}
+
+// flipBit flips one bit of the byte at offset; negative offsets count from the end
+func flipBit(offset int) func(path string) error {
+	return func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if offset < 0 {
+			offset += len(data)
+		}
+		data[offset] ^= 0x10
+		return os.WriteFile(path, data, 0o644)
+	}
+}
+
+// appendGarbage adds bytes after the end of the entry
+func appendGarbage(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString("garbage")
+	return err
+}
+
+// killedWriter leaves a half-written temp file next to the entry, as a
+// process killed between writing and renaming would
+func killedWriter(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), ".tmp-killed")
+	if err := os.WriteFile(tmp, data[:len(data)/2], 0o644); err != nil {
+		return err
+	}
+	old := time.Now().Add(-time.Hour)
+	return os.Chtimes(tmp, old, old)
+}
+
+func faultInjectionExample(log *logger.Logger, dir string) bool {
+	log.SubSection("Injecting faults into entry files")
+	ctx := context.Background()
+
+	orders, err := filestore.New[string, string](dir, codec.JSON[string]())
+	if err != nil {
+		log.Error("Error opening store: %v", err)
+		return false
+	}
+	for i := 1; i <= orderCount; i++ {
+		if err := orders.Set(ctx, fmt.Sprintf("order-%d", i), orderValue(i), time.Hour); err != nil {
+			log.Error("Error storing order-%d: %v", i, err)
+			return false
+		}
+	}
+
+	faults := []fault{
+		{"order-1", "truncated to half its size", truncate},
+		{"order-2", "one bit flipped in the value", flipBit(-3)},
+		{"order-3", "one bit flipped in the expiry", flipBit(12)},
+		{"order-4", "garbage appended", appendGarbage},
+		{"order-5", "writer killed before the rename", killedWriter},
+	}
+	for _, f := range faults {
+		if err := f.apply(orders.Path(f.key)); err != nil {
+			log.Error("Error injecting fault into %s: %v", f.key, err)
+			return false
+		}
+		log.Warn("%s: %s", f.key, f.description)
+	}
+	_ = orders.Close(ctx)
+
+	log.SubSection("Reopening the store")
+	orders, err = filestore.New[string, string](dir, codec.JSON[string]())
+	if err != nil {
+		log.Error("Error reopening store: %v", err)
+		return false
+	}
+	defer orders.Close(ctx)
+
+	if _, err := os.Stat(filepath.Join(dir, ".tmp-killed")); !errors.Is(err, os.ErrNotExist) {
+		log.Error("The temp file of the killed writer was not removed")
+		return false
+	}
+	log.Info("The temp file left by the killed writer was removed")
+
+	for i := 1; i <= orderCount; i++ {
+		key := fmt.Sprintf("order-%d", i)
+		value, err := orders.Load(ctx, key)
+		switch {
+		case errors.Is(err, filestore.ErrCorrupt):
+			log.Warn("%s: %v", key, err)
+		case err != nil:
+			log.Error("%s: unexpected error: %v", key, err)
+			return false
+		case value != orderValue(i):
+			log.Error("%s: returned garbage %q", key, value)
+			return false
+		default:
+			log.Info("%s: %s", key, value)
+		}
+	}
+
+	quarantined, _ := filepath.Glob(filepath.Join(dir, filestore.QuarantineDir, "*"))
+	stats := orders.Stats()
+	log.Info("Quarantined %d corrupt entries; %d entries remain", len(quarantined), orders.Size(ctx))
+	if len(quarantined) != 4 || stats.Hits != 2 {
+		log.Error("Expected 4 quarantined entries and 2 intact ones")
+		return false
+	}
+	if _, err := orders.Load(ctx, "order-1"); !errors.Is(err, filestore.ErrNotFound) {
+		log.Error("A quarantined entry should read as missing, got %v", err)
+		return false
+	}
+	log.Success("Corrupt entries were quarantined and read as misses")
+	return true
+}
+
+func randomFaultsExample(log *logger.Logger, dir string) bool {
+	log.SubSection(fmt.Sprintf("%d random bit flips and truncations", trials))
+	ctx := context.Background()
+
+	// Corrupt entries are deleted rather than kept for inspection
+	orders, err := filestore.New[string, string](dir, codec.JSON[string](), filestore.WithQuarantine(""))
+	if err != nil {
+		log.Error("Error opening store: %v", err)
+		return false
+	}
+	defer orders.Close(ctx)
+
+	rng := rand.New(rand.NewSource(1))
+	detected := 0
+	for i := 0; i < trials; i++ {
+		key := fmt.Sprintf("order-%d", i)
+		value := orderValue(i)
+		if err := orders.Set(ctx, key, value, time.Hour); err != nil {
+			log.Error("Error storing %s: %v", key, err)
+			return false
+		}
+
+		path := orders.Path(key)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Error("Error reading %s: %v", key, err)
+			return false
+		}
+		if rng.Intn(2) == 0 {
+			data[rng.Intn(len(data))] ^= 1 << rng.Intn(8)
+		} else {
+			data = data[:rng.Intn(len(data))]
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			log.Error("Error damaging %s: %v", key, err)
+			return false
+		}
+
+		got, err := orders.Load(ctx, key)
+		if err == nil {
+			log.Error("%s: damaged entry was decoded as %q", key, got)
+			return false
+		}
+		if errors.Is(err, filestore.ErrCorrupt) {
+			detected++
+		}
+	}
+	log.Info("Damaged entries detected: %d/%d", detected, trials)
+	if detected != trials {
+		return false
+	}
+	log.Success("No damaged entry was ever returned")
+	return true
+}
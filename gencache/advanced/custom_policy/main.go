@@ -1,89 +1,54 @@
 package main
 
 import (
+	"os"
 	"time"
 
+	"github.com/gozephyr/examples/pkg/fifo"
 	"github.com/gozephyr/examples/pkg/logger"
 	"github.com/gozephyr/gencache"
 )
 
-// CustomPolicy implements a simple FIFO (First In, First Out) eviction policy
-type CustomPolicy struct {
-	items    []string
-	capacity int
-}
-
-func NewCustomPolicy(capacity int) *CustomPolicy {
-	return &CustomPolicy{
-		items:    make([]string, 0, capacity),
-		capacity: capacity,
-	}
-}
-
-func (p *CustomPolicy) OnGet(key string, value string) {
-	// No-op for FIFO policy
-}
-
-func (p *CustomPolicy) OnSet(key string, value string, ttl time.Duration) {
-	if len(p.items) >= p.capacity {
-		// Remove oldest item if at capacity
-		p.items = p.items[1:]
-	}
-	p.items = append(p.items, key)
-}
-
-func (p *CustomPolicy) OnDelete(key string) {
-	// Remove key from items
-	for i, k := range p.items {
-		if k == key {
-			p.items = append(p.items[:i], p.items[i+1:]...)
-			break
-		}
-	}
-}
-
-func (p *CustomPolicy) OnClear() {
-	p.items = make([]string, 0, p.capacity)
-}
-
-func (p *CustomPolicy) Evict() (string, bool) {
-	if len(p.items) == 0 {
-		return "", false
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
 	}
-	key := p.items[0]
-	p.items = p.items[1:]
-	return key, true
-}
-
-func (p *CustomPolicy) Size() int {
-	return len(p.items)
 }
 
-func (p *CustomPolicy) Capacity() int {
-	return p.capacity
-}
-
-func main() {
-	log := logger.Get()
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-policy ")
 	log.Section("Custom Policy Example")
 	customPolicyExample(log)
+	return true
+}
+
+// newCache creates a cache bounded by the capacity of p
+func newCache(p *fifo.Policy[string, string]) gencache.Cache[string, string] {
+	return gencache.New[string, string](
+		gencache.WithMaxSize[string, string](p.Capacity()),
+		gencache.WithPolicy[string, string](p),
+	)
 }
 
 func customPolicyExample(log *logger.Logger) {
-	// Create a custom policy with capacity of 3
-	customPolicy := NewCustomPolicy(3)
+	// Create a FIFO policy, implemented in pkg/fifo, with capacity of 3
+	customPolicy := fifo.New[string, string](3)
 
 	// Create a cache with the custom policy
-	cache := gencache.New[string, string](
-		gencache.WithPolicy[string, string](customPolicy),
-	)
+	cache := newCache(customPolicy)
 	defer func() {
 		if err := cache.Close(); err != nil {
 			log.Error("Error closing cache: %v", err)
 		}
 	}()
 
+	cache.OnEvent(func(event gencache.CacheEvent[string, string]) {
+		if event.Type == gencache.EventTypeEviction {
+			log.Warn("Cache evicted %s", event.Key)
+		}
+	})
+
 	// Add items to demonstrate FIFO eviction
 	items := []struct {
 		key   string
@@ -116,9 +81,15 @@ func customPolicyExample(log *logger.Logger) {
 		}
 	}
 
+	// Deleting a key removes it from the middle of the queue
+	log.Info("Deleting key3...")
+	if err := cache.Delete("key3"); err != nil {
+		log.Error("Error deleting key3: %v", err)
+	}
+
 	// Demonstrate policy behavior
 	log.Section("Policy Statistics")
 	log.Info("Current Size: %d", customPolicy.Size())
 	log.Info("Capacity: %d", customPolicy.Capacity())
-	log.Info("Items in order: %v", customPolicy.items)
+	log.Info("Items in order: %v", customPolicy.Keys())
 }
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gozephyr/examples/pkg/golden"
+)
+
+func TestGolden(t *testing.T) {
+	golden.Run(t, run)
+}
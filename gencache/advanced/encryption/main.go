@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/examples/pkg/encstore"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/store"
+)
+
+// Session is a sensitive value that must not be readable on disk
+type Session struct {
+	UserID int
+	Token  string
+}
+
+var sessions = map[string]Session{
+	"session-1": {UserID: 1, Token: "tok_live_4f9a1c0b7e2d"},
+	"session-2": {UserID: 2, Token: "tok_live_8b3e6d2a9f10"},
+}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-encryption ")
+	log.Section("Encryption at Rest Example")
+	return encryptionExample(log)
+}
+
+// newKey returns a random AES-256 key
+func newKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// openStore opens an encrypting store over the file store in dir
+func openStore(dir string, keys encstore.KeyProvider) (*encstore.Store[string, Session], error) {
+	files, err := store.NewFileStore[string, []byte](context.Background(), &store.FileConfig{
+		Directory:       dir,
+		FileExtension:   ".cache",
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return encstore.New(files, codec.JSON[Session](), keys), nil
+}
+
+// tokensOnDisk reports whether any session token appears in the files in dir
+func tokensOnDisk(dir string) (bool, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.cache"))
+	if err != nil {
+		return false, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		for _, session := range sessions {
+			if bytes.Contains(data, []byte(session.Token)) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func encryptionExample(log *logger.Logger) bool {
+	ctx := context.Background()
+	dir, err := os.MkdirTemp("", "gencache-encrypted")
+	if err != nil {
+		log.Error("Error creating store directory: %v", err)
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	keys, err := encstore.NewKeyring("2025-01", newKey())
+	if err != nil {
+		log.Error("Error creating keyring: %v", err)
+		return false
+	}
+
+	log.SubSection("Writing sessions through an encrypting store")
+	sealedStore, err := openStore(dir, keys)
+	if err != nil {
+		log.Error("Error opening store: %v", err)
+		return false
+	}
+	cache := gencache.New[string, Session](gencache.WithStore[string, Session](sealedStore))
+	for _, id := range []string{"session-1", "session-2"} {
+		if err := cache.Set(id, sessions[id], time.Hour); err != nil {
+			log.Error("Error storing %s: %v", id, err)
+			return false
+		}
+	}
+	_ = cache.Close()
+
+	leaked, err := tokensOnDisk(dir)
+	if err != nil {
+		log.Error("Error scanning store directory: %v", err)
+		return false
+	}
+	log.Info("Session tokens readable in the files on disk: %t", leaked)
+	if leaked {
+		log.Error("A session token was written to disk in plain text")
+		return false
+	}
+
+	log.SubSection("Reading without the key")
+	wrongKeys, _ := encstore.NewKeyring("2025-01", newKey())
+	intruder, err := openStore(dir, wrongKeys)
+	if err != nil {
+		log.Error("Error opening store: %v", err)
+		return false
+	}
+	_, err = intruder.Load(ctx, "session-1")
+	_ = intruder.Close(ctx)
+	log.Warn("Load with a different key: %v", err)
+	if !errors.Is(err, encstore.ErrDecrypt) {
+		log.Error("Expected the entry to be unreadable without the key")
+		return false
+	}
+
+	log.SubSection("Rotating the key")
+	if err := keys.Rotate("2025-02", newKey()); err != nil {
+		log.Error("Error rotating key: %v", err)
+		return false
+	}
+	sealedStore, err = openStore(dir, keys)
+	if err != nil {
+		log.Error("Error opening store: %v", err)
+		return false
+	}
+	defer sealedStore.Close(ctx)
+
+	session, err := sealedStore.Load(ctx, "session-1")
+	if err != nil || session != sessions["session-1"] {
+		log.Error("Error reading session-1 after the rotation: %v", err)
+		return false
+	}
+	for _, id := range []string{"session-1", "session-2"} {
+		keyID, _ := sealedStore.KeyID(ctx, id)
+		log.Info("%s is sealed with key %s", id, keyID)
+	}
+
+	// Once every entry has been read, the old key can be retired
+	if _, err := sealedStore.Load(ctx, "session-2"); err != nil {
+		log.Error("Error reading session-2: %v", err)
+		return false
+	}
+	if err := keys.Retire("2025-01"); err != nil {
+		log.Error("Error retiring key: %v", err)
+		return false
+	}
+	for _, id := range []string{"session-1", "session-2"} {
+		session, err := sealedStore.Load(ctx, id)
+		if err != nil || session != sessions[id] {
+			log.Error("Error reading %s after retiring the old key: %v", id, err)
+			return false
+		}
+	}
+
+	stats := sealedStore.Stats()
+	log.Info("Stats: decrypted=%d failed=%d re-encrypted=%d", stats.Decrypted, stats.Failed, stats.Reencrypted)
+	log.Success("Entries were re-encrypted on read and the old key was retired")
+	return true
+}
@@ -2,18 +2,56 @@ package main
 
 import (
 	"context"
+	"errors"
+	"os"
 	"time"
 
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/examples/pkg/filestore"
 	"github.com/gozephyr/examples/pkg/logger"
 	"github.com/gozephyr/gencache"
 	"github.com/gozephyr/gencache/store"
 )
 
+// User is a struct value persisted with each codec
+type User struct {
+	ID   int
+	Name string
+}
+
+// userBinary encodes users in the compact binary format. Field numbers must
+// never be reused once entries have been written with them.
+var userBinary = codec.Binary(
+	func(w *codec.Writer, u *User) {
+		w.Int(1, int64(u.ID))
+		w.String(2, u.Name)
+	},
+	func(r *codec.Reader) (*User, error) {
+		u := &User{}
+		for r.Next() {
+			switch r.Field() {
+			case 1:
+				u.ID = int(r.Int())
+			case 2:
+				u.Name = r.String()
+			}
+		}
+		return u, r.Err()
+	},
+)
+
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-file ")
 	log.Section("File Store Example")
 	fileStoreExample(log)
+	return userStoreExample(log)
 }
 
 func fileStoreExample(log *logger.Logger) {
@@ -54,9 +92,11 @@ func fileStoreExample(log *logger.Logger) {
 		"key2": "value2",
 		"key3": "value3",
 	}
+	keys := []string{"key1", "key2", "key3"}
 
 	log.Info("Storing values in file store...")
-	for key, value := range values {
+	for _, key := range keys {
+		value := values[key]
 		err := cache.Set(key, value, time.Minute)
 		if err != nil {
 			log.Error("Error storing %s: %v", key, err)
@@ -67,7 +107,7 @@ func fileStoreExample(log *logger.Logger) {
 
 	// Retrieve values
 	log.Info("Retrieving values from file store...")
-	for key := range values {
+	for _, key := range keys {
 		value, err := cache.Get(key)
 		if err != nil {
 			log.Error("Error retrieving %s: %v", key, err)
@@ -109,7 +149,7 @@ func fileStoreExample(log *logger.Logger) {
 
 	// Verify values are still there
 	log.Info("Verifying persistence...")
-	for key := range values {
+	for _, key := range keys {
 		value, err := cache.Get(key)
 		if err != nil {
 			log.Error("Error retrieving %s after reopen: %v", key, err)
@@ -126,3 +166,113 @@ func fileStoreExample(log *logger.Logger) {
 		log.Success("Store cleared successfully")
 	}
 }
+
+// newUserCache opens a file store in dir with c and wraps it in a cache
+func newUserCache(dir string, c codec.Codec[*User], opts ...filestore.Option) (gencache.Cache[string, *User], *filestore.Store[string, *User], error) {
+	userStore, err := filestore.New[string, *User](dir, c, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache := gencache.New[string, *User](gencache.WithStore[string, *User](userStore))
+	return cache, userStore, nil
+}
+
+func userStoreExample(log *logger.Logger) bool {
+	log.SubSection("Persisting *User values with codecs")
+	users := []*User{
+		{ID: 1, Name: "John Doe"},
+		{ID: 2, Name: "Jane Smith"},
+		{ID: 3, Name: "Bob Johnson"},
+	}
+	codecs := []codec.Codec[*User]{codec.JSON[*User](), codec.Gob[*User](), userBinary}
+
+	dirs := make(map[string]string, len(codecs))
+	defer func() {
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	for _, c := range codecs {
+		dir, err := os.MkdirTemp("", "gencache-users-"+c.Name())
+		if err != nil {
+			log.Error("Error creating store directory: %v", err)
+			return false
+		}
+		dirs[c.Name()] = dir
+
+		// Store the users, then close the cache and its store
+		cache, userStore, err := newUserCache(dir, c)
+		if err != nil {
+			log.Error("Error creating %s store: %v", c.Name(), err)
+			return false
+		}
+		for _, user := range users {
+			if err := cache.Set(user.Name, user, time.Minute); err != nil {
+				log.Error("Error storing %s: %v", user.Name, err)
+				return false
+			}
+		}
+		size := userStore.MemoryUsage(context.Background())
+		if err := cache.Close(); err != nil {
+			log.Error("Error closing cache: %v", err)
+		}
+
+		// Reopen and read the users back from disk
+		cache, _, err = newUserCache(dir, c)
+		if err != nil {
+			log.Error("Error reopening %s store: %v", c.Name(), err)
+			return false
+		}
+		restored := 0
+		for _, user := range users {
+			got, err := cache.Get(user.Name)
+			if err != nil || *got != *user {
+				log.Error("User %s was not restored with %s: %v", user.Name, c.Name(), err)
+				continue
+			}
+			restored++
+		}
+		_ = cache.Close()
+		log.Success("%-6s restored %d/%d users after reopen (%d bytes on disk)", c.Name(), restored, len(users), size)
+		if restored != len(users) {
+			return false
+		}
+	}
+
+	log.SubSection("Detecting entries from another schema or codec")
+	ctx := context.Background()
+	checks := []struct {
+		description string
+		codec       codec.Codec[*User]
+		opts        []filestore.Option
+		want        error
+	}{
+		{"Reopened with schema version 2", codec.JSON[*User](), []filestore.Option{filestore.WithSchemaVersion(2)}, filestore.ErrSchemaMismatch},
+		{"Reopened with the gob codec", codec.Gob[*User](), nil, filestore.ErrCodecMismatch},
+	}
+	for _, check := range checks {
+		userStore, err := filestore.New[string, *User](dirs["json"], check.codec, check.opts...)
+		if err != nil {
+			log.Error("Error reopening store: %v", err)
+			return false
+		}
+		_, err = userStore.Load(ctx, users[0].Name)
+		_, found := userStore.Get(ctx, users[0].Name)
+		log.Warn("%s: %v (Get reports found: %t)", check.description, err, found)
+		if !errors.Is(err, check.want) || found {
+			log.Error("Expected %v", check.want)
+			return false
+		}
+	}
+
+	userStore, _ := filestore.New[string, *User](dirs["json"], codec.JSON[*User]())
+	header, err := userStore.Inspect(users[0].Name)
+	if err != nil {
+		log.Error("Error inspecting entry: %v", err)
+		return false
+	}
+	log.Info("Entry header: format %d, codec %d, schema %d", header.Format, header.Codec, header.Schema)
+	log.Success("Entries written with another schema or codec are never decoded")
+	return true
+}
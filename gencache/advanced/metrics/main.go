@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"time"
 
 	"github.com/gozephyr/examples/pkg/logger"
@@ -9,11 +10,18 @@ import (
 )
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-metrics ")
 	log.Section("Metrics Example")
 	metricsExample(log)
 	prometheusMetricsExample(log)
+	return true
 }
 
 func metricsExample(log *logger.Logger) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"time"
 
 	"github.com/gozephyr/examples/pkg/logger"
@@ -9,7 +10,13 @@ import (
 )
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-policy ")
 
 	log.Section("LRU Policy Example")
@@ -20,6 +27,7 @@ func main() {
 
 	log.Section("FIFO Policy Example")
 	fifoExample(log)
+	return true
 }
 
 func lruExample(log *logger.Logger) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"time"
 
 	"github.com/gozephyr/examples/pkg/logger"
@@ -14,10 +15,17 @@ type User struct {
 }
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-pooling ")
 	log.Section("Object Pooling Example")
 	poolingExample(log)
+	return true
 }
 
 func poolingExample(log *logger.Logger) {
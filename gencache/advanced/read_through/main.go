@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/readthrough"
+	"github.com/gozephyr/gencache"
+)
+
+// errNotFound is returned by the backend for unknown users
+var errNotFound = errors.New("user not found")
+
+// backend is a slow user database. Loads of "user:1" block until release is
+// closed, so the example can pile up concurrent callers behind one load.
+type backend struct {
+	release chan struct{}
+	stopped chan error // receives the reason a cancelled load stopped
+}
+
+func (b *backend) load(ctx context.Context, key string) (string, error) {
+	switch key {
+	case "user:1":
+		<-b.release
+		return "Ada Lovelace", nil
+	case "user:slow":
+		<-ctx.Done()
+		b.stopped <- ctx.Err()
+		return "", ctx.Err()
+	default:
+		return "", fmt.Errorf("loading %s: %w", key, errNotFound)
+	}
+}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-readthrough ")
+	log.Section("Read-Through Cache Example")
+	return readThroughExample(log, clock.FromEnv())
+}
+
+func readThroughExample(log *logger.Logger, clk clock.Clock) bool {
+	const (
+		callers     = 100
+		negativeTTL = 5 * time.Second
+	)
+
+	cache := gencache.New[string, string]()
+	defer cache.Close()
+
+	db := &backend{release: make(chan struct{}), stopped: make(chan error, 1)}
+	users := readthrough.New(cache, db.load,
+		readthrough.WithNegativeTTL(negativeTTL),
+		readthrough.WithClock(clk),
+	)
+	ctx := context.Background()
+
+	log.SubSection("Concurrent misses")
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, err := users.Get(ctx, "user:1")
+			if err != nil {
+				value = err.Error()
+			}
+			results[i] = value
+		}(i)
+	}
+
+	// Let the load finish once every caller is waiting for it
+	for users.Waiters("user:1") < callers {
+		time.Sleep(time.Millisecond)
+	}
+	log.Info("%d callers are waiting for user:1", callers)
+	close(db.release)
+	wg.Wait()
+
+	same := 0
+	for _, result := range results {
+		if result == "Ada Lovelace" {
+			same++
+		}
+	}
+	log.Info("%d of %d callers got %q", same, callers, "Ada Lovelace")
+	if users.Loads() != 1 {
+		log.Error("Expected 1 load for %d concurrent misses, got %d", callers, users.Loads())
+		return false
+	}
+	log.Success("%d concurrent misses triggered 1 load", callers)
+
+	value, _ := users.Get(ctx, "user:1")
+	log.Info("Reading user:1 again returns %q from the cache (loads: %d)", value, users.Loads())
+
+	log.SubSection("Negative caching")
+	for i := 1; i <= 2; i++ {
+		_, err := users.Get(ctx, "user:404")
+		log.Warn("Attempt %d for user:404: %v (loads: %d)", i, err, users.Loads())
+	}
+	log.Info("Waiting for the negative entry to expire...")
+	clk.Sleep(negativeTTL + time.Second)
+	_, err := users.Get(ctx, "user:404")
+	log.Warn("Attempt 3 for user:404: %v (loads: %d)", err, users.Loads())
+	if !errors.Is(err, errNotFound) {
+		log.Error("Expected the loader error to be returned")
+		return false
+	}
+
+	log.SubSection("Context cancellation")
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = users.Get(timeoutCtx, "user:slow")
+	log.Warn("Get user:slow: %v", err)
+	log.Info("Abandoned load stopped: %v", <-db.stopped)
+	log.Success("The loader was cancelled once its only caller gave up")
+	return true
+}
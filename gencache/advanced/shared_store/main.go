@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/examples/pkg/filestore"
+	"github.com/gozephyr/examples/pkg/logger"
+)
+
+const (
+	processes  = 4
+	iterations = 100
+	counters   = 3
+)
+
+// childEnv tells a re-executed copy of this program to act as a worker
+// using the store directory named by its value
+const childEnv = "ZEPHYR_SHARED_STORE_DIR"
+
+// init turns a re-executed copy into a worker before main or the tests run,
+// so the example works the same from go run and go test
+func init() {
+	if dir := os.Getenv(childEnv); dir != "" {
+		runWorker(dir)
+		os.Exit(0)
+	}
+}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-shared ")
+	log.Section("Multi-Process File Store Example")
+	return sharedStoreExample(log)
+}
+
+// runWorker runs a worker process, exiting non-zero if it fails
+func runWorker(dir string) {
+	if err := worker(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// counterKey names the i-th shared counter
+func counterKey(i int) string {
+	return fmt.Sprintf("counter-%d", i)
+}
+
+// openStore opens the shared store in dir
+func openStore(dir string) (*filestore.Store[string, int], error) {
+	return filestore.New[string, int](dir, codec.JSON[int](), filestore.WithSharedDirectory())
+}
+
+// worker runs in a child process. It increments every counter iterations
+// times, rewrites a key every process writes to, and reads it back, failing
+// on anything that is not a complete entry.
+func worker(dir string) error {
+	ctx := context.Background()
+	counts, err := openStore(dir)
+	if err != nil {
+		return err
+	}
+	defer counts.Close(ctx)
+
+	pid := os.Getpid()
+	for i := 0; i < iterations; i++ {
+		for c := 0; c < counters; c++ {
+			err := counts.Update(ctx, counterKey(c), 0, func(n int, found bool) (int, error) {
+				return n + 1, nil
+			})
+			if err != nil {
+				return fmt.Errorf("process %d: updating %s: %w", pid, counterKey(c), err)
+			}
+		}
+		if err := counts.Set(ctx, "last-writer", pid, 0); err != nil {
+			return fmt.Errorf("process %d: writing last-writer: %w", pid, err)
+		}
+		if _, err := counts.Load(ctx, "last-writer"); err != nil {
+			return fmt.Errorf("process %d: reading last-writer: %w", pid, err)
+		}
+	}
+	if stats := counts.Stats(); stats.Corrupt > 0 {
+		return fmt.Errorf("process %d: read %d corrupt entries", pid, stats.Corrupt)
+	}
+	return nil
+}
+
+func sharedStoreExample(log *logger.Logger) bool {
+	ctx := context.Background()
+	dir, err := os.MkdirTemp("", "gencache-shared")
+	if err != nil {
+		log.Error("Error creating store directory: %v", err)
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	counts, err := openStore(dir)
+	if err != nil {
+		log.Error("Error opening store: %v", err)
+		return false
+	}
+	defer counts.Close(ctx)
+
+	log.SubSection(fmt.Sprintf("%d processes sharing one directory", processes))
+	log.Info("Each process increments %d counters %d times and rewrites a shared key", counters, iterations)
+
+	var wg sync.WaitGroup
+	failures := make([]error, processes)
+	for p := 0; p < processes; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			var stderr bytes.Buffer
+			cmd := exec.Command(os.Args[0])
+			cmd.Env = append(os.Environ(), childEnv+"="+dir)
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				failures[p] = fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+			}
+		}(p)
+	}
+	wg.Wait()
+	if err := errors.Join(failures...); err != nil {
+		log.Error("A worker process failed: %v", err)
+		return false
+	}
+	log.Success("All %d worker processes finished", processes)
+
+	log.SubSection("Checking the shared directory")
+	ok := true
+	want := processes * iterations
+	for c := 0; c < counters; c++ {
+		n, err := counts.Load(ctx, counterKey(c))
+		if err != nil || n != want {
+			log.Error("%s = %d, want %d (%v)", counterKey(c), n, want, err)
+			ok = false
+			continue
+		}
+		log.Info("%s = %d", counterKey(c), n)
+	}
+
+	lastWriter, err := counts.Load(ctx, "last-writer")
+	if err != nil {
+		log.Error("Error reading last-writer: %v", err)
+		return false
+	}
+	log.Info("last-writer holds a complete entry written by one of the workers: %t", lastWriter > 0)
+
+	// Every Update and Set bumped the generation exactly once
+	writes := processes * iterations * (counters + 1)
+	gen, err := counts.Generation()
+	if err != nil || gen != uint64(writes) {
+		log.Error("Generation is %d, want %d (%v)", gen, writes, err)
+		return false
+	}
+	log.Info("Generation counter: %d (one per write)", gen)
+
+	if !ok {
+		return false
+	}
+	log.Success("No update was lost and no partial entry was read")
+	return true
+}
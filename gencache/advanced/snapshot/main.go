@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/snapshot"
+	"github.com/gozephyr/gencache"
+)
+
+const (
+	interval = 2 * time.Second
+	downtime = 5 * time.Second
+)
+
+// Product is a cached catalog entry
+type Product struct {
+	Name  string
+	Price int
+}
+
+// product is an entry stored before the restart
+type product struct {
+	key   string
+	value Product
+	ttl   time.Duration
+}
+
+var products = []product{
+	{"product-1", Product{"Keyboard", 4900}, time.Hour},
+	{"product-2", Product{"Mouse", 1900}, time.Hour},
+	{"product-3", Product{"Monitor", 18900}, 10 * time.Minute},
+	{"flash-sale", Product{"Headphones", 2900}, 3 * time.Second},
+}
+
+// late is stored after the background snapshot and saved by Close
+var late = product{"product-4", Product{"Webcam", 5900}, time.Hour}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-snapshot ")
+	log.Section("Snapshot and Restore Example")
+
+	dir, err := os.MkdirTemp("", "gencache-snapshot")
+	if err != nil {
+		log.Error("Error creating snapshot directory: %v", err)
+		return false
+	}
+
+	clk := clock.FromEnv()
+	path := filepath.Join(dir, "catalog.snap")
+	ok := firstRunExample(log, clk, path) &&
+		restartExample(log, clk, path) &&
+		corruptSnapshotExample(log, clk, path)
+	os.RemoveAll(dir)
+	return ok
+}
+
+// openCatalog returns an empty in-memory cache saved to path
+func openCatalog(clk clock.Clock, path string) *snapshot.Cache[string, Product] {
+	cache := clock.WrapCache(clk, gencache.New[string, Product]())
+	return snapshot.New(cache, path, codec.JSON[string](), codec.JSON[Product](),
+		snapshot.WithInterval(interval),
+		snapshot.WithClock(clk),
+	)
+}
+
+func firstRunExample(log *logger.Logger, clk clock.Clock, path string) bool {
+	log.SubSection("First run")
+	ctx := context.Background()
+	catalog := openCatalog(clk, path)
+
+	restored, err := catalog.Restore(ctx)
+	if err != nil {
+		log.Error("Error restoring snapshot: %v", err)
+		return false
+	}
+	log.Info("Restored %d entries (no snapshot yet: %t)", restored.Entries, restored.SavedAt.IsZero())
+
+	for _, p := range products {
+		if err := catalog.Set(p.key, p.value, p.ttl); err != nil {
+			log.Error("Error storing %s: %v", p.key, err)
+			return false
+		}
+		log.Info("Stored %s with TTL %v", p.key, p.ttl)
+	}
+
+	clk.Sleep(interval)
+	for i := 0; i < 1000 && catalog.Metrics().Snapshots == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	metrics := catalog.Metrics()
+	if metrics.Snapshots == 0 {
+		log.Error("No background snapshot was taken")
+		return false
+	}
+	log.Info("Background snapshot after %v: %d entries", interval, metrics.Entries)
+
+	if err := catalog.Set(late.key, late.value, late.ttl); err != nil {
+		log.Error("Error storing %s: %v", late.key, err)
+		return false
+	}
+	log.Info("Stored %s after the snapshot", late.key)
+
+	if err := catalog.Close(); err != nil {
+		log.Error("Error closing cache: %v", err)
+		return false
+	}
+	metrics = catalog.Metrics()
+	log.Info("Close took a final snapshot: %d entries", metrics.Entries)
+	if metrics.Entries != int64(len(products)+1) {
+		log.Error("Expected %d entries in the final snapshot", len(products)+1)
+		return false
+	}
+	log.Success("Shut down with a snapshot of every live entry")
+	return true
+}
+
+func restartExample(log *logger.Logger, clk clock.Clock, path string) bool {
+	log.SubSection("Restart after " + downtime.String() + " of downtime")
+	ctx := context.Background()
+	clk.Sleep(downtime)
+
+	catalog := openCatalog(clk, path)
+	defer catalog.Close()
+
+	restored, err := catalog.Restore(ctx)
+	if err != nil {
+		log.Error("Error restoring snapshot: %v", err)
+		return false
+	}
+	log.Info("Restored %d entries; %d expired while down (downtime %v)",
+		restored.Entries, restored.Expired, restored.Downtime)
+
+	for _, p := range append(products, late) {
+		value, err := catalog.Get(p.key)
+		if p.key == "flash-sale" {
+			if err == nil {
+				log.Error("flash-sale outlived its TTL across the restart")
+				return false
+			}
+			log.Info("flash-sale: gone, its TTL ran out while the process was down")
+			continue
+		}
+		if err != nil || value != p.value {
+			log.Error("%s: got %+v (%v), want %+v", p.key, value, err, p.value)
+			return false
+		}
+		ttl, _ := catalog.TTL(p.key)
+		log.Info("%s: %s at %d, TTL left %v", p.key, value.Name, value.Price, ttl.Round(time.Second))
+	}
+
+	if restored.Entries != len(products) || restored.Expired != 1 {
+		log.Error("Expected %d restored entries and 1 expired one", len(products))
+		return false
+	}
+	log.Success("The cache came back warm with TTLs adjusted for the downtime")
+	return true
+}
+
+func corruptSnapshotExample(log *logger.Logger, clk clock.Clock, path string) bool {
+	log.SubSection("Restoring a damaged snapshot")
+	ctx := context.Background()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Error("Error reading snapshot: %v", err)
+		return false
+	}
+	data[len(data)/2] ^= 0x10
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Error("Error damaging snapshot: %v", err)
+		return false
+	}
+	log.Warn("Flipped one bit in the middle of the snapshot")
+
+	catalog := openCatalog(clk, path)
+	restored, err := catalog.Restore(ctx)
+	if !errors.Is(err, snapshot.ErrCorrupt) || restored.Entries != 0 {
+		log.Error("Expected the damaged snapshot to be rejected, got %d entries (%v)", restored.Entries, err)
+		return false
+	}
+	log.Warn("Restore: %v", err)
+	log.Info("Restored %d entries; the cache starts empty", restored.Entries)
+
+	// Closing saves a new, empty snapshot over the damaged one
+	if err := catalog.Close(); err != nil {
+		log.Error("Error closing cache: %v", err)
+		return false
+	}
+	log.Success("A damaged snapshot is rejected whole instead of half-loaded")
+	return true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/refresh"
+	"github.com/gozephyr/gencache"
+)
+
+const (
+	softTTL = 2 * time.Second
+	hardTTL = 10 * time.Second
+)
+
+// pricing is a backend whose prices change on every load and which can be
+// switched into a failing state
+type pricing struct {
+	mu       sync.Mutex
+	versions map[string]int
+	failing  bool
+}
+
+func (p *pricing) load(ctx context.Context, key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing {
+		return "", errors.New("pricing service unavailable")
+	}
+	p.versions[key]++
+	return fmt.Sprintf("%s v%d", key, p.versions[key]), nil
+}
+
+func (p *pricing) setFailing(failing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing = failing
+}
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-swr ")
+	log.Section("Stale-While-Revalidate Example")
+	clk := clock.FromEnv()
+	staleWhileRevalidateExample(log, clk)
+	return refreshAheadExample(log, clk)
+}
+
+func staleWhileRevalidateExample(log *logger.Logger, clk clock.Clock) {
+	backend := &pricing{versions: make(map[string]int)}
+	cache := gencache.New[string, refresh.Entry[string]]()
+	defer cache.Close()
+	prices := refresh.New(cache, backend.load,
+		refresh.WithTTL(softTTL, hardTTL),
+		refresh.WithClock(clk),
+	)
+	defer prices.Close()
+
+	ctx := context.Background()
+	get := func(label string) {
+		value, err := prices.Get(ctx, "price:btc")
+		if err != nil {
+			log.Error("%s: %v", label, err)
+			return
+		}
+		log.Info("%s: %s", label, value)
+	}
+
+	log.SubSection(fmt.Sprintf("Soft TTL %v, hard TTL %v", softTTL, hardTTL))
+	get("First read (miss, loaded)")
+	get("Second read (fresh)")
+
+	clk.Sleep(softTTL + time.Second)
+	get("Read after the soft TTL (stale, refreshing)")
+	prices.Wait()
+	get("Read after the refresh (fresh)")
+
+	log.SubSection("Backend outage")
+	backend.setFailing(true)
+	clk.Sleep(softTTL + time.Second)
+	get("Read during the outage (stale, refresh fails)")
+	prices.Wait()
+	get("Read after the failed refresh (stale, retry held off)")
+	prices.Wait()
+
+	clk.Sleep(hardTTL)
+	get("Read after the hard TTL")
+
+	m := prices.Metrics()
+	log.Info("Metrics: fresh=%d stale=%d misses=%d refreshes=%d refresh failures=%d",
+		m.FreshHits, m.StaleHits, m.Misses, m.Refreshes, m.RefreshFailures)
+}
+
+func refreshAheadExample(log *logger.Logger, clk clock.Clock) bool {
+	const (
+		interval = time.Second
+		minReads = 3
+	)
+	log.SubSection(fmt.Sprintf("Refresh ahead (every %v, %d+ reads)", interval, minReads))
+
+	backend := &pricing{versions: make(map[string]int)}
+	cache := gencache.New[string, refresh.Entry[string]]()
+	defer cache.Close()
+	prices := refresh.New(cache, backend.load,
+		refresh.WithTTL(softTTL, hardTTL),
+		refresh.WithRefreshAhead(interval, minReads),
+		refresh.WithClock(clk),
+	)
+	defer prices.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, _ = prices.Get(ctx, "price:hot")
+	}
+	_, _ = prices.Get(ctx, "price:cold")
+	log.Info("Read price:hot 5 times and price:cold once")
+
+	// The next check finds price:hot about to go stale and refreshes it
+	clk.Sleep(interval)
+	for i := 0; i < 1000 && prices.Metrics().AheadRefreshes == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	prices.Wait()
+	log.Info("Refreshed ahead: %d key(s)", prices.Metrics().AheadRefreshes)
+
+	clk.Sleep(softTTL - interval/2)
+	before := prices.Metrics()
+	hot, _ := prices.Get(ctx, "price:hot")
+	afterHot := prices.Metrics()
+	cold, _ := prices.Get(ctx, "price:cold")
+	afterCold := prices.Metrics()
+	prices.Wait()
+
+	log.Info("price:hot = %s (fresh: %t)", hot, afterHot.FreshHits > before.FreshHits)
+	log.Info("price:cold = %s (stale: %t)", cold, afterCold.StaleHits > afterHot.StaleHits)
+
+	if afterHot.FreshHits == before.FreshHits {
+		log.Error("price:hot should have been refreshed before going stale")
+		return false
+	}
+	log.Success("The hot key never went stale")
+	return true
+}
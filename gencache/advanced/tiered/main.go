@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/tiered"
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
+	"github.com/gozephyr/gencache/store"
+)
+
+const (
+	l1Capacity    = 3
+	entryTTL      = time.Hour
+	flushInterval = time.Second
+)
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-tiered ")
+	log.Section("Two-Tier Cache Example")
+
+	dir, err := os.MkdirTemp("", "gencache-tiered")
+	if err != nil {
+		log.Error("Error creating store directory: %v", err)
+		return false
+	}
+	defer os.RemoveAll(dir)
+
+	clk := clock.FromEnv()
+	ok := writeThroughExample(log, dir) &&
+		writeBackExample(log, clk, dir) &&
+		persistenceExample(log, dir)
+	return ok
+}
+
+// newL1 creates the small in-memory front tier
+func newL1() gencache.Cache[string, string] {
+	return gencache.New[string, string](
+		gencache.WithMaxSize[string, string](l1Capacity),
+		gencache.WithPolicy[string, string](policy.NewLRU[string, string](policy.WithMaxSize(l1Capacity))),
+	)
+}
+
+// newL2 creates the file-backed tier storing its entries under dir.
+// Closing the cache closes the store.
+func newL2(dir string) (gencache.Cache[string, string], error) {
+	fileStore, err := store.NewFileStore[string, string](context.Background(), &store.FileConfig{
+		Directory:       dir,
+		FileExtension:   ".cache",
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gencache.New[string, string](gencache.WithStore[string, string](fileStore)), nil
+}
+
+// read gets key and logs which tier served it
+func read(log *logger.Logger, cache *tiered.Cache[string, string], key string) {
+	before := cache.Metrics()
+	value, err := cache.Get(context.Background(), key)
+	after := cache.Metrics()
+	switch {
+	case err != nil:
+		log.Warn("%s: miss", key)
+	case after.L2Hits > before.L2Hits:
+		log.Info("%s = %s (L2 hit, promoted to L1)", key, value)
+	default:
+		log.Info("%s = %s (L1 hit)", key, value)
+	}
+}
+
+// logMetrics logs the per-tier counters
+func logMetrics(log *logger.Logger, cache *tiered.Cache[string, string]) {
+	m := cache.Metrics()
+	log.Info("Metrics: L1 hits=%d L2 hits=%d misses=%d flushed=%d pending=%d",
+		m.L1Hits, m.L2Hits, m.Misses, m.Flushed, m.PendingFlush)
+}
+
+func writeThroughExample(log *logger.Logger, dir string) bool {
+	log.SubSection(fmt.Sprintf("Write-through (L1 holds %d entries)", l1Capacity))
+	ctx := context.Background()
+
+	l1 := newL1()
+	defer l1.Close()
+	l2, err := newL2(dir)
+	if err != nil {
+		log.Error("Error creating file-backed tier: %v", err)
+		return false
+	}
+	defer l2.Close()
+
+	cache := tiered.New(l1, l2)
+	defer cache.Close(ctx)
+
+	for i := 1; i <= 5; i++ {
+		key := fmt.Sprintf("product-%d", i)
+		if err := cache.Set(ctx, key, fmt.Sprintf("Product %d", i), entryTTL); err != nil {
+			log.Error("Error storing %s: %v", key, err)
+			return false
+		}
+	}
+	log.Info("Stored 5 products; the oldest 2 were evicted from L1 but remain in L2")
+	for _, key := range []string{"product-5", "product-1", "product-1"} {
+		read(log, cache, key)
+	}
+
+	log.Info("Deleting product-1 from both tiers...")
+	if err := cache.Delete(ctx, "product-1"); err != nil {
+		log.Error("Error deleting product-1: %v", err)
+		return false
+	}
+	_, l1Err := l1.Get("product-1")
+	_, l2Err := l2.Get("product-1")
+	if l1Err == nil || l2Err == nil {
+		log.Error("product-1 is still present after the delete (L1: %t, L2: %t)", l1Err == nil, l2Err == nil)
+		return false
+	}
+	read(log, cache, "product-1")
+	logMetrics(log, cache)
+	return true
+}
+
+func writeBackExample(log *logger.Logger, clk clock.Clock, dir string) bool {
+	log.SubSection(fmt.Sprintf("Write-back (flushed every %v)", flushInterval))
+	ctx := context.Background()
+
+	l1 := newL1()
+	defer l1.Close()
+	l2, err := newL2(dir)
+	if err != nil {
+		log.Error("Error creating file-backed tier: %v", err)
+		return false
+	}
+	defer l2.Close()
+
+	cache := tiered.New(l1, l2, tiered.WithWriteBack(flushInterval), tiered.WithClock(clk))
+	defer cache.Close(ctx)
+
+	for _, key := range []string{"order-1", "order-2", "order-3"} {
+		_ = cache.Set(ctx, key, "pending", entryTTL)
+	}
+	_ = cache.Set(ctx, "order-4", "cancelled", entryTTL)
+	_ = cache.Delete(ctx, "order-4")
+
+	_, err = l2.Get("order-1")
+	log.Info("Queued %d writes; order-1 in L2 before the flush: %t", cache.Metrics().PendingFlush, err == nil)
+
+	clk.Sleep(flushInterval)
+	for i := 0; i < 1000 && cache.Metrics().PendingFlush > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	log.Info("After the flush interval, %d writes are queued", cache.Metrics().PendingFlush)
+
+	for _, key := range []string{"order-1", "order-2", "order-3", "order-4"} {
+		if _, err := l2.Get(key); (err == nil) != (key != "order-4") {
+			log.Error("%s has the wrong presence in L2 after the flush", key)
+			return false
+		}
+	}
+	log.Success("The flush stored the 3 orders in L2 and did not resurrect order-4")
+	logMetrics(log, cache)
+	return true
+}
+
+func persistenceExample(log *logger.Logger, dir string) bool {
+	log.SubSection("Restart with an empty L1")
+	ctx := context.Background()
+
+	l1 := newL1()
+	defer l1.Close()
+	l2, err := newL2(dir)
+	if err != nil {
+		log.Error("Error reopening file-backed tier: %v", err)
+		return false
+	}
+	defer l2.Close()
+
+	cache := tiered.New(l1, l2)
+	defer cache.Close(ctx)
+
+	for _, key := range []string{"product-2", "order-3", "order-3", "product-1"} {
+		read(log, cache, key)
+	}
+	m := cache.Metrics()
+	logMetrics(log, cache)
+	if m.L1Hits != 1 || m.L2Hits != 2 || m.Misses != 1 {
+		log.Error("Expected 1 L1 hit, 2 L2 hits and 1 miss after the restart")
+		return false
+	}
+	log.Success("Entries written before the restart were served from disk and promoted")
+	return true
+}
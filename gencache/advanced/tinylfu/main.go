@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/cachepolicy"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
+)
+
+const (
+	capacity = 500     // entries held by each cache
+	keySpace = 50_000  // distinct keys in the workload
+	requests = 200_000 // requests replayed against each cache
+	skew     = 1.1     // Zipf exponent, larger values concentrate requests on fewer keys
+	seed     = 42
+)
+
+func main() {
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
+	log.SetPrefix("gencache-tinylfu ")
+	log.Section("W-TinyLFU Policy Example")
+	return tinyLFUExample(log)
+}
+
+// zipfTrace returns requests keys drawn from a Zipf distribution, so a few
+// keys are very popular and most are requested once or never
+func zipfTrace() []string {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, skew, 1, keySpace-1)
+	trace := make([]string, requests)
+	for i := range trace {
+		trace[i] = "key" + strconv.FormatUint(zipf.Uint64(), 10)
+	}
+	return trace
+}
+
+// replay reads every key of trace from cache, storing it on a miss, and
+// returns the number of hits
+func replay(cache gencache.Cache[string, string], trace []string) int {
+	hits := 0
+	for _, key := range trace {
+		if _, err := cache.Get(key); err == nil {
+			hits++
+			continue
+		}
+		_ = cache.Set(key, key, time.Minute)
+	}
+	return hits
+}
+
+func tinyLFUExample(log *logger.Logger) bool {
+	tinyLFU := cachepolicy.NewWTinyLFU[string, string](policy.WithMaxSize(capacity))
+	policies := []struct {
+		name   string
+		policy policy.Policy[string, string]
+	}{
+		{"LRU", policy.NewLRU[string, string](policy.WithMaxSize(capacity))},
+		{"LFU", policy.NewLFU[string, string](policy.WithMaxSize(capacity))},
+		{"W-TinyLFU", tinyLFU},
+	}
+
+	trace := zipfTrace()
+	log.Info("Replaying %d Zipf(%.1f) requests over %d keys against caches of %d entries",
+		requests, skew, keySpace, capacity)
+
+	hitRatios := make(map[string]float64, len(policies))
+	for _, p := range policies {
+		cache := gencache.New[string, string](
+			gencache.WithMaxSize[string, string](capacity),
+			gencache.WithPolicy[string, string](p.policy),
+		)
+		hits := replay(cache, trace)
+		_ = cache.Close()
+
+		hitRatios[p.name] = float64(hits) / float64(len(trace)) * 100
+		log.Info("%-9s hit ratio: %5.1f%% (%d hits)", p.name, hitRatios[p.name], hits)
+	}
+
+	windowLen, probationLen, protectedLen := tinyLFU.Lengths()
+	log.Info("W-TinyLFU segments: window=%d probation=%d protected=%d", windowLen, probationLen, protectedLen)
+
+	// The admission filter keeps one-hit wonders from displacing popular keys
+	if hitRatios["W-TinyLFU"] <= hitRatios["LRU"] || hitRatios["W-TinyLFU"] <= hitRatios["LFU"] {
+		log.Error("W-TinyLFU did not beat LRU and LFU on the Zipf workload")
+		return false
+	}
+	log.Success("W-TinyLFU had the best hit ratio")
+	return true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sizer is implemented by gencache caches, though not part of gencache.Cache
+type sizer interface {
+	Size() int
+}
+
+func TestConcurrentWritersStayWithinCapacity(t *testing.T) {
+	const (
+		maxSize = 50
+		writers = 8
+		writes  = 2000
+	)
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			cache := newBoundedCache(p, maxSize)
+			defer cache.Close()
+			sized, ok := cache.(sizer)
+			if !ok {
+				t.Fatalf("%T has no Size method", cache)
+			}
+
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < writes; i++ {
+						key := fmt.Sprintf("writer%d-key%d", w, i)
+						if err := cache.Set(key, key, time.Minute); err != nil {
+							t.Errorf("Set %s: %v", key, err)
+							return
+						}
+					}
+				}(w)
+			}
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+
+			// Sample the size for as long as the writers run
+			samples, peak := 0, 0
+			for running := true; running; {
+				select {
+				case <-done:
+					running = false
+				default:
+				}
+				size := sized.Size()
+				samples++
+				peak = max(peak, size)
+				if size > maxSize {
+					t.Fatalf("cache holds %d entries, beyond its capacity of %d", size, maxSize)
+				}
+			}
+
+			if size := sized.Size(); size != maxSize {
+				t.Errorf("final size = %d, want %d", size, maxSize)
+			}
+			evictions := cache.Stats().Evictions.Load()
+			if want := int64(writers*writes - maxSize); evictions != want {
+				t.Errorf("evictions = %d, want %d", evictions, want)
+			}
+			t.Logf("%d samples, peak size %d", samples, peak)
+		})
+	}
+}
@@ -2,47 +2,178 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gozephyr/examples/pkg/logger"
 	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
 )
 
+// capacity is the maximum number of entries held by the caches in the example
+const capacity = 3
+
+// evictionPolicy describes one of the built-in eviction policies
+type evictionPolicy struct {
+	name   string
+	reason string
+	create func(opts ...policy.Option) policy.Policy[string, string]
+}
+
+// policies are the built-in eviction policies demonstrated by the example
+var policies = []evictionPolicy{
+	{"LRU", "least recently used", policy.NewLRU[string, string]},
+	{"LFU", "least frequently used", policy.NewLFU[string, string]},
+	{"FIFO", "first inserted", policy.NewFIFO[string, string]},
+}
+
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-capacity")
-	log.Section("Cache Operations Example")
-	cacheOperationsExample(log)
+	log.Section("Capacity Limits Example")
+	for _, p := range policies {
+		capacityLimitsExample(log, p)
+	}
+	return concurrentWritersExample(log)
 }
 
-func cacheOperationsExample(log *logger.Logger) {
-	// Create a cache
-	cache := gencache.New[string, string]()
+// newBoundedCache creates a cache holding at most maxSize entries, evicting with p
+func newBoundedCache(p evictionPolicy, maxSize int) gencache.Cache[string, string] {
+	return gencache.New[string, string](
+		gencache.WithMaxSize[string, string](maxSize),
+		gencache.WithPolicy[string, string](p.create(policy.WithMaxSize(maxSize))),
+	)
+}
+
+func capacityLimitsExample(log *logger.Logger, p evictionPolicy) {
+	log.SubSection(fmt.Sprintf("%s policy (capacity %d)", p.name, capacity))
+
+	cache := newBoundedCache(p, capacity)
 	defer func() {
 		if err := cache.Close(); err != nil {
 			log.Warn("Error closing cache: %v", err)
 		}
 	}()
 
-	// Insert items
-	for i := 1; i <= 4; i++ {
-		key := fmt.Sprintf("key%d", i)
-		value := fmt.Sprintf("value%d", i)
-		log.Info("Setting %s = %s", key, value)
-		err := cache.Set(key, value, time.Minute)
-		if err != nil {
-			log.Error("Set error: %v", err)
+	// Report evictions as they happen. The callback runs while the cache is
+	// locked, so it must not call back into the cache.
+	cache.OnEvent(func(event gencache.CacheEvent[string, string]) {
+		if event.Type == gencache.EventTypeEviction {
+			log.Warn("Evicted %s: %s entry", event.Key, p.reason)
 		}
+	})
+
+	// Fill the cache to capacity
+	for i := 1; i <= capacity; i++ {
+		set(log, cache, fmt.Sprintf("key%d", i))
+	}
+
+	// Read key1 twice and key2 once so the entries differ in recency and frequency
+	for _, key := range []string{"key1", "key1", "key2"} {
+		if _, err := cache.Get(key); err != nil {
+			log.Error("Get %s error: %v", key, err)
+		}
+	}
+	log.Info("Read key1 twice and key2 once")
+
+	// Overflow the cache by two entries
+	for i := capacity + 1; i <= capacity+2; i++ {
+		set(log, cache, fmt.Sprintf("key%d", i))
 	}
 
-	// Check which keys are present
-	for i := 1; i <= 4; i++ {
+	// Check which keys survived
+	present := 0
+	for i := 1; i <= capacity+2; i++ {
 		key := fmt.Sprintf("key%d", i)
-		value, err := cache.Get(key)
-		if err != nil {
-			log.Warn("%s was not found: %v", key, err)
+		if value, err := cache.Get(key); err != nil {
+			log.Info("%s is gone", key)
 		} else {
+			present++
 			log.Success("%s is present with value: %s", key, value)
 		}
 	}
+
+	stats := cache.Stats()
+	log.Info("%d of %d entries present, %d evictions", present, capacity, stats.Evictions.Load())
+}
+
+// set stores key in the cache, logging failures
+func set(log *logger.Logger, cache gencache.Cache[string, string], key string) {
+	value := "value" + key[len("key"):]
+	log.Info("Setting %s = %s", key, value)
+	if err := cache.Set(key, value, time.Minute); err != nil {
+		log.Error("Set error: %v", err)
+	}
+}
+
+// concurrentWritersExample fills a small cache from several goroutines and
+// checks that the number of entries never exceeds the configured capacity
+func concurrentWritersExample(log *logger.Logger) bool {
+	const (
+		maxSize = 50
+		writers = 8
+		writes  = 500
+	)
+	log.SubSection(fmt.Sprintf("Concurrent writers (capacity %d)", maxSize))
+
+	cache := newBoundedCache(policies[0], maxSize)
+	defer func() {
+		if err := cache.Close(); err != nil {
+			log.Warn("Error closing cache: %v", err)
+		}
+	}()
+
+	// Every key is distinct, so each Set adds one entry and each eviction removes one
+	var size, peak atomic.Int64
+	cache.OnEvent(func(event gencache.CacheEvent[string, string]) {
+		switch event.Type {
+		case gencache.EventTypeSet:
+			n := size.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+		case gencache.EventTypeEviction:
+			size.Add(-1)
+		}
+	})
+
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Int64
+	)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				key := fmt.Sprintf("writer%d-key%d", w, i)
+				if err := cache.Set(key, key, time.Minute); err != nil {
+					failed.Add(1)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	log.Info("%d writers stored %d keys (%d failed)", writers, writers*writes, failed.Load())
+	log.Info("Final size %d, peak size %d, evictions %d", size.Load(), peak.Load(), stats.Evictions.Load())
+
+	if peak.Load() > maxSize {
+		log.Error("Cache grew to %d entries, beyond its capacity of %d", peak.Load(), maxSize)
+		return false
+	}
+	log.Success("Cache never held more than %d entries", maxSize)
+	return true
 }
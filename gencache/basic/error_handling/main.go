@@ -1,22 +1,31 @@
 package main
 
 import (
+	"os"
 	"time"
 
+	"github.com/gozephyr/examples/pkg/clock"
 	"github.com/gozephyr/examples/pkg/logger"
 	"github.com/gozephyr/gencache"
 )
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache-error ")
 
 	log.Section("Error Handling Example")
-	errorHandlingExample(log)
+	errorHandlingExample(log, clock.FromEnv())
+	return true
 }
 
-func errorHandlingExample(log *logger.Logger) {
-	cache := gencache.New[string, string]()
+func errorHandlingExample(log *logger.Logger, clk clock.Clock) {
+	cache := clock.WrapCache(clk, gencache.New[string, string]())
 	defer func() {
 		if err := cache.Close(); err != nil {
 			log.Warn("Error closing cache: %v", err)
@@ -46,7 +55,7 @@ func errorHandlingExample(log *logger.Logger) {
 
 	// Wait for the key to expire
 	log.Info("Waiting for 2s to let the key expire...")
-	time.Sleep(2 * time.Second)
+	clk.Sleep(2 * time.Second)
 
 	// Try to get the expired key
 	_, err = cache.Get("short_lived")
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"os"
 	"time"
 
+	"github.com/gozephyr/examples/pkg/clock"
 	"github.com/gozephyr/examples/pkg/logger"
 	"github.com/gozephyr/gencache"
 )
@@ -14,7 +16,13 @@ type User struct {
 }
 
 func main() {
-	log := logger.Get()
+	if !run(logger.Get()) {
+		os.Exit(1)
+	}
+}
+
+// run runs the example, logging to log, and reports whether its checks passed
+func run(log *logger.Logger) bool {
 	log.SetPrefix("gencache ")
 
 	log.Section("Basic Cache Operations Example")
@@ -24,7 +32,8 @@ func main() {
 	customTypeOperations(log)
 
 	log.Section("TTL Operations Example")
-	ttlOperations(log)
+	ttlOperations(log, clock.FromEnv())
+	return true
 }
 
 func basicOperations(log *logger.Logger) {
@@ -94,8 +103,8 @@ func customTypeOperations(log *logger.Logger) {
 	log.Success("Retrieved user: ID=%d, Name=%s", retrievedUser.ID, retrievedUser.Name)
 }
 
-func ttlOperations(log *logger.Logger) {
-	cache := gencache.New[string, string]()
+func ttlOperations(log *logger.Logger, clk clock.Clock) {
+	cache := clock.WrapCache(clk, gencache.New[string, string]())
 
 	defer func() {
 		if err := cache.Close(); err != nil {
@@ -120,7 +129,7 @@ func ttlOperations(log *logger.Logger) {
 	log.Success("Retrieved value: %s", value)
 
 	log.Info("Waiting for value to expire (2s)...")
-	time.Sleep(2 * time.Second)
+	clk.Sleep(2 * time.Second)
 
 	log.Info("Trying to get expired value...")
 	_, err = cache.Get("temp_key")
@@ -0,0 +1,192 @@
+package benchmarks
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/workload"
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
+	"github.com/gozephyr/gencache/store"
+)
+
+const (
+	capacity  = 1_000  // entries held by the in-memory caches
+	keySpace  = 10_000 // distinct keys in each workload
+	requests  = 100_000
+	batchSize = 100
+	entryTTL  = time.Hour
+
+	// warmup requests are replayed before timing starts so hit ratios
+	// describe a full cache rather than the cold start
+	warmup = 20_000
+)
+
+// namedKeys is a key distribution with a short name for benchmark labels
+type namedKeys struct {
+	name string
+	keys workload.Keys
+}
+
+// workloads returns a fresh key distribution for each workload
+func workloads() []namedKeys {
+	return []namedKeys{
+		{"uniform", workload.Uniform(keySpace)},
+		{"zipfian", workload.Zipfian(keySpace, 1.1)},
+		{"hotspot", workload.Hotspot(keySpace, keySpace/100, 90)},
+		{"sequential", workload.Sequential(keySpace)},
+		{"shifting", workload.Shifting(capacity, requests/20, capacity/4)},
+	}
+}
+
+// policies are the built-in eviction policies benchmarked
+var policies = []struct {
+	name   string
+	create func(opts ...policy.Option) policy.Policy[string, string]
+}{
+	{"LRU", policy.NewLRU[string, string]},
+	{"LFU", policy.NewLFU[string, string]},
+	{"FIFO", policy.NewFIFO[string, string]},
+}
+
+// zipfRequests is the Zipfian workload shared by the batch and file store benchmarks
+func zipfRequests() []workload.Request {
+	return workload.New(workload.Zipfian(keySpace, 1.1), workload.WithMix(workload.Balanced)).Take(requests)
+}
+
+// BenchmarkPolicies runs each policy under each workload
+func BenchmarkPolicies(b *testing.B) {
+	for _, p := range policies {
+		for _, w := range workloads() {
+			// Generate the requests once; b.Run calls the function again for every N
+			requests := workload.New(w.keys, workload.WithMix(workload.ReadHeavy)).Take(requests)
+			b.Run(p.name+"/"+w.name, func(b *testing.B) {
+				benchmarkCache(b, newCache(p.create(policy.WithMaxSize(capacity))), requests)
+			})
+		}
+	}
+}
+
+// BenchmarkBatchCache writes and reads the workload keys in batches
+func BenchmarkBatchCache(b *testing.B) {
+	benchmarkBatch(b, zipfRequests())
+}
+
+// BenchmarkFileStore replays the workload against a cache backed by a file store
+func BenchmarkFileStore(b *testing.B) {
+	benchmarkFileStore(b, zipfRequests())
+}
+
+// newCache creates a bounded cache using p
+func newCache(p policy.Policy[string, string]) gencache.Cache[string, string] {
+	return gencache.New[string, string](
+		gencache.WithMaxSize[string, string](capacity),
+		gencache.WithPolicy[string, string](p),
+	)
+}
+
+// benchmarkCache replays requests against cache, reading through on misses,
+// and reports the hit ratio alongside the timing. The first warmup requests
+// fill the cache untimed; the timed requests continue from there and are
+// the only ones counted in the hit ratio.
+func benchmarkCache(b *testing.B, cache gencache.Cache[string, string], requests []workload.Request) {
+	defer cache.Close()
+	for i := 0; i < warmup; i++ {
+		apply(cache, requests[i%len(requests)])
+	}
+	b.ReportAllocs()
+
+	var hits, reads int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		read, hit := apply(cache, requests[(warmup+i)%len(requests)])
+		if read {
+			reads++
+		}
+		if hit {
+			hits++
+		}
+	}
+	if reads > 0 {
+		b.ReportMetric(float64(hits)/float64(reads)*100, "hit%")
+	}
+}
+
+// apply performs req against cache, storing the key when a read misses, and
+// reports whether it was a read and whether that read hit
+func apply(cache gencache.Cache[string, string], req workload.Request) (read, hit bool) {
+	switch req.Op {
+	case workload.Read:
+		if _, err := cache.Get(req.Key); err == nil {
+			return true, true
+		}
+		_ = cache.Set(req.Key, req.Key, entryTTL)
+		return true, false
+	case workload.Write:
+		_ = cache.Set(req.Key, req.Key, entryTTL)
+	case workload.Delete:
+		_ = cache.Delete(req.Key)
+	}
+	return false, false
+}
+
+// benchmarkBatch writes and reads the workload keys in batches through a BatchCache
+func benchmarkBatch(b *testing.B, requests []workload.Request) {
+	cache := newCache(policy.NewLRU[string, string](policy.WithMaxSize(capacity)))
+	defer cache.Close()
+	batch := gencache.NewBatchCache(cache, gencache.DefaultBatchConfig())
+	ctx := context.Background()
+
+	// Pre-build the batches so the benchmark measures the cache only
+	var entries []map[string]string
+	var keys [][]string
+	for start := 0; start+batchSize <= len(requests); start += batchSize {
+		entry := make(map[string]string, batchSize)
+		batchKeys := make([]string, 0, batchSize)
+		for _, req := range requests[start : start+batchSize] {
+			entry[req.Key] = req.Key
+			batchKeys = append(batchKeys, req.Key)
+		}
+		entries = append(entries, entry)
+		keys = append(keys, batchKeys)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := i % len(entries)
+		if err := batch.SetMany(ctx, entries[n], entryTTL); err != nil {
+			b.Fatal(err)
+		}
+		batch.GetMany(ctx, keys[n])
+	}
+	b.ReportMetric(batchSize, "keys/batch")
+}
+
+// benchmarkFileStore replays requests against a cache backed by a file store
+func benchmarkFileStore(b *testing.B, requests []workload.Request) {
+	ctx := context.Background()
+	dir, err := os.MkdirTemp("", "gencache-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileStore, err := store.NewFileStore[string, string](ctx, &store.FileConfig{
+		Directory:       dir,
+		FileExtension:   ".cache",
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer fileStore.Close(ctx)
+
+	cache := gencache.New[string, string](
+		gencache.WithMaxSize[string, string](capacity),
+		gencache.WithStore[string, string](fileStore),
+	)
+	benchmarkCache(b, cache, requests)
+}
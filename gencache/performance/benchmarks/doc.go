@@ -0,0 +1,12 @@
+// Package benchmarks measures gencache under the synthetic workloads of
+// pkg/workload. It covers each built-in eviction policy, the BatchCache and
+// the file store, and reports the hit ratio next to the timings. Each cache
+// is warmed with a fixed number of requests before timing starts, and the
+// hit ratio covers the timed requests only.
+//
+// Usage:
+//
+//	go test -bench . ./gencache/performance/benchmarks
+//	go test -bench 'Policies/.*/zipfian' -benchtime 200ms ./gencache/performance/benchmarks
+//	go test -bench . -count 10 ./gencache/performance/benchmarks > new.txt && benchstat old.txt new.txt
+package benchmarks
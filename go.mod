@@ -7,6 +7,7 @@ toolchain go1.23.9
 require (
 	github.com/gozephyr/cbreak v0.1.1
 	github.com/gozephyr/gencache v1.0.0
+	golang.org/x/term v0.29.0
 )
 
 require (
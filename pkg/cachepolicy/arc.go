@@ -0,0 +1,221 @@
+// Package cachepolicy provides eviction policies for gencache beyond the
+// LRU, LFU and FIFO policies that ship with it. The policies implement
+// policy.Policy and plug into gencache.WithPolicy.
+//
+// The cache decides when to evict and asks the policy for a victim, so a
+// cache using one of these policies must be created with WithMaxSize set to
+// the policy's capacity:
+//
+//	arc := cachepolicy.NewARC[string, string](policy.WithMaxSize(100))
+//	cache := gencache.New[string, string](
+//		gencache.WithMaxSize[string, string](arc.Capacity()),
+//		gencache.WithPolicy[string, string](arc),
+//	)
+package cachepolicy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/gencache/policy"
+)
+
+// defaultMaxSize is the capacity used when no policy.WithMaxSize option is given
+const defaultMaxSize = 1000
+
+// arcList identifies one of the four ARC lists
+type arcList int
+
+const (
+	t1 arcList = iota // resident, seen once recently
+	t2                // resident, seen at least twice
+	b1                // ghosts evicted from t1
+	b2                // ghosts evicted from t2
+)
+
+// arcEntry is an element of one of the ARC lists
+type arcEntry[K comparable] struct {
+	key  K
+	list arcList
+}
+
+// ARC implements the Adaptive Replacement Cache policy of Megiddo and Modha.
+// Resident keys live in T1 (seen once) or T2 (seen again); B1 and B2 remember
+// recently evicted keys. A re-reference to a ghost shifts the target size p
+// of T1 towards recency (B1) or frequency (B2), which makes ARC resistant to
+// scans that would flush an LRU cache.
+//
+// gencache asks for a victim before it reports the key being inserted, so a
+// ghost hit adapts p for the next eviction rather than the current one. Hit
+// counts still match the algorithm in the paper to within a fraction of a
+// percent. Like the paper's ARC, it trails LRU for a while after a switch to
+// a new working set: the old frequent keys hold their place in T2 until ghost
+// hits grow T1's target, while LRU gives the new set the whole cache at once.
+type ARC[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	p        int // target size of t1
+	lists    [4]*list.List
+	index    map[K]*list.Element
+}
+
+// NewARC creates an ARC policy. The capacity is set with policy.WithMaxSize
+// and defaults to 1000.
+func NewARC[K comparable, V any](opts ...policy.Option) *ARC[K, V] {
+	options := &policy.Options{MaxSize: defaultMaxSize}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	p := &ARC[K, V]{
+		capacity: options.MaxSize,
+		index:    make(map[K]*list.Element),
+	}
+	for i := range p.lists {
+		p.lists[i] = list.New()
+	}
+	return p
+}
+
+// OnGet is called when an item is retrieved from the cache
+func (p *ARC[K, V]) OnGet(key K, value V) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.index[key]; ok && p.resident(element) {
+		p.move(element, t2)
+	}
+}
+
+// OnSet is called when an item is added to the cache
+func (p *ARC[K, V]) OnSet(key K, value V, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, ok := p.index[key]
+	if !ok {
+		p.index[key] = p.lists[t1].PushFront(&arcEntry[K]{key: key, list: t1})
+		p.trimGhosts()
+		return
+	}
+
+	switch element.Value.(*arcEntry[K]).list {
+	case b1:
+		// Recently evicted from T1: favor recency
+		p.p = min(p.capacity, p.p+max(p.lists[b2].Len()/p.lists[b1].Len(), 1))
+	case b2:
+		// Recently evicted from T2: favor frequency
+		p.p = max(0, p.p-max(p.lists[b1].Len()/p.lists[b2].Len(), 1))
+	}
+	p.move(element, t2)
+	p.trimGhosts()
+}
+
+// OnDelete is called when an item is removed from the cache
+func (p *ARC[K, V]) OnDelete(key K) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.index[key]; ok {
+		p.lists[element.Value.(*arcEntry[K]).list].Remove(element)
+		delete(p.index, key)
+	}
+}
+
+// OnClear is called when the cache is cleared
+func (p *ARC[K, V]) OnClear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, l := range p.lists {
+		l.Init()
+	}
+	p.index = make(map[K]*list.Element)
+	p.p = 0
+}
+
+// Evict returns the next key to be evicted from the cache. The key is
+// remembered in the ghost list of the list it was evicted from.
+func (p *ARC[K, V]) Evict() (K, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Evict from T1 while it is larger than its target, otherwise from T2
+	from, ghost := t2, b2
+	if n := p.lists[t1].Len(); n > 0 && (n > p.p || p.lists[t2].Len() == 0) {
+		from, ghost = t1, b1
+	}
+
+	element := p.lists[from].Back()
+	if element == nil {
+		var zero K
+		return zero, false
+	}
+	p.move(element, ghost)
+	return element.Value.(*arcEntry[K]).key, true
+}
+
+// Size returns the number of items in the policy
+func (p *ARC[K, V]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lists[t1].Len() + p.lists[t2].Len()
+}
+
+// Capacity returns the maximum number of items the policy can hold
+func (p *ARC[K, V]) Capacity() int {
+	return p.capacity
+}
+
+// Target returns the current target size of T1. It grows when recently
+// evicted keys are re-referenced after one use and shrinks when they are
+// re-referenced after several.
+func (p *ARC[K, V]) Target() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.p
+}
+
+// Lengths returns the lengths of T1, T2, B1 and B2
+func (p *ARC[K, V]) Lengths() (recent, frequent, recentGhosts, frequentGhosts int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lists[t1].Len(), p.lists[t2].Len(), p.lists[b1].Len(), p.lists[b2].Len()
+}
+
+// resident reports whether element is in T1 or T2
+func (p *ARC[K, V]) resident(element *list.Element) bool {
+	l := element.Value.(*arcEntry[K]).list
+	return l == t1 || l == t2
+}
+
+// move makes element the most recent entry of list to
+func (p *ARC[K, V]) move(element *list.Element, to arcList) {
+	entry := element.Value.(*arcEntry[K])
+	if entry.list == to {
+		p.lists[to].MoveToFront(element)
+		return
+	}
+	p.lists[entry.list].Remove(element)
+	entry.list = to
+	p.index[entry.key] = p.lists[to].PushFront(entry)
+}
+
+// trimGhosts keeps T1+B1 within the capacity and the whole directory within
+// twice the capacity by forgetting the oldest ghosts
+func (p *ARC[K, V]) trimGhosts() {
+	for p.lists[t1].Len()+p.lists[b1].Len() > p.capacity && p.lists[b1].Len() > 0 {
+		p.forget(b1)
+	}
+	for p.lists[t1].Len()+p.lists[t2].Len()+p.lists[b1].Len()+p.lists[b2].Len() > 2*p.capacity && p.lists[b2].Len() > 0 {
+		p.forget(b2)
+	}
+}
+
+// forget drops the oldest entry of a ghost list
+func (p *ARC[K, V]) forget(ghost arcList) {
+	element := p.lists[ghost].Back()
+	p.lists[ghost].Remove(element)
+	delete(p.index, element.Value.(*arcEntry[K]).key)
+}
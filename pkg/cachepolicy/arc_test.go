@@ -0,0 +1,323 @@
+package cachepolicy
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
+)
+
+// ARC must plug into gencache.WithPolicy
+var _ policy.Policy[string, string] = (*ARC[string, string])(nil)
+
+// newCache creates a cache bounded by the capacity of p
+func newCache(p policy.Policy[string, string]) gencache.Cache[string, string] {
+	return gencache.New[string, string](
+		gencache.WithMaxSize[string, string](p.Capacity()),
+		gencache.WithPolicy[string, string](p),
+	)
+}
+
+// replay reads every key of trace from cache, storing it on a miss, and
+// returns the number of hits
+func replay(t *testing.T, cache gencache.Cache[string, string], trace []string) int {
+	t.Helper()
+	hits := 0
+	for _, key := range trace {
+		if _, err := cache.Get(key); err == nil {
+			hits++
+			continue
+		}
+		if err := cache.Set(key, key, time.Minute); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+	return hits
+}
+
+// access drives p the way gencache does: a hit is reported with OnGet, a
+// miss asks for a victim when the cache is full and then reports the insert
+func access(p *ARC[string, string], key string) {
+	if element, ok := p.index[key]; ok && p.resident(element) {
+		p.OnGet(key, key)
+		return
+	}
+	if p.Size() >= p.Capacity() {
+		p.Evict()
+	}
+	p.OnSet(key, key, time.Minute)
+}
+
+// keys returns n keys named prefix0, prefix1...
+func keys(prefix string, n int) []string {
+	ks := make([]string, n)
+	for i := range ks {
+		ks[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return ks
+}
+
+// repeat concatenates rounds copies of ks
+func repeat(ks []string, rounds int) []string {
+	var trace []string
+	for i := 0; i < rounds; i++ {
+		trace = append(trace, ks...)
+	}
+	return trace
+}
+
+func TestARCScanResistance(t *testing.T) {
+	const capacity = 100
+	hot := keys("hot", capacity/2)
+	scan := keys("scan", 20*capacity)
+
+	arc := NewARC[string, string](policy.WithMaxSize(capacity))
+	arcCache := newCache(arc)
+	defer arcCache.Close()
+	lruCache := newCache(policy.NewLRU[string, string](policy.WithMaxSize(capacity)))
+	defer lruCache.Close()
+
+	// Reading the hot keys twice promotes them to T2
+	for _, cache := range []gencache.Cache[string, string]{arcCache, lruCache} {
+		replay(t, cache, repeat(hot, 2))
+	}
+
+	// A long scan of one-time keys, with a hot key read after every four
+	var trace []string
+	for i, key := range scan {
+		trace = append(trace, key)
+		if i%4 == 3 {
+			trace = append(trace, hot[(i/4)%len(hot)])
+		}
+	}
+	arcHits := replay(t, arcCache, trace)
+	lruHits := replay(t, lruCache, trace)
+
+	// Every hot read hits under ARC: the scan only churns T1
+	if want := len(scan) / 4; arcHits != want {
+		t.Errorf("ARC hits during the scan = %d, want %d", arcHits, want)
+	}
+	if arcHits <= lruHits {
+		t.Errorf("ARC hits = %d, LRU hits = %d; want ARC ahead", arcHits, lruHits)
+	}
+	for _, key := range hot {
+		if _, err := arcCache.Get(key); err != nil {
+			t.Fatalf("hot key %s was flushed by the scan", key)
+		}
+	}
+	if recent, frequent, _, _ := arc.Lengths(); frequent != len(hot) || recent != capacity-len(hot) {
+		t.Errorf("T1=%d T2=%d after the scan, want T1=%d T2=%d", recent, frequent, capacity-len(hot), len(hot))
+	}
+}
+
+func TestARCAdapts(t *testing.T) {
+	p := NewARC[string, string](policy.WithMaxSize(4))
+	for _, key := range []string{"a", "b", "c", "x", "x", "d"} {
+		access(p, key)
+	}
+	// d evicted a from T1 to B1; reading a again favors recency
+	if _, _, recentGhosts, _ := p.Lengths(); recentGhosts != 1 || p.Target() != 0 {
+		t.Fatalf("B1=%d target=%d before the ghost hit, want 1 and 0", recentGhosts, p.Target())
+	}
+	access(p, "a")
+	if target := p.Target(); target != 1 {
+		t.Fatalf("target after a B1 ghost hit = %d, want 1", target)
+	}
+	if recent, frequent, _, _ := p.Lengths(); recent != 2 || frequent != 2 {
+		t.Fatalf("T1=%d T2=%d, want 2 and 2 with the ghost promoted to T2", recent, frequent)
+	}
+
+	// Promote the rest of T1, then let e evict x from T2 to B2
+	for _, key := range []string{"c", "d", "e"} {
+		access(p, key)
+	}
+	if _, _, _, frequentGhosts := p.Lengths(); frequentGhosts != 1 {
+		t.Fatalf("B2=%d, want x evicted from T2", frequentGhosts)
+	}
+	// Reading x again favors frequency
+	access(p, "x")
+	if target := p.Target(); target != 0 {
+		t.Errorf("target after a B2 ghost hit = %d, want 0", target)
+	}
+}
+
+// TestARCWorkingSetSwitch moves from a protected hot set to a new working
+// set that fits the cache but not the room T2 leaves for it. ARC misses a
+// whole extra round before ghost hits grow T1's target; LRU pays nothing.
+// The reference implementation scores the same, so the lag is ARC's own and
+// not a consequence of gencache asking for a victim before the insert.
+func TestARCWorkingSetSwitch(t *testing.T) {
+	const capacity = 100
+	hot := keys("hot", capacity/2)
+	loop := keys("loop", capacity*3/4)
+
+	arc := NewARC[string, string](policy.WithMaxSize(capacity))
+	cache := newCache(arc)
+	defer cache.Close()
+	ref := newRefARC(capacity)
+
+	warmup := repeat(hot, 3)
+	replay(t, cache, warmup)
+	ref.replay(warmup)
+
+	for round := 1; round <= 5; round++ {
+		hits := replay(t, cache, loop)
+		if want := ref.replay(loop); hits != want {
+			t.Errorf("round %d: %d hits, reference ARC %d", round, hits, want)
+		}
+		// The first round is all cold misses and most of the second was
+		// evicted before its reuse; from the third on the loop is resident
+		if want := map[int]int{1: 0, 2: len(loop) / 3}[round]; round <= 2 && hits != want {
+			t.Errorf("round %d: %d hits, want %d", round, hits, want)
+		} else if round > 2 && hits != len(loop) {
+			t.Errorf("round %d: %d hits, want all %d", round, hits, len(loop))
+		}
+	}
+	if target := arc.Target(); target == 0 {
+		t.Error("target of T1 did not grow after the switch")
+	}
+}
+
+func TestARCMatchesReference(t *testing.T) {
+	const capacity = 64
+	for seed := int64(1); seed <= 5; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		zipf := rand.NewZipf(rng, 1.1, 1, 1000)
+
+		// A skewed working set mixed with one-time keys
+		trace := make([]string, 20000)
+		for i := range trace {
+			if rng.Intn(10) < 7 {
+				trace[i] = fmt.Sprintf("hot%d", zipf.Uint64())
+			} else {
+				trace[i] = fmt.Sprintf("once%d", i)
+			}
+		}
+
+		arc := NewARC[string, string](policy.WithMaxSize(capacity))
+		cache := newCache(arc)
+		hits := replay(t, cache, trace)
+		cache.Close()
+		want := newRefARC(capacity).replay(trace)
+
+		// gencache asks for a victim before reporting the insert, so a ghost
+		// hit adapts the target one eviction later than in the paper; the
+		// difference is expected to be tiny
+		if diff := hits - want; diff < -len(trace)/200 || diff > len(trace)/200 {
+			t.Errorf("seed %d: %d hits, reference ARC %d", seed, hits, want)
+		}
+		if size := arc.Size(); size != capacity {
+			t.Errorf("seed %d: policy tracks %d keys, want %d", seed, size, capacity)
+		}
+	}
+}
+
+func TestARCDeleteAndClear(t *testing.T) {
+	arc := NewARC[string, string](policy.WithMaxSize(4))
+	cache := newCache(arc)
+	defer cache.Close()
+
+	replay(t, cache, []string{"a", "b", "c", "a"})
+	if err := cache.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if recent, frequent, _, _ := arc.Lengths(); recent != 2 || frequent != 0 {
+		t.Errorf("T1=%d T2=%d after deleting a, want 2 and 0", recent, frequent)
+	}
+
+	// gencache.Clear does not notify the policy, so call OnClear directly
+	arc.OnClear()
+	if size, target := arc.Size(), arc.Target(); size != 0 || target != 0 {
+		t.Errorf("size=%d target=%d after Clear, want 0 and 0", size, target)
+	}
+}
+
+// refARC is a direct transcription of ARC(c) from Megiddo and Modha, "ARC: A
+// Self-Tuning, Low Overhead Replacement Cache" (FAST '03), used to check ARC.
+// Lists hold keys oldest first.
+type refARC struct {
+	c              int
+	p              int
+	t1, t2, b1, b2 []string
+}
+
+func newRefARC(c int) *refARC {
+	return &refARC{c: c}
+}
+
+// replay runs trace and returns the number of hits
+func (r *refARC) replay(trace []string) int {
+	hits := 0
+	for _, key := range trace {
+		if r.request(key) {
+			hits++
+		}
+	}
+	return hits
+}
+
+// request handles one reference to x and reports whether it was a hit
+func (r *refARC) request(x string) bool {
+	switch {
+	case remove(&r.t1, x), remove(&r.t2, x):
+		// Case I: a hit moves x to the MRU position of T2
+		r.t2 = append(r.t2, x)
+		return true
+	case slices.Contains(r.b1, x):
+		// Case II
+		r.p = min(r.c, r.p+max(len(r.b2)/len(r.b1), 1))
+		r.replace(false)
+		remove(&r.b1, x)
+		r.t2 = append(r.t2, x)
+		return false
+	case slices.Contains(r.b2, x):
+		// Case III
+		r.p = max(0, r.p-max(len(r.b1)/len(r.b2), 1))
+		r.replace(true)
+		remove(&r.b2, x)
+		r.t2 = append(r.t2, x)
+		return false
+	}
+
+	// Case IV: x is in none of the lists
+	if l1 := len(r.t1) + len(r.b1); l1 == r.c {
+		if len(r.t1) < r.c {
+			r.b1 = r.b1[1:]
+			r.replace(false)
+		} else {
+			r.t1 = r.t1[1:]
+		}
+	} else if total := l1 + len(r.t2) + len(r.b2); total >= r.c {
+		if total == 2*r.c {
+			r.b2 = r.b2[1:]
+		}
+		r.replace(false)
+	}
+	r.t1 = append(r.t1, x)
+	return false
+}
+
+// replace evicts the LRU page of T1 or T2 into the matching ghost list
+func (r *refARC) replace(inB2 bool) {
+	if len(r.t1) > 0 && (len(r.t1) > r.p || (inB2 && len(r.t1) == r.p)) {
+		r.b1 = append(r.b1, r.t1[0])
+		r.t1 = r.t1[1:]
+		return
+	}
+	r.b2 = append(r.b2, r.t2[0])
+	r.t2 = r.t2[1:]
+}
+
+// remove deletes x from l and reports whether it was there
+func remove(l *[]string, x string) bool {
+	i := slices.Index(*l, x)
+	if i < 0 {
+		return false
+	}
+	*l = slices.Delete(*l, i, i+1)
+	return true
+}
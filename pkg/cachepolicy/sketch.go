@@ -0,0 +1,163 @@
+package cachepolicy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+)
+
+const (
+	sketchDepth    = 4  // rows of the count-min sketch
+	maxCount       = 15 // counters saturate like the 4-bit counters of TinyLFU
+	sampleFactor   = 10 // the sketch ages after sampleFactor*capacity increments
+	doorkeeperBits = 4  // bloom filter bits per cached entry
+)
+
+// frequencySketch estimates how often keys were seen recently. A doorkeeper
+// bloom filter absorbs the first occurrence of every key so one-hit wonders
+// never reach the count-min sketch. After a sample of increments the counters
+// are halved and the doorkeeper is cleared, so old popularity fades.
+type frequencySketch struct {
+	counters   []uint8
+	width      uint64 // columns per row, a power of two
+	doorkeeper []uint64
+	doorMask   uint64
+	additions  int
+	sampleSize int
+}
+
+// newFrequencySketch sizes a sketch for a cache holding capacity entries
+func newFrequencySketch(capacity int) *frequencySketch {
+	width := nextPowerOfTwo(max(capacity, 16))
+	doorBits := nextPowerOfTwo(max(capacity*doorkeeperBits, 64))
+	return &frequencySketch{
+		counters:   make([]uint8, sketchDepth*width),
+		width:      width,
+		doorkeeper: make([]uint64, doorBits/64),
+		doorMask:   doorBits - 1,
+		sampleSize: max(capacity, 1) * sampleFactor,
+	}
+}
+
+// increment records one occurrence of the key with hash h
+func (s *frequencySketch) increment(h uint64) {
+	if !s.doorkeeperContains(h) {
+		s.doorkeeperAdd(h)
+	} else {
+		for row := uint64(0); row < sketchDepth; row++ {
+			if i := s.index(h, row); s.counters[i] < maxCount {
+				s.counters[i]++
+			}
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.age()
+	}
+}
+
+// estimate returns the approximate recent frequency of the key with hash h
+func (s *frequencySketch) estimate(h uint64) int {
+	count := uint8(maxCount)
+	for row := uint64(0); row < sketchDepth; row++ {
+		count = min(count, s.counters[s.index(h, row)])
+	}
+	if s.doorkeeperContains(h) {
+		return int(count) + 1
+	}
+	return int(count)
+}
+
+// age halves every counter and clears the doorkeeper
+func (s *frequencySketch) age() {
+	for i := range s.counters {
+		s.counters[i] /= 2
+	}
+	clear(s.doorkeeper)
+	s.additions /= 2
+}
+
+// reset forgets every recorded occurrence
+func (s *frequencySketch) reset() {
+	clear(s.counters)
+	clear(s.doorkeeper)
+	s.additions = 0
+}
+
+// index returns the counter used for h in row, deriving one hash per row
+// from the two halves of h
+func (s *frequencySketch) index(h, row uint64) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return row*s.width + (h1+row*h2)&(s.width-1)
+}
+
+// doorkeeperContains reports whether h was added since the last aging
+func (s *frequencySketch) doorkeeperContains(h uint64) bool {
+	for _, bit := range s.doorkeeperBits(h) {
+		if s.doorkeeper[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// doorkeeperAdd adds h to the doorkeeper
+func (s *frequencySketch) doorkeeperAdd(h uint64) {
+	for _, bit := range s.doorkeeperBits(h) {
+		s.doorkeeper[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// doorkeeperBits returns the bloom filter bits for h
+func (s *frequencySketch) doorkeeperBits(h uint64) [2]uint64 {
+	return [2]uint64{h & s.doorMask, bits.RotateLeft64(h, 32) & s.doorMask}
+}
+
+// hashKey returns a stable 64-bit hash of key. Strings and integers are
+// hashed directly. Other key types are formatted with fmt.Fprint, which
+// allocates on every access and makes keys that print alike share a hash;
+// such keys still work, but give the sketch a cost and error it would not
+// have with a string or integer key.
+func hashKey[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case int:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case string:
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		return mix64(h.Sum64())
+	default:
+		h := fnv.New64a()
+		fmt.Fprint(h, key)
+		return mix64(h.Sum64())
+	}
+}
+
+// mix64 spreads the bits of x (the splitmix64 finalizer)
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// nextPowerOfTwo returns the smallest power of two not below n
+func nextPowerOfTwo(n int) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(uint64(n-1))
+}
@@ -0,0 +1,93 @@
+package cachepolicy
+
+import "testing"
+
+func TestSketchSaturates(t *testing.T) {
+	s := newFrequencySketch(1000)
+	h := hashKey("hot")
+	for i := 0; i < 100; i++ {
+		s.increment(h)
+	}
+	// 15 from the counters plus one for the doorkeeper
+	if got := s.estimate(h); got != maxCount+1 {
+		t.Errorf("estimate after 100 increments = %d, want %d", got, maxCount+1)
+	}
+	for i, c := range s.counters {
+		if c > maxCount {
+			t.Fatalf("counter %d = %d, above %d", i, c, maxCount)
+		}
+	}
+}
+
+func TestSketchDoorkeeperAbsorbsFirstOccurrence(t *testing.T) {
+	s := newFrequencySketch(1000)
+	h := hashKey("once")
+	s.increment(h)
+	if got := s.estimate(h); got != 1 {
+		t.Errorf("estimate after one increment = %d, want 1", got)
+	}
+	for i, c := range s.counters {
+		if c != 0 {
+			t.Fatalf("counter %d = %d, want the first occurrence kept out of the sketch", i, c)
+		}
+	}
+}
+
+func TestSketchAges(t *testing.T) {
+	const capacity = 16
+	s := newFrequencySketch(capacity)
+	h := hashKey("hot")
+
+	// The sample is 10x capacity increments; stop one short of it
+	for i := 0; i < sampleFactor*capacity-1; i++ {
+		s.increment(h)
+	}
+	if got := s.estimate(h); got != maxCount+1 {
+		t.Fatalf("estimate before aging = %d, want %d", got, maxCount+1)
+	}
+
+	s.increment(h)
+	if s.doorkeeperContains(h) {
+		t.Error("doorkeeper not cleared by aging")
+	}
+	if got := s.estimate(h); got != maxCount/2 {
+		t.Errorf("estimate after aging = %d, want the counters halved to %d", got, maxCount/2)
+	}
+	if s.additions != sampleFactor*capacity/2 {
+		t.Errorf("additions = %d after aging, want %d", s.additions, sampleFactor*capacity/2)
+	}
+}
+
+func TestSketchReset(t *testing.T) {
+	s := newFrequencySketch(16)
+	h := hashKey(42)
+	for i := 0; i < 5; i++ {
+		s.increment(h)
+	}
+	s.reset()
+	if got := s.estimate(h); got != 0 {
+		t.Errorf("estimate after reset = %d, want 0", got)
+	}
+}
+
+func TestHashKeyStable(t *testing.T) {
+	type point struct{ x, y int }
+	if hashKey("a") != hashKey("a") || hashKey(point{1, 2}) != hashKey(point{1, 2}) {
+		t.Error("equal keys hashed differently")
+	}
+	if hashKey("a") == hashKey("b") || hashKey(1) == hashKey(2) {
+		t.Error("distinct keys share a hash")
+	}
+}
+
+func TestHashKeyAllocations(t *testing.T) {
+	for name, hash := range map[string]func(){
+		"string": func() { hashKey("key") },
+		"int":    func() { hashKey(42) },
+		"uint32": func() { hashKey(uint32(42)) },
+	} {
+		if n := testing.AllocsPerRun(100, hash); n != 0 {
+			t.Errorf("hashing a %s key allocates %v times", name, n)
+		}
+	}
+}
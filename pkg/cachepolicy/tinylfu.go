@@ -0,0 +1,215 @@
+package cachepolicy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/gencache/policy"
+)
+
+// tinyLFUSegment identifies one of the W-TinyLFU segments
+type tinyLFUSegment int
+
+const (
+	window    tinyLFUSegment = iota // admission window, LRU
+	probation                       // main segment, entries seen once in main
+	protected                       // main segment, entries seen again in main
+)
+
+const (
+	windowPercent    = 1  // share of the capacity given to the window
+	protectedPercent = 80 // share of the main segment given to protected entries
+)
+
+// tinyLFUEntry is an element of one of the segments
+type tinyLFUEntry[K comparable] struct {
+	key     K
+	hash    uint64
+	segment tinyLFUSegment
+}
+
+// WTinyLFU implements the W-TinyLFU policy. New keys enter a small LRU
+// window. When the window overflows, its oldest key competes with the oldest
+// key of the main segment, and the one with the higher estimated frequency
+// stays. The main segment is a segmented LRU split into probation and
+// protected parts. Frequencies come from a count-min sketch behind a
+// doorkeeper bloom filter, both aged periodically.
+type WTinyLFU[K comparable, V any] struct {
+	mu           sync.Mutex
+	capacity     int
+	windowCap    int
+	protectedCap int
+	segments     [3]*list.List
+	index        map[K]*list.Element
+	sketch       *frequencySketch
+}
+
+// NewWTinyLFU creates a W-TinyLFU policy. The capacity is set with
+// policy.WithMaxSize and defaults to 1000. Keys are hashed for the sketch
+// on every access; string and integer keys hash without allocating.
+func NewWTinyLFU[K comparable, V any](opts ...policy.Option) *WTinyLFU[K, V] {
+	options := &policy.Options{MaxSize: defaultMaxSize}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	capacity := max(options.MaxSize, 1)
+	windowCap := max(capacity*windowPercent/100, 1)
+	p := &WTinyLFU[K, V]{
+		capacity:     capacity,
+		windowCap:    windowCap,
+		protectedCap: (capacity - windowCap) * protectedPercent / 100,
+		index:        make(map[K]*list.Element),
+		sketch:       newFrequencySketch(capacity),
+	}
+	for i := range p.segments {
+		p.segments[i] = list.New()
+	}
+	return p
+}
+
+// OnGet is called when an item is retrieved from the cache
+func (p *WTinyLFU[K, V]) OnGet(key K, value V) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.index[key]; ok {
+		p.access(element)
+	}
+}
+
+// OnSet is called when an item is added to the cache
+func (p *WTinyLFU[K, V]) OnSet(key K, value V, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.index[key]; ok {
+		p.access(element)
+		return
+	}
+
+	entry := &tinyLFUEntry[K]{key: key, hash: hashKey(key), segment: window}
+	p.sketch.increment(entry.hash)
+	p.index[key] = p.segments[window].PushFront(entry)
+
+	// While the cache fills up, keys leaving the window move to main unopposed
+	for p.segments[window].Len() > p.windowCap &&
+		p.segments[probation].Len()+p.segments[protected].Len() < p.capacity-p.windowCap {
+		p.move(p.segments[window].Back(), probation)
+	}
+}
+
+// OnDelete is called when an item is removed from the cache
+func (p *WTinyLFU[K, V]) OnDelete(key K) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.index[key]; ok {
+		p.segments[element.Value.(*tinyLFUEntry[K]).segment].Remove(element)
+		delete(p.index, key)
+	}
+}
+
+// OnClear is called when the cache is cleared
+func (p *WTinyLFU[K, V]) OnClear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, l := range p.segments {
+		l.Init()
+	}
+	p.index = make(map[K]*list.Element)
+	p.sketch.reset()
+}
+
+// Evict returns the next key to be evicted from the cache. gencache calls it
+// just before inserting a new key, so a full window means the window is about
+// to overflow and its oldest key has to win admission to the main segment.
+func (p *WTinyLFU[K, V]) Evict() (K, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var victim *list.Element
+	if p.segments[window].Len() >= p.windowCap {
+		candidate := p.segments[window].Back()
+		victim = p.mainVictim()
+		if victim != nil && p.frequency(candidate) > p.frequency(victim) {
+			// The candidate is admitted to probation and the main victim goes
+			p.move(candidate, probation)
+		} else {
+			victim = candidate
+		}
+	} else {
+		victim = p.mainVictim()
+	}
+
+	if victim == nil {
+		var zero K
+		return zero, false
+	}
+	entry := victim.Value.(*tinyLFUEntry[K])
+	p.segments[entry.segment].Remove(victim)
+	delete(p.index, entry.key)
+	return entry.key, true
+}
+
+// Size returns the number of items in the policy
+func (p *WTinyLFU[K, V]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.index)
+}
+
+// Capacity returns the maximum number of items the policy can hold
+func (p *WTinyLFU[K, V]) Capacity() int {
+	return p.capacity
+}
+
+// Lengths returns the number of keys in the window, probation and protected segments
+func (p *WTinyLFU[K, V]) Lengths() (windowLen, probationLen, protectedLen int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.segments[window].Len(), p.segments[probation].Len(), p.segments[protected].Len()
+}
+
+// access records a hit on element and updates its position
+func (p *WTinyLFU[K, V]) access(element *list.Element) {
+	entry := element.Value.(*tinyLFUEntry[K])
+	p.sketch.increment(entry.hash)
+
+	switch entry.segment {
+	case window, protected:
+		p.segments[entry.segment].MoveToFront(element)
+	case probation:
+		// A second hit in main promotes the key, demoting the oldest protected key if needed
+		p.move(element, protected)
+		if p.segments[protected].Len() > p.protectedCap {
+			p.move(p.segments[protected].Back(), probation)
+		}
+	}
+}
+
+// mainVictim returns the oldest key of the main segment, preferring probation
+func (p *WTinyLFU[K, V]) mainVictim() *list.Element {
+	if victim := p.segments[probation].Back(); victim != nil {
+		return victim
+	}
+	if victim := p.segments[protected].Back(); victim != nil {
+		return victim
+	}
+	return p.segments[window].Back()
+}
+
+// frequency returns the estimated recent frequency of the key held by element
+func (p *WTinyLFU[K, V]) frequency(element *list.Element) int {
+	return p.sketch.estimate(element.Value.(*tinyLFUEntry[K]).hash)
+}
+
+// move makes element the most recent entry of segment to
+func (p *WTinyLFU[K, V]) move(element *list.Element, to tinyLFUSegment) {
+	entry := element.Value.(*tinyLFUEntry[K])
+	p.segments[entry.segment].Remove(element)
+	entry.segment = to
+	p.index[entry.key] = p.segments[to].PushFront(entry)
+}
@@ -0,0 +1,162 @@
+package cachepolicy
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/gencache/policy"
+)
+
+// WTinyLFU must plug into gencache.WithPolicy
+var _ policy.Policy[string, string] = (*WTinyLFU[string, string])(nil)
+
+// accessTinyLFU drives p the way gencache does and returns the evicted key,
+// if any
+func accessTinyLFU(p *WTinyLFU[string, string], key string) (string, bool) {
+	if _, ok := p.index[key]; ok {
+		p.OnGet(key, key)
+		return "", false
+	}
+	var evicted string
+	var ok bool
+	if p.Size() >= p.Capacity() {
+		evicted, ok = p.Evict()
+	}
+	p.OnSet(key, key, time.Minute)
+	return evicted, ok
+}
+
+// segmentOf returns the segment holding key
+func segmentOf(t *testing.T, p *WTinyLFU[string, string], key string) tinyLFUSegment {
+	t.Helper()
+	element, ok := p.index[key]
+	if !ok {
+		t.Fatalf("%s not held", key)
+	}
+	return element.Value.(*tinyLFUEntry[string]).segment
+}
+
+// filledTinyLFU returns a policy of capacity 100 holding k0..k99: k99 in
+// the window and the rest in probation, k0 the oldest
+func filledTinyLFU(t *testing.T) *WTinyLFU[string, string] {
+	t.Helper()
+	p := NewWTinyLFU[string, string](policy.WithMaxSize(100))
+	for _, key := range keys("k", 100) {
+		if evicted, ok := accessTinyLFU(p, key); ok {
+			t.Fatalf("%s evicted while filling", evicted)
+		}
+	}
+	if w, prob, prot := p.Lengths(); w != 1 || prob != 99 || prot != 0 {
+		t.Fatalf("segments %d/%d/%d after filling, want 1/99/0", w, prob, prot)
+	}
+	return p
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	p := filledTinyLFU(t)
+
+	// k99 leaves the window no more popular than k0, the main victim, and is rejected
+	if evicted, _ := accessTinyLFU(p, "new1"); evicted != "k99" {
+		t.Errorf("evicted %q, want the cold candidate k99", evicted)
+	}
+	if segmentOf(t, p, "k0") != probation {
+		t.Error("main victim k0 lost its place to a colder candidate")
+	}
+
+	// new1 is read until it is hotter than k0, so it wins admission
+	for i := 0; i < 3; i++ {
+		accessTinyLFU(p, "new1")
+	}
+	if evicted, _ := accessTinyLFU(p, "new2"); evicted != "k0" {
+		t.Errorf("evicted %q, want the main victim k0", evicted)
+	}
+	if segmentOf(t, p, "new1") != probation {
+		t.Error("hot candidate new1 not admitted to probation")
+	}
+	if segmentOf(t, p, "new2") != window {
+		t.Error("new2 not in the window")
+	}
+}
+
+func TestTinyLFUProtectedOverflowDemotes(t *testing.T) {
+	p := filledTinyLFU(t)
+	protectedCap := p.protectedCap
+	if protectedCap != 79 {
+		t.Fatalf("protected capacity = %d, want 79", protectedCap)
+	}
+
+	// A second hit promotes probation keys until protected overflows
+	for _, key := range keys("k", protectedCap+1) {
+		accessTinyLFU(p, key)
+	}
+	if w, prob, prot := p.Lengths(); w != 1 || prot != protectedCap || prob != 99-protectedCap {
+		t.Errorf("segments %d/%d/%d, want 1/%d/%d", w, prob, prot, 99-protectedCap, protectedCap)
+	}
+	// k0 was promoted first, so it is the one demoted, to the front of probation
+	if segmentOf(t, p, "k0") != probation {
+		t.Error("oldest protected key k0 not demoted to probation")
+	}
+	if front := p.segments[probation].Front().Value.(*tinyLFUEntry[string]).key; front != "k0" {
+		t.Errorf("front of probation = %s, want the demoted k0", front)
+	}
+	if segmentOf(t, p, "k79") != protected {
+		t.Error("k79 not promoted")
+	}
+}
+
+func TestTinyLFUSizeMatchesKeys(t *testing.T) {
+	const capacity = 50
+	p := NewWTinyLFU[string, string](policy.WithMaxSize(capacity))
+	held := make(map[string]bool)
+	rng := rand.New(rand.NewSource(1))
+	universe := keys("k", 4*capacity)
+
+	for i := 0; i < 10000; i++ {
+		key := universe[rng.Intn(len(universe))]
+		if rng.Intn(10) == 0 {
+			p.OnDelete(key)
+			delete(held, key)
+		} else {
+			if evicted, ok := accessTinyLFU(p, key); ok {
+				delete(held, evicted)
+			}
+			held[key] = true
+		}
+
+		w, prob, prot := p.Lengths()
+		if size := p.Size(); size != len(held) || size != w+prob+prot || size > capacity {
+			t.Fatalf("step %d: Size() = %d, segments %d/%d/%d, %d keys held, capacity %d",
+				i, size, w, prob, prot, len(held), capacity)
+		}
+	}
+	for key := range held {
+		if _, ok := p.index[key]; !ok {
+			t.Fatalf("%s held by the cache but unknown to the policy", key)
+		}
+	}
+
+	p.OnClear()
+	if size := p.Size(); size != 0 {
+		t.Errorf("Size() = %d after OnClear", size)
+	}
+}
+
+func TestTinyLFUWithCache(t *testing.T) {
+	const capacity = 100
+	p := NewWTinyLFU[string, string](policy.WithMaxSize(capacity))
+	cache := newCache(p)
+	defer cache.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.1, 1, 10*capacity)
+	trace := make([]string, 20000)
+	for i := range trace {
+		trace[i] = fmt.Sprintf("k%d", zipf.Uint64())
+	}
+	replay(t, cache, trace)
+	if size, cacheSize := p.Size(), int(cache.Stats().Size.Load()); size != cacheSize || size > capacity {
+		t.Errorf("policy holds %d keys, cache %d, capacity %d", size, cacheSize, capacity)
+	}
+}
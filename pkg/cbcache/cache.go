@@ -0,0 +1,235 @@
+// Package cbcache puts a gencache cache in front of a loader protected by a
+// cbreak circuit breaker.
+//
+// Values are loaded through the breaker and kept in the cache as the last
+// known good value of their key. While a value is younger than its TTL it is
+// served from the cache. Once it is older, Get calls the loader again; if the
+// breaker rejects the call or the loader fails, the last known good value is
+// served marked as stale instead of returning the error. Calls let through
+// while the breaker is Half-Open refresh the cache, so the first successful
+// probe after an outage brings the value up to date.
+//
+// Concurrent Gets that need to load the same key share one call through the
+// breaker, so a burst of requests for an expired key reaches the backend,
+// and counts against the breaker, once.
+//
+// Example usage:
+//
+//	breaker, _ := cbreak.NewBreaker[string](cbreak.DefaultConfig("prices"))
+//	prices := cbcache.New(gencache.New[string, cbcache.Entry[string]](), breaker, loadPrice)
+//	result, err := prices.Get(ctx, "btc")
+//	if err == nil && result.Stale {
+//	    // the backend is unhealthy, result.Value is the last known price
+//	}
+package cbcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+// Loader fetches the current value of a key
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Entry is a last known good value and the time it was loaded
+type Entry[V any] struct {
+	Value  V
+	Loaded time.Time
+}
+
+// Result is a value returned by Get
+type Result[V any] struct {
+	Value V
+	Stale bool          // the value is older than the TTL and could not be refreshed
+	Age   time.Duration // time since the value was loaded
+	Cause error         // why a stale value was served instead of a fresh one
+}
+
+// Metrics counts how Get requests were served
+type Metrics struct {
+	Hits     int64 // values served from the cache within their TTL
+	Loads    int64 // values loaded through the breaker
+	Stale    int64 // stale values served because the breaker or loader failed
+	Failures int64 // requests that failed with no value to fall back on
+}
+
+// metrics holds the live counters behind Metrics
+type metrics struct {
+	hits, loads, stale, failures atomic.Int64
+}
+
+// flight is a load in progress shared by every caller of the key
+type flight[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int // callers that joined the load
+}
+
+// Cache serves values loaded through a circuit breaker, falling back to the
+// last known good value when the breaker is open. It is safe for concurrent
+// use.
+type Cache[K comparable, V any] struct {
+	cache     gencache.Cache[K, Entry[V]]
+	breaker   *cbreak.Breaker[V]
+	load      Loader[K, V]
+	ttl       time.Duration
+	retention time.Duration
+	clock     clock.Clock
+	metrics   metrics
+
+	mu      sync.Mutex
+	flights map[K]*flight[V]
+}
+
+// Option configures a Cache
+type Option func(*options)
+
+type options struct {
+	ttl       time.Duration
+	retention time.Duration
+	clock     clock.Clock
+}
+
+// WithTTL sets how long a loaded value is served without calling the loader
+// (defaults to 1m)
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithRetention sets how long the last known good value is kept to fall back
+// on (defaults to 24h, the longest TTL gencache accepts). It is never shorter
+// than the TTL.
+func WithRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// WithClock sets the clock used to age cached values
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New wraps load with breaker and caches its results in cache
+func New[K comparable, V any](cache gencache.Cache[K, Entry[V]], breaker *cbreak.Breaker[V], load Loader[K, V], opts ...Option) *Cache[K, V] {
+	o := options{
+		ttl:       time.Minute,
+		retention: 24 * time.Hour,
+		clock:     clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Cache[K, V]{
+		cache:     cache,
+		breaker:   breaker,
+		load:      load,
+		ttl:       o.ttl,
+		retention: max(o.retention, o.ttl),
+		clock:     o.clock,
+		flights:   make(map[K]*flight[V]),
+	}
+}
+
+// Get returns the value of key. A value younger than the TTL is served from
+// the cache. Otherwise the value is loaded through the breaker; if that
+// fails and a last known good value is cached, it is returned with Stale set
+// and a nil error. Get only returns an error when there is nothing to fall
+// back on; errors.Is(err, cbreak.ErrCircuitOpen) reports a rejected call.
+//
+// If a load of key is already running, Get waits for it instead of starting
+// another. A caller whose ctx is done stops waiting, and is served the last
+// known good value or ctx.Err(), while the load carries on for the others.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (Result[V], error) {
+	now := c.clock.Now()
+	entry, lookupErr := c.cache.GetWithContext(ctx, key)
+	cached := lookupErr == nil
+	if cached && now.Sub(entry.Loaded) < c.ttl {
+		c.metrics.hits.Add(1)
+		return Result[V]{Value: entry.Value, Age: now.Sub(entry.Loaded)}, nil
+	}
+
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		f = c.startLoad(ctx, key)
+	}
+	f.waiters++
+	c.mu.Unlock()
+
+	var err error
+	select {
+	case <-f.done:
+		if err = f.err; err == nil {
+			return Result[V]{Value: f.value}, nil
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if !cached {
+		c.metrics.failures.Add(1)
+		return Result[V]{}, err
+	}
+	c.metrics.stale.Add(1)
+	return Result[V]{Value: entry.Value, Stale: true, Age: now.Sub(entry.Loaded), Cause: err}, nil
+}
+
+// startLoad loads key through the breaker in the background and caches the
+// result. The load keeps the values of ctx but not its cancellation, since
+// other callers may be waiting for it; the breaker's CommandTimeout bounds
+// it instead. It must be called with c.mu held.
+func (c *Cache[K, V]) startLoad(ctx context.Context, key K) *flight[V] {
+	f := &flight[V]{done: make(chan struct{})}
+	c.flights[key] = f
+	loadCtx := context.WithoutCancel(ctx)
+
+	go func() {
+		f.value, f.err = c.breaker.Execute(loadCtx, func() (V, error) {
+			return c.load(loadCtx, key)
+		})
+		if f.err == nil {
+			c.metrics.loads.Add(1)
+			// A value the cache refuses is still returned, it just cannot be fallen back on
+			_ = c.cache.Set(key, Entry[V]{Value: f.value, Loaded: c.clock.Now()}, c.retention)
+		}
+
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+// Invalidate removes the last known good value of key
+func (c *Cache[K, V]) Invalidate(key K) error {
+	return c.cache.Delete(key)
+}
+
+// Breaker returns the breaker protecting the loader
+func (c *Cache[K, V]) Breaker() *cbreak.Breaker[V] {
+	return c.breaker
+}
+
+// Metrics returns a snapshot of the counters
+func (c *Cache[K, V]) Metrics() Metrics {
+	return Metrics{
+		Hits:     c.metrics.hits.Load(),
+		Loads:    c.metrics.loads.Load(),
+		Stale:    c.metrics.stale.Load(),
+		Failures: c.metrics.failures.Load(),
+	}
+}
@@ -0,0 +1,203 @@
+package cbcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+var errDown = errors.New("backend down")
+
+// backend is a loader the test can switch into failing, counting its calls
+type backend struct {
+	calls   atomic.Int64
+	fail    atomic.Bool
+	release chan struct{} // when not nil, each call waits for it
+}
+
+func (b *backend) load(ctx context.Context, key string) (string, error) {
+	n := b.calls.Add(1)
+	if b.release != nil {
+		<-b.release
+	}
+	if b.fail.Load() {
+		return "", errDown
+	}
+	return fmt.Sprintf("%s v%d", key, n), nil
+}
+
+// newCache returns a cache with a 1m TTL on a fake clock, behind a breaker
+// that opens after two failures and stays open for a minute
+func newCache(t *testing.T) (*Cache[string, string], *backend, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(clock.Epoch)
+	config := cbreak.DefaultConfig("test")
+	config.FailureThreshold = 2
+	config.Timeout = time.Minute
+	breaker, err := clock.NewBreaker[string](clk, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := gencache.New[string, Entry[string]]()
+	t.Cleanup(func() { inner.Close() })
+
+	b := &backend{}
+	return New(inner, breaker, b.load, WithTTL(time.Minute), WithClock(clk)), b, clk
+}
+
+func TestServesStaleWhileOpen(t *testing.T) {
+	c, b, clk := newCache(t)
+	ctx := context.Background()
+
+	if r, err := c.Get(ctx, "k"); err != nil || r.Value != "k v1" || r.Stale {
+		t.Fatalf("first Get = %+v, %v", r, err)
+	}
+	if r, _ := c.Get(ctx, "k"); r.Value != "k v1" || b.calls.Load() != 1 {
+		t.Fatalf("Get within the TTL = %+v after %d loads", r, b.calls.Load())
+	}
+
+	b.fail.Store(true)
+	clk.Advance(2 * time.Minute)
+	// Two failures open the breaker; both serve the last good value
+	for i := 0; i < 2; i++ {
+		r, err := c.Get(ctx, "k")
+		if err != nil || r.Value != "k v1" || !r.Stale || !errors.Is(r.Cause, errDown) {
+			t.Fatalf("Get %d during the outage = %+v, %v; want stale v1 caused by the loader", i, r, err)
+		}
+	}
+	if state := c.Breaker().GetState(); state != cbreak.Open {
+		t.Fatalf("breaker is %s, want open", state)
+	}
+
+	r, err := c.Get(ctx, "k")
+	if err != nil || r.Value != "k v1" || !r.Stale || !errors.Is(r.Cause, cbreak.ErrCircuitOpen) {
+		t.Errorf("Get while open = %+v, %v; want stale v1 caused by the open circuit", r, err)
+	}
+	if r.Age != 2*time.Minute {
+		t.Errorf("Age = %v, want 2m", r.Age)
+	}
+	if n := b.calls.Load(); n != 3 {
+		t.Errorf("loader called %d times, want none while open", n)
+	}
+
+	// The first probe after the breaker timeout refreshes the value
+	b.fail.Store(false)
+	clk.Advance(time.Minute)
+	if r, err := c.Get(ctx, "k"); err != nil || r.Stale || r.Value != "k v4" {
+		t.Errorf("Get after recovery = %+v, %v; want a fresh value", r, err)
+	}
+	if m := c.Metrics(); m.Loads != 2 || m.Stale != 3 || m.Hits != 1 || m.Failures != 0 {
+		t.Errorf("metrics = %+v", m)
+	}
+}
+
+func TestMissWhileOpenFails(t *testing.T) {
+	c, b, _ := newCache(t)
+	ctx := context.Background()
+	b.fail.Store(true)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(ctx, "a"); !errors.Is(err, errDown) {
+			t.Fatalf("Get %d: err = %v, want the loader error", i, err)
+		}
+	}
+	_, err := c.Get(ctx, "b")
+	if !errors.Is(err, cbreak.ErrCircuitOpen) {
+		t.Errorf("err = %v, want cbreak.ErrCircuitOpen with nothing cached", err)
+	}
+	if m := c.Metrics(); m.Failures != 3 || m.Stale != 0 {
+		t.Errorf("metrics = %+v, want 3 failures", m)
+	}
+}
+
+func TestConcurrentLoadsShareOneCall(t *testing.T) {
+	const callers = 20
+	c, b, clk := newCache(t)
+	b.release = make(chan struct{})
+
+	// Each key gets one load, whether missing or expired
+	for round := 0; round < 2; round++ {
+		var wg sync.WaitGroup
+		results := make(chan string, 2*callers)
+		for _, key := range []string{"x", "y"} {
+			for i := 0; i < callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r, err := c.Get(context.Background(), key)
+					if err != nil || r.Stale {
+						t.Errorf("Get %s = %+v, %v", key, r, err)
+					}
+					results <- r.Value
+				}()
+			}
+		}
+		waitFor(t, "every caller to join a load", func() bool {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			x, y := c.flights["x"], c.flights["y"]
+			return x != nil && y != nil && x.waiters == callers && y.waiters == callers
+		})
+		b.release <- struct{}{}
+		b.release <- struct{}{}
+		wg.Wait()
+		close(results)
+
+		// Every caller of a key got the value of its key's single load
+		seen := make(map[string]int)
+		for value := range results {
+			seen[value]++
+		}
+		if len(seen) != 2 {
+			t.Errorf("round %d: callers saw %v, want one value per key", round, seen)
+		}
+		if n := b.calls.Load(); n != int64(2*(round+1)) {
+			t.Fatalf("round %d: loader called %d times, want one load per key", round, n)
+		}
+		clk.Advance(2 * time.Minute)
+	}
+}
+
+func TestCallerGivesUpWithoutCancellingLoad(t *testing.T) {
+	c, b, _ := newCache(t)
+	b.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "k")
+		done <- err
+	}()
+	waitFor(t, "the load to start", func() bool { return b.calls.Load() == 1 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled with nothing cached", err)
+	}
+
+	// The load finishes for the cache even though its caller left
+	close(b.release)
+	waitFor(t, "the load to be cached", func() bool { return c.Metrics().Loads == 1 })
+	if r, err := c.Get(context.Background(), "k"); err != nil || r.Value != "k v1" {
+		t.Errorf("Get = %+v, %v; want the value loaded for the caller that left", r, err)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,326 @@
+// Package cbhttp provides an http.RoundTripper that protects every upstream
+// host with its own cbreak circuit breaker.
+//
+// Example usage:
+//
+//	client := &http.Client{Transport: cbhttp.NewTransport()}
+//	resp, err := client.Get("http://example.com/")
+//	if errors.Is(err, cbreak.ErrCircuitOpen) {
+//	    // fail fast, the host is unhealthy
+//	}
+package cbhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+)
+
+// maxDrainBytes bounds how much of a discarded body is read so the
+// underlying connection can be reused
+const maxDrainBytes = 4 << 10
+
+// DefaultIdleTimeout is how long the breaker of an unused host is kept
+const DefaultIdleTimeout = 5 * time.Minute
+
+// Classifier reports whether a response should count as a failure
+type Classifier func(resp *http.Response) bool
+
+// ServerErrors classifies 5xx responses as failures
+func ServerErrors(resp *http.Response) bool {
+	return resp.StatusCode >= 500
+}
+
+// CircuitOpenError is returned when the breaker for a host rejects a request
+type CircuitOpenError struct {
+	Host string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open for host %s", e.Host)
+}
+
+// Unwrap allows errors.Is(err, cbreak.ErrCircuitOpen)
+func (e *CircuitOpenError) Unwrap() error {
+	return cbreak.ErrCircuitOpen
+}
+
+// StatusError is returned in place of a response the classifier rejected.
+// The body has already been drained and closed.
+type StatusError struct {
+	Host       string
+	StatusCode int
+	Header     http.Header
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upstream %s responded with %d %s", e.Host, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// host is the breaker of one upstream host
+type host struct {
+	breaker  *cbreak.Breaker[*http.Response]
+	inFlight int
+	lastUsed time.Time
+}
+
+// Transport is an http.RoundTripper with one circuit breaker per host.
+// Breakers of hosts unused for the idle timeout are shut down and dropped.
+type Transport struct {
+	base        http.RoundTripper
+	config      func(host string) *cbreak.Config
+	classify    Classifier
+	idleTimeout time.Duration
+	clock       clock.Clock
+
+	mu        sync.Mutex
+	hosts     map[string]*host
+	lastSweep time.Time
+}
+
+// Option configures a Transport
+type Option func(*Transport)
+
+// WithBase sets the RoundTripper used to perform requests
+func WithBase(base http.RoundTripper) Option {
+	return func(t *Transport) {
+		t.base = base
+	}
+}
+
+// WithConfig sets the function producing the breaker configuration for a host
+func WithConfig(config func(host string) *cbreak.Config) Option {
+	return func(t *Transport) {
+		t.config = config
+	}
+}
+
+// WithClassifier sets the function deciding which responses are failures
+func WithClassifier(classify Classifier) Option {
+	return func(t *Transport) {
+		t.classify = classify
+	}
+}
+
+// WithIdleTimeout sets how long the breaker of an unused host is kept
+// (defaults to DefaultIdleTimeout)
+func WithIdleTimeout(d time.Duration) Option {
+	return func(t *Transport) {
+		t.idleTimeout = d
+	}
+}
+
+// WithClock sets the clock used to track idle hosts
+func WithClock(clk clock.Clock) Option {
+	return func(t *Transport) {
+		t.clock = clk
+	}
+}
+
+// NewTransport creates a Transport wrapping http.DefaultTransport
+func NewTransport(opts ...Option) *Transport {
+	t := &Transport{
+		base:        http.DefaultTransport,
+		config:      func(host string) *cbreak.Config { return cbreak.DefaultConfig("http-" + host) },
+		classify:    ServerErrors,
+		idleTimeout: DefaultIdleTimeout,
+		clock:       clock.Real(),
+		hosts:       make(map[string]*host),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.lastSweep = t.clock.Now()
+	return t
+}
+
+// acquire returns the breaker for name, creating it from the config of the
+// host on first use, and marks it in use until release. Once per idle
+// timeout it also drops the breakers of idle hosts.
+func (t *Transport) acquire(name string) (*cbreak.Breaker[*http.Response], error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	if now.Sub(t.lastSweep) >= t.idleTimeout {
+		t.lastSweep = now
+		for key, h := range t.hosts {
+			if h.inFlight == 0 && now.Sub(h.lastUsed) >= t.idleTimeout {
+				h.breaker.Shutdown()
+				delete(t.hosts, key)
+			}
+		}
+	}
+
+	h, ok := t.hosts[name]
+	if !ok {
+		breaker, err := cbreak.NewBreaker[*http.Response](t.config(name))
+		if err != nil {
+			return nil, err
+		}
+		h = &host{breaker: breaker}
+		t.hosts[name] = h
+	}
+	h.inFlight++
+	h.lastUsed = now
+	return h.breaker, nil
+}
+
+// release marks a request to name as finished
+func (t *Transport) release(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.hosts[name]; ok {
+		h.inFlight--
+		h.lastUsed = t.clock.Now()
+	}
+}
+
+// RoundTrip executes a single request through the breaker for its host.
+// The breaker's CommandTimeout bounds the time until the response headers
+// arrive; reading the body is not limited by it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	breaker, err := t.acquire(host)
+	if err != nil {
+		return nil, err
+	}
+	defer t.release(host)
+
+	// Execute gives up after CommandTimeout; cancelling ctx then aborts the
+	// request on the wire too. Otherwise ctx lives until the body is closed.
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.WithContext(ctx)
+
+	pending := &pendingResponse{}
+	resp, err := breaker.Execute(req.Context(), func() (*http.Response, error) {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		if t.classify(resp) {
+			drain(resp)
+			return nil, &StatusError{Host: host, StatusCode: resp.StatusCode, Header: resp.Header}
+		}
+		return pending.deliver(resp)
+	})
+	if err != nil {
+		// Execute may give up before fn returns; make sure a late response
+		// does not leak its connection
+		pending.abandon()
+		cancel()
+		if errors.Is(err, cbreak.ErrCircuitOpen) {
+			return nil, &CircuitOpenError{Host: host}
+		}
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// breakers returns a copy of the breakers currently held
+func (t *Transport) breakers() map[string]*cbreak.Breaker[*http.Response] {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	breakers := make(map[string]*cbreak.Breaker[*http.Response], len(t.hosts))
+	for name, h := range t.hosts {
+		breakers[name] = h.breaker
+	}
+	return breakers
+}
+
+// States returns the current breaker state for every host in use
+func (t *Transport) States() map[string]cbreak.State {
+	// GetState may fire OnStateChange, so call it outside the lock
+	states := make(map[string]cbreak.State)
+	for name, b := range t.breakers() {
+		states[name] = b.GetState()
+	}
+	return states
+}
+
+// Metrics returns the breaker metrics for every host in use
+func (t *Transport) Metrics() map[string]*cbreak.Metrics {
+	metrics := make(map[string]*cbreak.Metrics)
+	for name, b := range t.breakers() {
+		metrics[name] = b.GetMetrics()
+	}
+	return metrics
+}
+
+// Close shuts down the breaker of every host. Requests made afterwards get
+// fresh breakers.
+func (t *Transport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, h := range t.hosts {
+		h.breaker.Shutdown()
+		delete(t.hosts, name)
+	}
+}
+
+// CloseIdleConnections forwards to the base transport when supported
+func (t *Transport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	if ci, ok := t.base.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// pendingResponse hands a response from the breaker goroutine to RoundTrip,
+// draining it if RoundTrip has already returned
+type pendingResponse struct {
+	mu        sync.Mutex
+	resp      *http.Response
+	abandoned bool
+}
+
+// deliver records resp unless the caller has stopped waiting for it
+func (p *pendingResponse) deliver(resp *http.Response) (*http.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.abandoned {
+		drain(resp)
+		return nil, context.Canceled
+	}
+	p.resp = resp
+	return resp, nil
+}
+
+// abandon drains any response that was delivered but never returned
+func (p *pendingResponse) abandon() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.abandoned = true
+	if p.resp != nil {
+		drain(p.resp)
+		p.resp = nil
+	}
+}
+
+// drain discards what is left of a response body and closes it
+func drain(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+	_ = resp.Body.Close()
+}
+
+// cancelBody releases the request context once the body is closed. It does
+// not bound how long the body takes to read.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
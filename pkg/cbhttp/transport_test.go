@@ -0,0 +1,266 @@
+package cbhttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// trackedBody is a response body that records when it is closed
+type trackedBody struct {
+	io.Reader
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newTrackedBody(s string) *trackedBody {
+	return &trackedBody{Reader: strings.NewReader(s), closed: make(chan struct{})}
+}
+
+func (b *trackedBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+// breakerConfig returns breaker configs that open after threshold failures
+// and give up on requests after commandTimeout
+func breakerConfig(threshold int, commandTimeout time.Duration) func(host string) *cbreak.Config {
+	return func(host string) *cbreak.Config {
+		config := cbreak.DefaultConfig("test-" + host)
+		config.FailureThreshold = threshold
+		config.CommandTimeout = commandTimeout
+		config.Timeout = time.Minute
+		return config
+	}
+}
+
+// status serves every request with code
+func status(code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+		io.WriteString(w, http.StatusText(code))
+	})
+}
+
+// hostOf returns the host part of a server URL, as used for breaker names
+func hostOf(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func TestHostsHaveSeparateBreakers(t *testing.T) {
+	bad := httptest.NewServer(status(http.StatusInternalServerError))
+	defer bad.Close()
+	good := httptest.NewServer(status(http.StatusOK))
+	defer good.Close()
+
+	transport := NewTransport(WithConfig(breakerConfig(2, time.Second)))
+	defer transport.Close()
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get(bad.URL); err == nil {
+			t.Fatalf("request %d to the failing host succeeded", i+1)
+		}
+	}
+
+	_, err := client.Get(bad.URL)
+	if !errors.Is(err, cbreak.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want cbreak.ErrCircuitOpen", err)
+	}
+	var open *CircuitOpenError
+	if !errors.As(err, &open) || open.Host != hostOf(t, bad) {
+		t.Errorf("err = %v, want a CircuitOpenError for %s", err, hostOf(t, bad))
+	}
+
+	resp, err := client.Get(good.URL)
+	if err != nil {
+		t.Fatalf("healthy host rejected: %v", err)
+	}
+	resp.Body.Close()
+
+	states := transport.States()
+	if states[hostOf(t, bad)] != cbreak.Open || states[hostOf(t, good)] != cbreak.Closed {
+		t.Errorf("states = %v, want only the failing host open", states)
+	}
+}
+
+func TestStatusErrorReusesConnection(t *testing.T) {
+	var connections atomic.Int64
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, strings.Repeat("upstream is down\n", 100))
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	transport := NewTransport(WithConfig(breakerConfig(10, time.Second)))
+	defer transport.Close()
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Get(server.URL)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("err = %v, want a StatusError", err)
+		}
+		if statusErr.StatusCode != http.StatusBadGateway || statusErr.Header.Get("Retry-After") != "7" {
+			t.Errorf("StatusError = %+v, want 502 with its headers", statusErr)
+		}
+	}
+	failing.Store(false)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// Each rejected body was drained and closed, so every request used the
+	// same connection
+	if n := connections.Load(); n != 1 {
+		t.Errorf("%d connections opened for 4 sequential requests, want 1", n)
+	}
+}
+
+func TestLateResponseIsDrained(t *testing.T) {
+	body := newTrackedBody("late")
+	release := make(chan struct{})
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		// Ignore cancellation to deliver after the breaker gave up
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body, Request: req}, nil
+	})
+	transport := NewTransport(WithBase(base), WithConfig(breakerConfig(10, 20*time.Millisecond)))
+	defer transport.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "http://upstream.test/", nil)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the CommandTimeout", err)
+	}
+	close(release)
+	select {
+	case <-body.closed:
+	case <-time.After(time.Second):
+		t.Error("the response that arrived after the timeout was never closed")
+	}
+}
+
+func TestBodyOutlivesCommandTimeout(t *testing.T) {
+	var reqCtx context.Context
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		reqCtx = req.Context()
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: newTrackedBody("slow body"), Request: req}, nil
+	})
+	transport := NewTransport(WithBase(base), WithConfig(breakerConfig(10, 20*time.Millisecond)))
+	defer transport.Close()
+
+	resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://upstream.test/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reading the body is not bound by CommandTimeout
+	time.Sleep(50 * time.Millisecond)
+	if err := reqCtx.Err(); err != nil {
+		t.Fatalf("request context ended before the body was closed: %v", err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || string(data) != "slow body" {
+		t.Fatalf("body = %q, %v", data, err)
+	}
+	resp.Body.Close()
+	if reqCtx.Err() == nil {
+		t.Error("closing the body did not release the request context")
+	}
+}
+
+func TestIdleHostsEvictedUnlessInFlight(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "busy.test" {
+			close(started)
+			<-release
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+	transport := NewTransport(WithBase(base), WithClock(clk), WithIdleTimeout(time.Minute),
+		WithConfig(breakerConfig(10, 10*time.Second)))
+	defer transport.Close()
+
+	get := func(host string) error {
+		resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil))
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	busy := make(chan error, 1)
+	go func() { busy <- get("busy.test") }()
+	<-started
+	if err := get("idle.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request to another host sweeps once the idle timeout has passed
+	clk.Advance(2 * time.Minute)
+	if err := get("other.test"); err != nil {
+		t.Fatal(err)
+	}
+	states := transport.States()
+	if _, ok := states["idle.test"]; ok {
+		t.Error("idle host kept past the idle timeout")
+	}
+	if _, ok := states["busy.test"]; !ok {
+		t.Error("host with a request in flight was evicted")
+	}
+
+	close(release)
+	if err := <-busy; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	clk.Advance(2 * time.Minute)
+	if err := get("other.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := transport.States()["busy.test"]; ok {
+		t.Error("host kept after its request finished and it went idle")
+	}
+}
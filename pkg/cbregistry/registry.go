@@ -0,0 +1,285 @@
+// Package cbregistry manages one cbreak circuit breaker per key (downstream
+// host, tenant, endpoint...), creating breakers lazily from a config template
+// and evicting the ones that have gone idle.
+package cbregistry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+)
+
+// Default values for registry options
+const (
+	DefaultIdleTimeout   = 5 * time.Minute
+	DefaultSweepInterval = time.Minute
+)
+
+// ErrClosed is returned when a breaker is requested from a closed registry
+var ErrClosed = errors.New("breaker registry is closed")
+
+// Option configures a Registry
+type Option func(*options)
+
+type options struct {
+	idleTimeout   time.Duration
+	sweepInterval time.Duration
+	onEvict       func(key string)
+	clock         clock.Clock
+}
+
+// WithIdleTimeout sets how long a breaker may go unused before it is evicted
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = d
+	}
+}
+
+// WithSweepInterval sets how often idle breakers are looked for.
+// A zero interval disables the background sweep; call Sweep manually.
+func WithSweepInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.sweepInterval = d
+	}
+}
+
+// WithOnEvict registers a callback invoked after a breaker is evicted
+func WithOnEvict(fn func(key string)) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// WithClock sets the clock used to track idle time and create breakers
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// entry is a breaker together with its last use
+type entry[T any] struct {
+	breaker  *cbreak.Breaker[T]
+	inFlight int // calls running through Execute
+	lastUsed time.Time
+}
+
+// Registry holds one breaker per key. It is safe for concurrent use.
+type Registry[T any] struct {
+	mu       sync.Mutex
+	template cbreak.Config
+	opts     options
+	entries  map[string]*entry[T]
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closed    bool
+}
+
+// New creates a registry producing breakers from template. Every breaker
+// gets a copy of template named "<template.Name>-<key>".
+func New[T any](template *cbreak.Config, opts ...Option) (*Registry[T], error) {
+	// Validate the template once up front rather than on first use
+	probe, err := cbreak.NewBreaker[T](template)
+	if err != nil {
+		return nil, err
+	}
+	probe.Shutdown()
+
+	o := options{
+		idleTimeout:   DefaultIdleTimeout,
+		sweepInterval: DefaultSweepInterval,
+		clock:         clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	r := &Registry[T]{
+		template: *template,
+		opts:     o,
+		entries:  make(map[string]*entry[T]),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if o.sweepInterval > 0 {
+		// Create the ticker before returning so ticks from a fake clock
+		// advanced right after New are not missed
+		go r.sweepLoop(o.clock.NewTicker(o.sweepInterval))
+	} else {
+		close(r.done)
+	}
+
+	return r, nil
+}
+
+// Get returns the breaker for key, creating it on first use. Prefer Execute,
+// which keeps the breaker marked as in use for the duration of the call.
+func (r *Registry[T]) Get(key string) (*cbreak.Breaker[T], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, err := r.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return e.breaker, nil
+}
+
+// lookup returns the entry for key, creating it on first use, and marks it
+// used now. It must be called with r.mu held.
+func (r *Registry[T]) lookup(key string) (*entry[T], error) {
+	if r.closed {
+		return nil, ErrClosed
+	}
+
+	if e, ok := r.entries[key]; ok {
+		e.lastUsed = r.opts.clock.Now()
+		return e, nil
+	}
+
+	config := r.template
+	config.Name = r.template.Name + "-" + key
+	breaker, err := clock.NewBreaker[T](r.opts.clock, &config)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry[T]{breaker: breaker, lastUsed: r.opts.clock.Now()}
+	r.entries[key] = e
+	return e, nil
+}
+
+// Execute runs fn through the breaker for key. The breaker counts as in use
+// until fn returns, so Sweep never shuts it down mid-call.
+func (r *Registry[T]) Execute(ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	r.mu.Lock()
+	e, err := r.lookup(key)
+	if err == nil {
+		e.inFlight++
+	}
+	r.mu.Unlock()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer r.release(e)
+	return e.breaker.Execute(ctx, fn)
+}
+
+// release marks a call through e as finished
+func (r *Registry[T]) release(e *entry[T]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.inFlight--
+	e.lastUsed = r.opts.clock.Now()
+}
+
+// GetState returns a snapshot of the state of every breaker in the registry
+func (r *Registry[T]) GetState() map[string]cbreak.State {
+	r.mu.Lock()
+	breakers := make(map[string]*cbreak.Breaker[T], len(r.entries))
+	for key, e := range r.entries {
+		breakers[key] = e.breaker
+	}
+	r.mu.Unlock()
+
+	// GetState may fire OnStateChange, so call it outside the registry lock
+	states := make(map[string]cbreak.State, len(breakers))
+	for key, b := range breakers {
+		states[key] = b.GetState()
+	}
+	return states
+}
+
+// Len returns the number of breakers currently held
+func (r *Registry[T]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// Sweep evicts breakers that have been idle longer than the idle timeout and
+// returns how many were removed. Breakers with a call running through
+// Execute are never idle. Open and half-open breakers are kept for the
+// breaker Timeout on top of that, so a host that just failed is not
+// forgotten at once; but since a breaker only closes again when called,
+// one left unused that long is evicted whatever its state.
+func (r *Registry[T]) Sweep() int {
+	now := r.opts.clock.Now()
+	cutoff := now.Add(-r.opts.idleTimeout)
+	unhealthyCutoff := cutoff.Add(-r.template.Timeout)
+
+	r.mu.Lock()
+	candidates := make(map[string]*entry[T])
+	lastUsed := make(map[string]time.Time)
+	for key, e := range r.entries {
+		if e.inFlight == 0 && !e.lastUsed.After(cutoff) {
+			candidates[key] = e
+			lastUsed[key] = e.lastUsed
+		}
+	}
+	r.mu.Unlock()
+
+	// GetState may fire OnStateChange, so call it outside the registry lock
+	for key, e := range candidates {
+		if e.breaker.GetState() != cbreak.Closed && lastUsed[key].After(unhealthyCutoff) {
+			delete(candidates, key)
+		}
+	}
+
+	r.mu.Lock()
+	var evicted []string
+	for key, e := range candidates {
+		// Skip entries that were used again since the first pass
+		if current, ok := r.entries[key]; !ok || current != e || e.inFlight > 0 || e.lastUsed.After(cutoff) {
+			continue
+		}
+		e.breaker.Shutdown()
+		delete(r.entries, key)
+		evicted = append(evicted, key)
+	}
+	r.mu.Unlock()
+
+	if r.opts.onEvict != nil {
+		for _, key := range evicted {
+			r.opts.onEvict(key)
+		}
+	}
+	return len(evicted)
+}
+
+// sweepLoop evicts idle breakers on every tick until Close is called
+func (r *Registry[T]) sweepLoop(ticker clock.Ticker) {
+	defer close(r.done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			r.Sweep()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background sweep and shuts down every breaker
+func (r *Registry[T]) Close() {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for key, e := range r.entries {
+			e.breaker.Shutdown()
+			delete(r.entries, key)
+		}
+		r.closed = true
+	})
+}
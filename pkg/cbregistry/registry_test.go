@@ -0,0 +1,180 @@
+package cbregistry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+	"github.com/gozephyr/examples/pkg/clock"
+)
+
+// template returns a breaker config that opens after one failure and
+// stays open for a minute
+func template() *cbreak.Config {
+	config := cbreak.DefaultConfig("test")
+	config.FailureThreshold = 1
+	config.Timeout = time.Minute
+	return config
+}
+
+// newRegistry returns a registry without a background sweep, idle after
+// a minute, that records evicted keys
+func newRegistry(t *testing.T, clk clock.Clock) (*Registry[string], func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var evicted []string
+	r, err := New[string](template(),
+		WithClock(clk),
+		WithIdleTimeout(time.Minute),
+		WithSweepInterval(0),
+		WithOnEvict(func(key string) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted = append(evicted, key)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.Close)
+	return r, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Sorted(slices.Values(evicted))
+	}
+}
+
+func TestGetReusesBreakers(t *testing.T) {
+	r, _ := newRegistry(t, clock.NewFake(clock.Epoch))
+
+	a, err := r.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := r.Get("a")
+	b, _ := r.Get("b")
+	if a != again {
+		t.Error("Get returned a new breaker for a known key")
+	}
+	if a == b {
+		t.Error("two keys share a breaker")
+	}
+	if n := r.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+}
+
+func TestSweepEvictsIdleBreakers(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	r, evicted := newRegistry(t, clk)
+
+	for _, key := range []string{"idle", "busy", "failing"} {
+		if _, err := r.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = r.Execute(ctx, "failing", func() (string, error) { return "", errors.New("down") })
+	if state := r.GetState()["failing"]; state != cbreak.Open {
+		t.Fatalf("failing breaker is %s, want open", state)
+	}
+
+	clk.Advance(30 * time.Second)
+	if _, err := r.Get("busy"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(40 * time.Second)
+	if n := r.Sweep(); n != 1 {
+		t.Errorf("Sweep() = %d, want 1", n)
+	}
+	if got := evicted(); !slices.Equal(got, []string{"idle"}) {
+		t.Errorf("evicted %v, want [idle]", got)
+	}
+
+	// The failing breaker is kept for its Timeout past the idle timeout
+	clk.Advance(time.Minute)
+	if n := r.Sweep(); n != 2 {
+		t.Errorf("Sweep() = %d, want 2", n)
+	}
+	if got := evicted(); !slices.Equal(got, []string{"busy", "failing", "idle"}) {
+		t.Errorf("evicted %v, want every breaker", got)
+	}
+	if n := r.Len(); n != 0 {
+		t.Errorf("Len() = %d after every breaker went idle", n)
+	}
+}
+
+func TestNoEvictionMidCall(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	r, evicted := newRegistry(t, clk)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Execute(context.Background(), "slow", func() (string, error) {
+			close(started)
+			<-release
+			return "ok", nil
+		})
+		done <- err
+	}()
+	<-started
+
+	// The call outlasts the idle timeout
+	clk.Advance(5 * time.Minute)
+	if n := r.Sweep(); n != 0 {
+		t.Errorf("Sweep() = %d while a call was running, evicted %v", n, evicted())
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	// The end of the call counts as a use
+	if n := r.Sweep(); n != 0 {
+		t.Errorf("Sweep() = %d right after the call returned", n)
+	}
+	clk.Advance(time.Minute)
+	if n := r.Sweep(); n != 1 {
+		t.Errorf("Sweep() = %d once idle, want 1", n)
+	}
+}
+
+func TestCloseStopsSweepLoop(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	evicted := make(chan string, 1)
+	r, err := New[string](template(),
+		WithClock(clk),
+		WithIdleTimeout(time.Minute),
+		WithSweepInterval(time.Minute),
+		WithOnEvict(func(key string) { evicted <- key }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The background sweep evicts the idle breaker
+	clk.Advance(time.Minute)
+	select {
+	case key := <-evicted:
+		if key != "a" {
+			t.Errorf("evicted %q, want a", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("background sweep did not run")
+	}
+
+	r.Close()
+	if n := clk.Pending(); n != 0 {
+		t.Errorf("%d timers still pending after Close, want the sweep ticker stopped", n)
+	}
+	if _, err := r.Get("a"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Get after Close: err = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,57 @@
+package clock
+
+import (
+	"sync"
+
+	"github.com/gozephyr/cbreak"
+)
+
+// NewBreaker creates a cbreak breaker whose Open to Half-Open transition is
+// driven by clk. cbreak reads time.Now directly, so with a fake clock the
+// breaker is moved to Half-Open explicitly once config.Timeout has elapsed
+// on clk. With the real clock this is plain cbreak.NewBreaker.
+//
+// Any OnStateChange hook already set on config is still called.
+func NewBreaker[T any](clk Clock, config *cbreak.Config) (*cbreak.Breaker[T], error) {
+	if IsReal(clk) || config == nil {
+		return cbreak.NewBreaker[T](config)
+	}
+
+	var (
+		mu      sync.Mutex
+		pending Timer
+		breaker *cbreak.Breaker[T]
+	)
+
+	// Work on a copy so the caller's config keeps its own hook
+	wrapped := *config
+	userHook := config.OnStateChange
+	wrapped.OnStateChange = func(from, to cbreak.State, reason string) {
+		// Called with the breaker lock held: only schedule work here
+		mu.Lock()
+		if pending != nil {
+			pending.Stop()
+			pending = nil
+		}
+		if to == cbreak.Open {
+			pending = clk.AfterFunc(config.Timeout, func() {
+				mu.Lock()
+				pending = nil
+				mu.Unlock()
+				breaker.SetState(cbreak.HalfOpen, "timeout elapsed")
+			})
+		}
+		mu.Unlock()
+
+		if userHook != nil {
+			userHook(from, to, reason)
+		}
+	}
+
+	b, err := cbreak.NewBreaker[T](&wrapped)
+	if err != nil {
+		return nil, err
+	}
+	breaker = b
+	return breaker, nil
+}
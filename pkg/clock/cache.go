@@ -0,0 +1,150 @@
+package clock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/gencache"
+	cacheerrors "github.com/gozephyr/gencache/errors"
+)
+
+// minSweep is the smallest number of tracked expiries worth sweeping
+const minSweep = 64
+
+// cache expires entries according to a Clock on top of a gencache cache
+type cache[K comparable, V any] struct {
+	gencache.Cache[K, V]
+	clk       Clock
+	mu        sync.Mutex
+	expires   map[K]time.Time
+	nextSweep int // sweep expired entries once expires grows this large
+}
+
+// WrapCache returns c with TTLs measured on clk. gencache reads time.Now
+// directly, so with a fake clock the wrapper tracks expiry itself and treats
+// entries past their TTL as missing. With the real clock c is returned as is.
+//
+// Expiries of entries gencache evicts are forgotten as they are evicted, and
+// entries past their TTL on clk are swept out whenever the number tracked has
+// doubled, so the wrapper stays as large as the cache it wraps.
+func WrapCache[K comparable, V any](clk Clock, c gencache.Cache[K, V]) gencache.Cache[K, V] {
+	if IsReal(clk) {
+		return c
+	}
+	w := &cache[K, V]{
+		Cache:     c,
+		clk:       clk,
+		expires:   make(map[K]time.Time),
+		nextSweep: minSweep,
+	}
+	// gencache emits these synchronously and never while w.mu is held
+	c.OnEvent(func(event gencache.CacheEvent[K, V]) {
+		switch event.Type {
+		case gencache.EventTypeEviction, gencache.EventTypeExpiration:
+			w.forget(event.Key)
+		}
+	})
+	return w
+}
+
+// forget stops tracking the expiry of key
+func (c *cache[K, V]) forget(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.expires, key)
+}
+
+// Get retrieves a value, honouring expiry on the wrapped clock
+func (c *cache[K, V]) Get(key K) (V, error) {
+	return c.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext retrieves a value, honouring expiry on the wrapped clock
+func (c *cache[K, V]) GetWithContext(ctx context.Context, key K) (V, error) {
+	c.mu.Lock()
+	expires, ok := c.expires[key]
+	expired := ok && !c.clk.Now().Before(expires)
+	if expired {
+		delete(c.expires, key)
+	}
+	c.mu.Unlock()
+
+	if expired {
+		_ = c.Cache.DeleteWithContext(ctx, key)
+		c.Cache.Stats().IncMisses()
+		var zero V
+		return zero, cacheerrors.WrapError("Get", key, cacheerrors.ErrKeyNotFound)
+	}
+
+	value, err := c.Cache.GetWithContext(ctx, key)
+	if ok && errors.Is(err, cacheerrors.ErrKeyNotFound) {
+		// Evicted without an event reaching us; stop tracking it
+		c.forget(key)
+	}
+	return value, err
+}
+
+// Set stores a value with a TTL measured on the wrapped clock
+func (c *cache[K, V]) Set(key K, value V, ttl time.Duration) error {
+	return c.SetWithContext(context.Background(), key, value, ttl)
+}
+
+// SetWithContext stores a value with a TTL measured on the wrapped clock
+func (c *cache[K, V]) SetWithContext(ctx context.Context, key K, value V, ttl time.Duration) error {
+	if err := c.Cache.SetWithContext(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	now := c.clk.Now()
+	if ttl > 0 {
+		c.expires[key] = now.Add(ttl)
+	} else {
+		delete(c.expires, key)
+	}
+	var expired []K
+	if len(c.expires) >= c.nextSweep {
+		for k, expires := range c.expires {
+			if !now.Before(expires) {
+				delete(c.expires, k)
+				expired = append(expired, k)
+			}
+		}
+		c.nextSweep = max(2*len(c.expires), minSweep)
+	}
+	c.mu.Unlock()
+
+	// Delete outside the lock, since gencache may emit events back to us
+	for _, k := range expired {
+		_ = c.Cache.DeleteWithContext(ctx, k)
+	}
+	return nil
+}
+
+// Delete removes a value
+func (c *cache[K, V]) Delete(key K) error {
+	return c.DeleteWithContext(context.Background(), key)
+}
+
+// DeleteWithContext removes a value
+func (c *cache[K, V]) DeleteWithContext(ctx context.Context, key K) error {
+	c.mu.Lock()
+	delete(c.expires, key)
+	c.mu.Unlock()
+	return c.Cache.DeleteWithContext(ctx, key)
+}
+
+// Clear removes all values
+func (c *cache[K, V]) Clear() error {
+	return c.ClearWithContext(context.Background())
+}
+
+// ClearWithContext removes all values
+func (c *cache[K, V]) ClearWithContext(ctx context.Context) error {
+	c.mu.Lock()
+	c.expires = make(map[K]time.Time)
+	c.mu.Unlock()
+	return c.Cache.ClearWithContext(ctx)
+}
@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/gencache"
+)
+
+// tracked returns how many expiries the wrapper holds
+func tracked[K comparable, V any](c gencache.Cache[K, V]) int {
+	w := c.(*cache[K, V])
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.expires)
+}
+
+func TestWrapCacheForgetsEvictedEntries(t *testing.T) {
+	const capacity = 10
+	inner := gencache.New[string, int](gencache.WithMaxSize[string, int](capacity))
+	defer inner.Close()
+	c := WrapCache(NewFake(Epoch), inner)
+
+	for i := 0; i < 1000; i++ {
+		if err := c.Set(fmt.Sprintf("key-%d", i), i, time.Hour); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if n := tracked(c); n > capacity {
+		t.Errorf("tracking %d expiries for a cache of %d entries", n, capacity)
+	}
+}
+
+func TestWrapCacheSweepsExpiredEntries(t *testing.T) {
+	clk := NewFake(Epoch)
+	inner := gencache.New[string, int]()
+	defer inner.Close()
+	c := WrapCache(clk, inner)
+
+	for i := 0; i < 1000; i++ {
+		if err := c.Set(fmt.Sprintf("key-%d", i), i, time.Minute); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		// Every entry has expired by the time the next one is stored
+		clk.Advance(time.Minute)
+	}
+	if n := tracked(c); n > 2*minSweep {
+		t.Errorf("tracking %d expiries for entries that were never read again", n)
+	}
+	if _, err := c.Get("key-0"); err == nil {
+		t.Error("Get returned an entry past its TTL")
+	}
+}
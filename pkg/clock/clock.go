@@ -0,0 +1,96 @@
+// Package clock provides a time source that examples can swap for a
+// deterministic fake, so waiting out breaker timeouts and cache TTLs takes
+// no real time.
+package clock
+
+import (
+	"os"
+	"time"
+)
+
+// EnvVar selects the clock returned by FromEnv. Set it to "fake" to run
+// examples against a fake clock.
+const EnvVar = "ZEPHYR_CLOCK"
+
+// Epoch is the time a fake clock created by FromEnv starts at
+var Epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a source of time
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Since returns the time elapsed since t
+	Since(t time.Time) time.Duration
+
+	// Sleep pauses for at least d
+	Sleep(d time.Duration)
+
+	// After waits for d to elapse and then sends the current time on the returned channel
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc calls f once d has elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+
+	// NewTicker returns a ticker firing every d
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a pending call scheduled by AfterFunc
+type Timer interface {
+	// Stop prevents the timer from firing, reporting whether it was still pending
+	Stop() bool
+}
+
+// Ticker delivers ticks at intervals
+type Ticker interface {
+	// C returns the channel on which ticks are delivered
+	C() <-chan time.Time
+
+	// Stop turns off the ticker
+	Stop()
+}
+
+// Real returns the clock backed by the time package
+func Real() Clock {
+	return realClock{}
+}
+
+// FromEnv returns a fake clock starting at Epoch when EnvVar is "fake",
+// and the real clock otherwise
+func FromEnv() Clock {
+	if os.Getenv(EnvVar) == "fake" {
+		return NewFake(Epoch)
+	}
+	return Real()
+}
+
+// IsReal reports whether clk is the real clock
+func IsReal(clk Clock) bool {
+	_, ok := clk.(realClock)
+	return ok
+}
+
+// realClock implements Clock using the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker adapts time.Ticker to the Ticker interface
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
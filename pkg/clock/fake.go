@@ -0,0 +1,157 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a manually advanced clock. Timers and tickers fire synchronously,
+// in order, from the goroutine calling Advance. It is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+// NewFake creates a fake clock set to start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// fakeTimer is a pending timer, After channel or ticker
+type fakeTimer struct {
+	fake   *Fake
+	when   time.Time
+	period time.Duration
+	fn     func()
+	ch     chan time.Time
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the fake time elapsed since t
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep advances the clock by d instead of blocking
+func (f *Fake) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+// After returns a channel receiving the fake time once d has elapsed
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	f.schedule(&fakeTimer{when: f.Now().Add(d), ch: ch})
+	return ch
+}
+
+// AfterFunc calls fn once the clock has been advanced by d
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{when: f.Now().Add(d), fn: fn}
+	f.schedule(t)
+	return t
+}
+
+// NewTicker returns a ticker firing every d of fake time
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{when: f.Now().Add(d), period: d, ch: make(chan time.Time, 1)}
+	f.schedule(t)
+	return fakeTicker{t}
+}
+
+// Advance moves the clock forward by d, firing every timer that falls due
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+	for len(f.waiters) > 0 && !f.waiters[0].when.After(target) {
+		t := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		f.now = t.when
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+			f.insert(t)
+		}
+
+		// Fire without holding the lock so callbacks may use the clock
+		now := f.now
+		f.mu.Unlock()
+		t.fire(now)
+		f.mu.Lock()
+	}
+	f.now = target
+	f.mu.Unlock()
+}
+
+// Pending returns the number of timers and tickers waiting to fire
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// schedule registers t with the clock
+func (f *Fake) schedule(t *fakeTimer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t.fake = f
+	f.insert(t)
+}
+
+// insert adds t keeping waiters ordered by due time. Callers hold f.mu.
+func (f *Fake) insert(t *fakeTimer) {
+	i := sort.Search(len(f.waiters), func(i int) bool {
+		return f.waiters[i].when.After(t.when)
+	})
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = t
+}
+
+// remove drops t from the waiters, reporting whether it was present
+func (f *Fake) remove(t *fakeTimer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, w := range f.waiters {
+		if w == t {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fire delivers a tick or runs the callback
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+	// Drop the tick if the receiver has fallen behind, like time.Ticker
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
+
+// Stop cancels the timer, reporting whether it was still pending
+func (t *fakeTimer) Stop() bool {
+	return t.fake.remove(t)
+}
+
+// fakeTicker adapts a periodic fakeTimer to the Ticker interface
+type fakeTicker struct {
+	timer *fakeTimer
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.timer.ch }
+func (t fakeTicker) Stop()               { t.timer.Stop() }
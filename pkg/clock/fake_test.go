@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAdvanceFiresInDeadlineOrder(t *testing.T) {
+	f := NewFake(Epoch)
+	var fired []string
+	var at []time.Duration
+	record := func(name string) func() {
+		return func() {
+			fired = append(fired, name)
+			at = append(at, f.Since(Epoch))
+		}
+	}
+	// Scheduled out of order, and one timer scheduled from another
+	f.AfterFunc(3*time.Second, record("3s"))
+	f.AfterFunc(time.Second, func() {
+		record("1s")()
+		f.AfterFunc(time.Second, record("1s+1s"))
+	})
+	f.AfterFunc(5*time.Second, record("5s"))
+	ch := f.After(4 * time.Second)
+
+	f.Advance(4 * time.Second)
+	if want := []string{"1s", "1s+1s", "3s"}; !slices.Equal(fired, want) {
+		t.Errorf("fired %v, want %v", fired, want)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}; !slices.Equal(at, want) {
+		t.Errorf("callbacks saw the clock at %v, want their deadlines %v", at, want)
+	}
+	select {
+	case now := <-ch:
+		if !now.Equal(Epoch.Add(4 * time.Second)) {
+			t.Errorf("After delivered %v, want its deadline", now)
+		}
+	default:
+		t.Error("After did not fire at its deadline")
+	}
+	if n := f.Pending(); n != 1 {
+		t.Errorf("Pending() = %d, want the 5s timer", n)
+	}
+	if now := f.Now(); !now.Equal(Epoch.Add(4 * time.Second)) {
+		t.Errorf("Now() = %v after Advance", now)
+	}
+}
+
+func TestTickerFiresOncePerPeriod(t *testing.T) {
+	f := NewFake(Epoch)
+	ticker := f.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	ticks := 0
+	for i := 0; i < 5; i++ {
+		f.Advance(time.Second)
+		select {
+		case now := <-ticker.C():
+			ticks++
+			if want := Epoch.Add(time.Duration(ticks) * time.Second); !now.Equal(want) {
+				t.Errorf("tick %d at %v, want %v", ticks, now, want)
+			}
+		default:
+			t.Fatalf("no tick after %d periods", i+1)
+		}
+	}
+
+	// Half a period fires nothing; a receiver that falls behind gets one
+	// tick, like time.Ticker
+	f.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Error("ticked before a full period")
+	default:
+	}
+	f.Advance(3 * time.Second)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Error("more than one tick buffered for a slow receiver")
+	default:
+	}
+}
+
+func TestStopPreventsFire(t *testing.T) {
+	f := NewFake(Epoch)
+	fired := false
+	timer := f.AfterFunc(time.Second, func() { fired = true })
+	if !timer.Stop() {
+		t.Error("Stop of a pending timer reported false")
+	}
+	if timer.Stop() {
+		t.Error("second Stop reported the timer still pending")
+	}
+
+	ticker := f.NewTicker(time.Second)
+	f.Advance(time.Second)
+	<-ticker.C()
+	ticker.Stop()
+
+	f.Advance(time.Minute)
+	if fired {
+		t.Error("stopped timer fired")
+	}
+	select {
+	case <-ticker.C():
+		t.Error("stopped ticker ticked")
+	default:
+	}
+	if n := f.Pending(); n != 0 {
+		t.Errorf("Pending() = %d after Stop", n)
+	}
+}
+
+func TestAfterFuncRunsOnAdvance(t *testing.T) {
+	f := NewFake(Epoch)
+	calls := 0
+	f.AfterFunc(time.Minute, func() { calls++ })
+
+	f.Advance(59 * time.Second)
+	if calls != 0 {
+		t.Fatal("AfterFunc ran before its delay")
+	}
+	f.Sleep(time.Second)
+	if calls != 1 {
+		t.Fatalf("AfterFunc ran %d times at its deadline, want 1", calls)
+	}
+	f.Advance(time.Hour)
+	if calls != 1 {
+		t.Errorf("AfterFunc ran %d times, want once", calls)
+	}
+}
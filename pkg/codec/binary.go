@@ -0,0 +1,179 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Wire types of binary fields
+const (
+	wireVarint = 0 // the value is a uvarint
+	wireBytes  = 2 // the value is a uvarint length followed by that many bytes
+)
+
+// ErrMalformed is returned when binary data cannot be decoded
+var ErrMalformed = errors.New("codec: malformed binary data")
+
+// Writer builds a binary encoded value. Each field is a uvarint tag holding
+// the field number and wire type, followed by the value.
+type Writer struct {
+	buf []byte
+}
+
+// Uint writes an unsigned integer field
+func (w *Writer) Uint(field int, v uint64) {
+	w.tag(field, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+// Int writes a signed integer field, zigzag encoded so small negative
+// numbers stay small
+func (w *Writer) Int(field int, v int64) {
+	w.Uint(field, uint64(v<<1)^uint64(v>>63))
+}
+
+// Bool writes a boolean field
+func (w *Writer) Bool(field int, v bool) {
+	var u uint64
+	if v {
+		u = 1
+	}
+	w.Uint(field, u)
+}
+
+// Bytes writes a length-prefixed byte field
+func (w *Writer) Bytes(field int, b []byte) {
+	w.tag(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// String writes a length-prefixed string field
+func (w *Writer) String(field int, s string) {
+	w.tag(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *Writer) tag(field int, wire uint64) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field)<<3|wire)
+}
+
+// Reader walks the fields of a binary encoded value. Fields the decoder does
+// not ask for are skipped, so fields added by newer writers are ignored.
+type Reader struct {
+	data  []byte
+	field int
+	wire  uint64
+	u     uint64
+	b     []byte
+	err   error
+}
+
+// Next advances to the next field, reporting false at the end of the data
+// or on malformed data (see Err)
+func (r *Reader) Next() bool {
+	if r.err != nil || len(r.data) == 0 {
+		return false
+	}
+	tag, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrMalformed
+		return false
+	}
+	r.data = r.data[n:]
+	r.field, r.wire = int(tag>>3), tag&7
+
+	switch r.wire {
+	case wireVarint:
+		r.u, n = binary.Uvarint(r.data)
+		if n <= 0 {
+			r.err = ErrMalformed
+			return false
+		}
+		r.data = r.data[n:]
+	case wireBytes:
+		size, n := binary.Uvarint(r.data)
+		if n <= 0 || size > uint64(len(r.data)-n) {
+			r.err = ErrMalformed
+			return false
+		}
+		r.b = r.data[n : n+int(size)]
+		r.data = r.data[n+int(size):]
+	default:
+		r.err = fmt.Errorf("%w: field %d has unknown wire type %d", ErrMalformed, r.field, r.wire)
+		return false
+	}
+	return true
+}
+
+// Field returns the number of the current field
+func (r *Reader) Field() int {
+	return r.field
+}
+
+// Uint returns the current field as an unsigned integer
+func (r *Reader) Uint() uint64 {
+	return r.u
+}
+
+// Int returns the current field as a zigzag encoded signed integer
+func (r *Reader) Int() int64 {
+	return int64(r.u>>1) ^ -int64(r.u&1)
+}
+
+// Bool returns the current field as a boolean
+func (r *Reader) Bool() bool {
+	return r.u != 0
+}
+
+// Bytes returns a copy of the current field's bytes
+func (r *Reader) Bytes() []byte {
+	return append([]byte(nil), r.b...)
+}
+
+// String returns the current field as a string
+func (r *Reader) String() string {
+	return string(r.b)
+}
+
+// Err returns the error that stopped Next, if any
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// binaryCodec encodes values with hand-written field functions
+type binaryCodec[V any] struct {
+	encode func(w *Writer, v V)
+	decode func(r *Reader) (V, error)
+}
+
+// Binary returns a compact codec built from an encode and a decode function,
+// much like generated protocol buffer code. Give every field a number that
+// never changes; decode should loop over r.Next and switch on r.Field.
+func Binary[V any](encode func(w *Writer, v V), decode func(r *Reader) (V, error)) Codec[V] {
+	return binaryCodec[V]{encode: encode, decode: decode}
+}
+
+func (binaryCodec[V]) ID() byte     { return IDBinary }
+func (binaryCodec[V]) Name() string { return "binary" }
+
+func (c binaryCodec[V]) Marshal(v V) ([]byte, error) {
+	var w Writer
+	c.encode(&w, v)
+	return w.buf, nil
+}
+
+func (c binaryCodec[V]) Unmarshal(data []byte, v *V) error {
+	r := &Reader{data: data}
+	value, err := c.decode(r)
+	if err != nil {
+		return err
+	}
+	if r.err != nil {
+		return r.err
+	}
+	*v = value
+	return nil
+}
@@ -0,0 +1,137 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// tag returns the encoded tag of a field
+func tag(field int, wire uint64) []byte {
+	return binary.AppendUvarint(nil, uint64(field)<<3|wire)
+}
+
+// concat joins byte slices
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// maxUvarint is the largest length prefix a uvarint can hold
+var maxUvarint = binary.AppendUvarint(nil, 1<<64-1)
+
+func TestZigzag(t *testing.T) {
+	for _, v := range []int64{0, -1, 1, -64, 63, -1 << 63, 1<<63 - 1} {
+		var w Writer
+		w.Int(1, v)
+		r := &Reader{data: w.buf}
+		if !r.Next() || r.Int() != v {
+			t.Errorf("Int(%d) read back as %d (err %v)", v, r.Int(), r.Err())
+		}
+	}
+
+	// Small magnitudes stay one byte either side of zero
+	var w Writer
+	w.Int(1, -64)
+	if len(w.buf) != 2 {
+		t.Errorf("Int(-64) took %d bytes, want a tag and one value byte", len(w.buf))
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated tag", []byte{0x80}},
+		{"truncated varint", concat(tag(1, wireVarint), []byte{0xff, 0xff})},
+		{"overlong varint", concat(tag(1, wireVarint), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})},
+		{"truncated length", concat(tag(3, wireBytes), []byte{0x80})},
+		{"missing length", tag(3, wireBytes)},
+		{"length past the end", concat(tag(3, wireBytes), []byte{5}, []byte("abc"))},
+		{"maximum length", concat(tag(3, wireBytes), maxUvarint, []byte("abc"))},
+		{"length past the end after a field", concat(tag(1, wireVarint), []byte{1}, tag(3, wireBytes), []byte{2, 'a'})},
+		{"unknown wire type", concat(tag(1, 1), []byte{0, 0, 0, 0, 0, 0, 0, 0})},
+		{"group wire type", tag(1, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v record
+			if err := recordBinary.Unmarshal(tt.data, &v); !errors.Is(err, ErrMalformed) {
+				t.Errorf("err = %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestLargeLengthDoesNotAllocate(t *testing.T) {
+	data := concat(tag(3, wireBytes), maxUvarint)
+	allocs := testing.AllocsPerRun(100, func() {
+		r := &Reader{data: data}
+		for r.Next() {
+		}
+		if r.Err() == nil {
+			t.Fatal("huge length accepted")
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("rejecting a huge length allocated %v times", allocs)
+	}
+}
+
+func TestUnknownFieldsSkipped(t *testing.T) {
+	// A newer writer adds fields 6 to 9 between and after the known ones
+	var w Writer
+	w.Int(1, -5)
+	w.Uint(6, 1<<40)
+	w.String(3, "kept")
+	w.Bytes(7, []byte("ignored"))
+	w.Bool(8, true)
+	w.String(9, "")
+
+	var got record
+	if err := recordBinary.Unmarshal(w.buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (record{ID: -5, Name: "kept"}); got.ID != want.ID || got.Name != want.Name || got.Data != nil || got.Active {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestReaderBytesIsACopy(t *testing.T) {
+	var w Writer
+	w.Bytes(4, []byte("abc"))
+	r := &Reader{data: w.buf}
+	r.Next()
+	b := r.Bytes()
+	b[0] = 'X'
+	if r.String() != "abc" {
+		t.Errorf("modifying Bytes changed the data to %q", r.String())
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	good, _ := recordBinary.Marshal(record{ID: -3, Count: 9, Name: "seed", Data: []byte{1, 2}, Active: true})
+	f.Add(good)
+	f.Add(concat(tag(3, wireBytes), maxUvarint))
+	f.Add([]byte{0x80})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v record
+		err := recordBinary.Unmarshal(data, &v)
+		if err != nil && !errors.Is(err, ErrMalformed) {
+			t.Fatalf("err = %v, want ErrMalformed or nil", err)
+		}
+		if err != nil {
+			return
+		}
+		// Whatever decodes re-encodes to something that decodes the same
+		again, _ := recordBinary.Marshal(v)
+		var w record
+		if err := recordBinary.Unmarshal(again, &w); err != nil || w.ID != v.ID || w.Name != v.Name || w.Count != v.Count {
+			t.Fatalf("re-encoded %+v decoded as %+v, %v", v, w, err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+// Package codec converts cached values to and from bytes so they can be
+// persisted by a store.
+//
+// Three codecs are provided: JSON, encoding/gob, and a compact binary format
+// of numbered, length-prefixed fields in the style of protocol buffers. Each
+// codec has an ID that stores record next to the encoded value, so data
+// written with one codec is never decoded with another.
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// IDs of the built-in codecs. Custom codecs should use IDs from 128 up.
+const (
+	IDJSON   byte = 1
+	IDGob    byte = 2
+	IDBinary byte = 3
+)
+
+// Codec encodes and decodes values of type V
+type Codec[V any] interface {
+	// ID identifies the encoding in stored data
+	ID() byte
+
+	// Name returns a short human-readable name
+	Name() string
+
+	// Marshal encodes v
+	Marshal(v V) ([]byte, error)
+
+	// Unmarshal decodes data into v
+	Unmarshal(data []byte, v *V) error
+}
+
+// jsonCodec encodes values with encoding/json
+type jsonCodec[V any] struct{}
+
+// JSON returns a codec using encoding/json. Only exported fields are stored.
+func JSON[V any]() Codec[V] {
+	return jsonCodec[V]{}
+}
+
+func (jsonCodec[V]) ID() byte     { return IDJSON }
+func (jsonCodec[V]) Name() string { return "json" }
+
+func (jsonCodec[V]) Marshal(v V) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec[V]) Unmarshal(data []byte, v *V) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec encodes values with encoding/gob
+type gobCodec[V any] struct{}
+
+// Gob returns a codec using encoding/gob. Every value carries its own type
+// description, so gob entries are larger than binary ones for small values.
+func Gob[V any]() Codec[V] {
+	return gobCodec[V]{}
+}
+
+func (gobCodec[V]) ID() byte     { return IDGob }
+func (gobCodec[V]) Name() string { return "gob" }
+
+func (gobCodec[V]) Marshal(v V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[V]) Unmarshal(data []byte, v *V) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
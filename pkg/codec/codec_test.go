@@ -0,0 +1,84 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+)
+
+// record exercises every kind of field the binary format has
+type record struct {
+	ID     int64
+	Count  uint64
+	Name   string
+	Data   []byte
+	Active bool
+}
+
+// recordBinary encodes records with field numbers 1 to 5
+var recordBinary = Binary(
+	func(w *Writer, v record) {
+		w.Int(1, v.ID)
+		w.Uint(2, v.Count)
+		w.String(3, v.Name)
+		w.Bytes(4, v.Data)
+		w.Bool(5, v.Active)
+	},
+	func(r *Reader) (record, error) {
+		var v record
+		for r.Next() {
+			switch r.Field() {
+			case 1:
+				v.ID = r.Int()
+			case 2:
+				v.Count = r.Uint()
+			case 3:
+				v.Name = r.String()
+			case 4:
+				v.Data = r.Bytes()
+			case 5:
+				v.Active = r.Bool()
+			}
+		}
+		return v, r.Err()
+	},
+)
+
+func TestRoundTrip(t *testing.T) {
+	values := []record{
+		{},
+		{ID: 42, Count: 7, Name: "ada", Data: []byte{0, 1, 255}, Active: true},
+		{ID: -1 << 63, Count: 1<<64 - 1, Name: "ünïcode ✓", Data: make([]byte, 1000)},
+		{ID: 1<<63 - 1, Name: string(make([]byte, 300))},
+	}
+	for _, c := range []Codec[record]{JSON[record](), Gob[record](), recordBinary} {
+		t.Run(c.Name(), func(t *testing.T) {
+			for _, want := range values {
+				data, err := c.Marshal(want)
+				if err != nil {
+					t.Fatalf("Marshal(%+v): %v", want, err)
+				}
+				var got record
+				if err := c.Unmarshal(data, &got); err != nil {
+					t.Fatalf("Unmarshal of %+v: %v", want, err)
+				}
+				// JSON and gob decode an empty slice as nil
+				if len(want.Data) == 0 {
+					got.Data, want.Data = nil, nil
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("round trip = %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCodecIDs(t *testing.T) {
+	seen := make(map[byte]string)
+	for _, c := range []Codec[record]{JSON[record](), Gob[record](), recordBinary} {
+		if other, ok := seen[c.ID()]; ok {
+			t.Errorf("%s and %s share ID %d", c.Name(), other, c.ID())
+		}
+		seen[c.ID()] = c.Name()
+	}
+}
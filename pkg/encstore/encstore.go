@@ -0,0 +1,370 @@
+// Package encstore encrypts cache entries before they reach a backing store.
+//
+// A Store wraps any store.Store that holds bytes, such as the file store
+// returned by store.NewFileStore. Values are encoded with a codec and sealed
+// with AES-GCM under a fresh random nonce, so nothing written to disk can be
+// read or altered without the key. Entries record the ID of the key that
+// sealed them; after a key rotation, entries are re-encrypted with the new
+// key the next time they are read.
+//
+// Example usage:
+//
+//	files, _ := store.NewFileStore[string, []byte](ctx, config)
+//	keys, _ := encstore.NewKeyring("2025-01", key)
+//	sessions := encstore.New(files, codec.JSON[Session](), keys)
+//	cache := gencache.New[string, Session](gencache.WithStore[string, Session](sessions))
+package encstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/gencache/store"
+)
+
+// envelopeVersion is the layout of sealed entries written by this package:
+// version, key ID length, key ID, expiry (unix nanoseconds, 0 for never),
+// nonce, then the AES-GCM ciphertext. Everything before the nonce is
+// authenticated along with the cache key.
+const envelopeVersion = 1
+
+// Errors returned by Load
+var (
+	ErrNotFound = errors.New("encstore: entry not found")
+	ErrExpired  = errors.New("encstore: entry expired")
+	ErrDecrypt  = errors.New("encstore: entry cannot be decrypted")
+)
+
+// Stats counts decryptions and lazy re-encryptions
+type Stats struct {
+	Decrypted   int64 // entries decrypted successfully
+	Failed      int64 // entries that could not be decrypted
+	Reencrypted int64 // entries re-sealed with the current key after a rotation
+}
+
+// stats holds the live counters behind Stats
+type stats struct {
+	decrypted, failed, reencrypted atomic.Int64
+}
+
+// Store encrypts values before storing them in an inner store. It is safe
+// for concurrent use if the inner store is.
+type Store[K comparable, V any] struct {
+	inner store.Store[K, []byte]
+	codec codec.Codec[V]
+	keys  KeyProvider
+	clock clock.Clock
+
+	// Serializes writes so a lazy re-encryption cannot overwrite a newer value
+	mu    sync.Mutex
+	stats stats
+}
+
+var _ store.Store[string, string] = (*Store[string, string])(nil)
+
+// Option configures a Store
+type Option func(*options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock sets the clock used to expire entries
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New returns a store encoding values with c, encrypting them with keys and
+// keeping them in inner
+func New[K comparable, V any](inner store.Store[K, []byte], c codec.Codec[V], keys KeyProvider, opts ...Option) *Store[K, V] {
+	o := options{clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Store[K, V]{
+		inner: inner,
+		codec: c,
+		keys:  keys,
+		clock: o.clock,
+	}
+}
+
+// Load returns the value of key, or ErrNotFound, ErrExpired, ErrUnknownKey
+// or ErrDecrypt. An entry sealed with a key other than the current one is
+// re-encrypted with the current key before Load returns.
+func (s *Store[K, V]) Load(ctx context.Context, key K) (V, error) {
+	var zero V
+	sealed, ok := s.inner.Get(ctx, key)
+	if !ok {
+		return zero, ErrNotFound
+	}
+
+	env, err := s.open(key, sealed)
+	if err != nil {
+		s.stats.failed.Add(1)
+		return zero, err
+	}
+	if !env.expires.IsZero() && !s.clock.Now().Before(env.expires) {
+		return zero, ErrExpired
+	}
+
+	var value V
+	if err := s.codec.Unmarshal(env.plaintext, &value); err != nil {
+		s.stats.failed.Add(1)
+		return zero, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	s.stats.decrypted.Add(1)
+
+	if current, _, err := s.keys.Current(); err == nil && current != env.keyID {
+		s.reencrypt(ctx, key, sealed, env)
+	}
+	return value, nil
+}
+
+// KeyID returns the ID of the key the entry of key is sealed with
+func (s *Store[K, V]) KeyID(ctx context.Context, key K) (string, error) {
+	sealed, ok := s.inner.Get(ctx, key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	id, _, _, _, err := parseEnvelope(sealed)
+	return id, err
+}
+
+// Get returns the value of key. Entries that cannot be decrypted are
+// reported as missing; use Load to find out why.
+func (s *Store[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	value, err := s.Load(ctx, key)
+	return value, err == nil
+}
+
+// Set encrypts value with the current key and stores it
+func (s *Store[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	plaintext, err := s.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encstore: encoding %v: %w", key, err)
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = s.clock.Now().Add(ttl)
+	}
+	sealed, err := s.seal(key, plaintext, expires)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Set(ctx, key, sealed, ttl)
+}
+
+// Delete removes key from the inner store
+func (s *Store[K, V]) Delete(ctx context.Context, key K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Delete(ctx, key)
+}
+
+// Clear removes every entry from the inner store
+func (s *Store[K, V]) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.Clear(ctx)
+}
+
+// Size returns the number of entries in the inner store
+func (s *Store[K, V]) Size(ctx context.Context) int {
+	return s.inner.Size(ctx)
+}
+
+// Capacity returns the capacity of the inner store
+func (s *Store[K, V]) Capacity(ctx context.Context) int {
+	return s.inner.Capacity(ctx)
+}
+
+// Keys returns the keys of the inner store. Keys are not encrypted.
+func (s *Store[K, V]) Keys(ctx context.Context) []K {
+	return s.inner.Keys(ctx)
+}
+
+// MemoryUsage returns the memory usage of the inner store
+func (s *Store[K, V]) MemoryUsage(ctx context.Context) int64 {
+	return s.inner.MemoryUsage(ctx)
+}
+
+// MaxMemory returns the memory limit of the inner store
+func (s *Store[K, V]) MaxMemory(ctx context.Context) int64 {
+	return s.inner.MaxMemory(ctx)
+}
+
+// GetMany returns the values of the keys that could be decrypted
+func (s *Store[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := s.Get(ctx, key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// SetMany encrypts and stores every entry, stopping at the first error
+func (s *Store[K, V]) SetMany(ctx context.Context, entries map[K]V, ttl time.Duration) error {
+	for key, value := range entries {
+		if err := s.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMany removes the keys from the inner store
+func (s *Store[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inner.DeleteMany(ctx, keys)
+}
+
+// Close closes the inner store
+func (s *Store[K, V]) Close(ctx context.Context) error {
+	return s.inner.Close(ctx)
+}
+
+// Stats returns a snapshot of the counters
+func (s *Store[K, V]) Stats() Stats {
+	return Stats{
+		Decrypted:   s.stats.decrypted.Load(),
+		Failed:      s.stats.failed.Load(),
+		Reencrypted: s.stats.reencrypted.Load(),
+	}
+}
+
+// envelope is an opened entry
+type envelope struct {
+	keyID     string
+	expires   time.Time
+	plaintext []byte
+}
+
+// seal encrypts plaintext with the current key
+func (s *Store[K, V]) seal(key K, plaintext []byte, expires time.Time) ([]byte, error) {
+	id, secret, err := s.keys.Current()
+	if err != nil {
+		return nil, fmt.Errorf("encstore: current key: %w", err)
+	}
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var nanos int64
+	if !expires.IsZero() {
+		nanos = expires.UnixNano()
+	}
+	header := []byte{envelopeVersion, byte(len(id))}
+	header = append(header, id...)
+	header = binary.BigEndian.AppendUint64(header, uint64(nanos))
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("encstore: generating nonce: %w", err)
+	}
+	sealed := append(header, nonce...)
+	return aead.Seal(sealed, nonce, plaintext, additionalData(header, key)), nil
+}
+
+// open authenticates and decrypts a sealed entry
+func (s *Store[K, V]) open(key K, sealed []byte) (envelope, error) {
+	id, expires, header, rest, err := parseEnvelope(sealed)
+	if err != nil {
+		return envelope{}, err
+	}
+	secret, err := s.keys.Key(id)
+	if err != nil {
+		return envelope{}, err
+	}
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return envelope{}, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return envelope{}, fmt.Errorf("%w: truncated", ErrDecrypt)
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(header, key))
+	if err != nil {
+		return envelope{}, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return envelope{keyID: id, expires: expires, plaintext: plaintext}, nil
+}
+
+// reencrypt seals an entry read with an old key using the current key,
+// unless the entry has changed since it was read. Failures are ignored: the
+// entry stays readable with the old key and is retried on the next read.
+func (s *Store[K, V]) reencrypt(ctx context.Context, key K, old []byte, env envelope) {
+	var ttl time.Duration
+	if !env.expires.IsZero() {
+		if ttl = env.expires.Sub(s.clock.Now()); ttl <= 0 {
+			return
+		}
+	}
+	sealed, err := s.seal(key, env.plaintext, env.expires)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.inner.Get(ctx, key); !ok || !bytes.Equal(current, old) {
+		return
+	}
+	if s.inner.Set(ctx, key, sealed, ttl) == nil {
+		s.stats.reencrypted.Add(1)
+	}
+}
+
+// parseEnvelope splits a sealed entry into its key ID, expiry, the
+// authenticated header and the nonce and ciphertext that follow it
+func parseEnvelope(sealed []byte) (id string, expires time.Time, header, rest []byte, err error) {
+	if len(sealed) < 2 || sealed[0] != envelopeVersion {
+		return "", time.Time{}, nil, nil, fmt.Errorf("%w: unknown envelope", ErrDecrypt)
+	}
+	idLen := int(sealed[1])
+	end := 2 + idLen + 8
+	if len(sealed) < end {
+		return "", time.Time{}, nil, nil, fmt.Errorf("%w: truncated", ErrDecrypt)
+	}
+	id = string(sealed[2 : 2+idLen])
+	if nanos := int64(binary.BigEndian.Uint64(sealed[2+idLen : end])); nanos != 0 {
+		expires = time.Unix(0, nanos)
+	}
+	return id, expires, sealed[:end], sealed[end:], nil
+}
+
+// additionalData binds a sealed entry to its header and cache key, so an
+// entry copied to another key fails to decrypt
+func additionalData[K comparable](header []byte, key K) []byte {
+	return fmt.Appendf(append([]byte(nil), header...), "%v", key)
+}
+
+// newAEAD returns AES-GCM for secret
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, fmt.Errorf("encstore: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
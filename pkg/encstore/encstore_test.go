@@ -0,0 +1,287 @@
+package encstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/gencache/store"
+)
+
+// session is the value stored in the tests
+type session struct {
+	User  string
+	Roles []string
+}
+
+// key returns a 32-byte AES key filled with b
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+// hookedStore is an inner store that runs afterGet once, after the next Get
+type hookedStore struct {
+	store.Store[string, []byte]
+	afterGet func()
+}
+
+func (h *hookedStore) Get(ctx context.Context, key string) ([]byte, bool) {
+	value, ok := h.Store.Get(ctx, key)
+	if hook := h.afterGet; hook != nil {
+		h.afterGet = nil
+		hook()
+	}
+	return value, ok
+}
+
+// newStore returns an encrypting store over a memory store, with a keyring
+// whose current key is "k1" and a fake clock
+func newStore(t *testing.T) (*Store[string, session], *hookedStore, *Keyring, *clock.Fake) {
+	t.Helper()
+	ctx := context.Background()
+	mem, err := store.NewMemoryStore[string, []byte](ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mem.Close(ctx) })
+	inner := &hookedStore{Store: mem}
+
+	keys, err := NewKeyring("k1", key(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(clock.Epoch)
+	return New(inner, codec.JSON[session](), keys, WithClock(clk)), inner, keys, clk
+}
+
+// sealed returns the raw entry of key in inner
+func sealed(t *testing.T, inner store.Store[string, []byte], key string) []byte {
+	t.Helper()
+	data, ok := inner.Get(context.Background(), key)
+	if !ok {
+		t.Fatalf("%s not in the inner store", key)
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, inner, _, _ := newStore(t)
+	want := session{User: "ada", Roles: []string{"admin"}}
+
+	if err := s.Set(ctx, "s1", want, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Load(ctx, "s1")
+	if err != nil || got.User != want.User || len(got.Roles) != 1 || got.Roles[0] != "admin" {
+		t.Fatalf("Load = %+v, %v; want %+v", got, err, want)
+	}
+	if bytes.Contains(sealed(t, inner, "s1"), []byte("ada")) {
+		t.Error("the inner store holds the plaintext")
+	}
+	if _, err := s.Load(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load of a missing key: err = %v, want ErrNotFound", err)
+	}
+
+	// Sealing twice uses fresh nonces
+	if err := s.Set(ctx, "s2", want, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	first, second := sealed(t, inner, "s1"), sealed(t, inner, "s2")
+	if bytes.Equal(first[len(first)-20:], second[len(second)-20:]) {
+		t.Error("two entries of the same value share their ciphertext")
+	}
+	if stats := s.Stats(); stats.Decrypted != 1 || stats.Failed != 0 {
+		t.Errorf("Stats() = %+v, want one decryption", stats)
+	}
+}
+
+func TestTamperedEntries(t *testing.T) {
+	ctx := context.Background()
+	s, inner, _, _ := newStore(t)
+	if err := s.Set(ctx, "s", session{User: "ada"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	good := sealed(t, inner, "s")
+	headerLen := 2 + len("k1") + 8
+
+	flip := func(i int) []byte {
+		b := bytes.Clone(good)
+		b[i] ^= 1
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"flipped ciphertext byte", flip(len(good) - 1)},
+		{"flipped nonce byte", flip(headerLen)},
+		{"flipped expiry", flip(headerLen - 1)},
+		{"unknown version", flip(0)},
+		{"truncated header", good[:4]},
+		{"truncated nonce", good[:headerLen+5]},
+		{"truncated ciphertext", good[:len(good)-1]},
+		{"empty", []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := inner.Set(ctx, "s", tt.data, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Load(ctx, "s"); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("err = %v, want ErrDecrypt", err)
+			}
+			if _, ok := s.Get(ctx, "s"); ok {
+				t.Error("Get reported a tampered entry as present")
+			}
+		})
+	}
+	if stats := s.Stats(); stats.Failed != int64(2*len(tests)) || stats.Decrypted != 0 {
+		t.Errorf("Stats() = %+v, want %d failures", stats, 2*len(tests))
+	}
+}
+
+func TestEntryCopiedToAnotherKey(t *testing.T) {
+	ctx := context.Background()
+	s, inner, _, _ := newStore(t)
+	if err := s.Set(ctx, "alice", session{User: "alice", Roles: []string{"admin"}}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.Set(ctx, "mallory", sealed(t, inner, "alice"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(ctx, "mallory"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("err = %v, want ErrDecrypt for an entry moved to another key", err)
+	}
+}
+
+func TestRetiredKey(t *testing.T) {
+	ctx := context.Background()
+	s, _, keys, _ := newStore(t)
+	if err := s.Set(ctx, "s", session{User: "ada"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Retire("k1"); err == nil {
+		t.Fatal("retired the current key")
+	}
+	if err := keys.Rotate("k2", key(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Retire("k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Load(ctx, "s"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRotationReencryptsOnRead(t *testing.T) {
+	ctx := context.Background()
+	s, inner, keys, clk := newStore(t)
+	if err := s.Set(ctx, "s", session{User: "ada"}, 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Minute)
+	if err := keys.Rotate("k2", key(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := s.KeyID(ctx, "s"); id != "k1" {
+		t.Fatalf("KeyID before the read = %q, want k1", id)
+	}
+	if got, err := s.Load(ctx, "s"); err != nil || got.User != "ada" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+	if id, _ := s.KeyID(ctx, "s"); id != "k2" {
+		t.Errorf("KeyID after the read = %q, want k2", id)
+	}
+	if n := s.Stats().Reencrypted; n != 1 {
+		t.Errorf("Reencrypted = %d, want 1", n)
+	}
+
+	// The entry keeps its original expiry
+	_, expires, _, _, err := parseEnvelope(sealed(t, inner, "s"))
+	if err != nil || !expires.Equal(clock.Epoch.Add(10*time.Minute)) {
+		t.Errorf("expiry after re-encryption = %v, %v; want %v", expires, err, clock.Epoch.Add(10*time.Minute))
+	}
+
+	// With k1 gone the entry is still readable, and is not re-encrypted again
+	if err := keys.Retire("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Load(ctx, "s"); err != nil || got.User != "ada" {
+		t.Errorf("Load after retiring k1 = %+v, %v", got, err)
+	}
+	if n := s.Stats().Reencrypted; n != 1 {
+		t.Errorf("Reencrypted = %d after a read under the current key, want 1", n)
+	}
+}
+
+func TestReencryptKeepsConcurrentWrite(t *testing.T) {
+	ctx := context.Background()
+	s, inner, keys, _ := newStore(t)
+	if err := s.Set(ctx, "s", session{User: "old"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Rotate("k2", key(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A writer replaces the entry between the read and its re-encryption
+	inner.afterGet = func() {
+		if err := s.Set(ctx, "s", session{User: "new"}, time.Hour); err != nil {
+			t.Error(err)
+		}
+	}
+	if got, err := s.Load(ctx, "s"); err != nil || got.User != "old" {
+		t.Fatalf("Load = %+v, %v; want the value it read", got, err)
+	}
+
+	if got, err := s.Load(ctx, "s"); err != nil || got.User != "new" {
+		t.Errorf("Load after the race = %+v, %v; want the concurrent write", got, err)
+	}
+	if n := s.Stats().Reencrypted; n != 0 {
+		t.Errorf("Reencrypted = %d, want the stale re-encryption skipped", n)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, clk := newStore(t)
+	if err := s.Set(ctx, "short", session{User: "ada"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "forever", session{User: "bob"}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(59 * time.Second)
+	if _, err := s.Load(ctx, "short"); err != nil {
+		t.Fatalf("Load before the TTL: %v", err)
+	}
+	clk.Advance(time.Second)
+	if _, err := s.Load(ctx, "short"); !errors.Is(err, ErrExpired) {
+		t.Errorf("err = %v, want ErrExpired", err)
+	}
+	clk.Advance(24 * time.Hour)
+	if got, err := s.Load(ctx, "forever"); err != nil || got.User != "bob" {
+		t.Errorf("entry without TTL = %+v, %v", got, err)
+	}
+}
+
+func TestKeyringRejectsBadKeys(t *testing.T) {
+	if _, err := NewKeyring("k", make([]byte, 20)); err == nil {
+		t.Error("accepted a 20-byte key")
+	}
+	if _, err := NewKeyring("", key(1)); err == nil {
+		t.Error("accepted an empty key ID")
+	}
+	if _, err := NewKeyring(string(make([]byte, 256)), key(1)); err == nil {
+		t.Error("accepted a 256-byte key ID")
+	}
+}
@@ -0,0 +1,84 @@
+package encstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownKey is returned for a key ID the provider does not hold
+var ErrUnknownKey = errors.New("encstore: unknown encryption key")
+
+// KeyProvider supplies the AES keys entries are encrypted with. Keys must
+// be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256).
+type KeyProvider interface {
+	// Current returns the key new entries are encrypted with and its ID
+	Current() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, to decrypt older entries
+	Key(id string) ([]byte, error)
+}
+
+// Keyring is an in-memory KeyProvider supporting rotation. It is safe for
+// concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring returns a keyring whose current key is key
+func NewKeyring(id string, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate adds key and makes it current. Older keys are kept so existing
+// entries can still be read, and re-encrypted with key as they are.
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if n := len(key); n != 16 && n != 24 && n != 32 {
+		return fmt.Errorf("encstore: key %q is %d bytes, want 16, 24 or 32", id, n)
+	}
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("encstore: key ID must be 1 to 255 bytes")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = append([]byte(nil), key...)
+	k.current = id
+	return nil
+}
+
+// Retire removes a key that is no longer current. Entries still encrypted
+// with it can no longer be read.
+func (k *Keyring) Retire(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.current {
+		return fmt.Errorf("encstore: cannot retire the current key %q", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// Current returns the current key and its ID
+func (k *Keyring) Current() (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current, k.keys[k.current], nil
+}
+
+// Key returns the key with the given ID
+func (k *Keyring) Key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
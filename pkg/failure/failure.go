@@ -0,0 +1,40 @@
+// Package failure provides predicates deciding which errors should count
+// against a circuit breaker. They plug into cbreak.Config.ErrorClassifier.
+//
+// Errors the predicate rejects are still returned to the caller by
+// Execute, they just do not move the breaker towards Open.
+package failure
+
+import (
+	"context"
+	"errors"
+)
+
+// Coder is implemented by errors that carry an HTTP-style status code
+type Coder interface {
+	StatusCode() int
+}
+
+// StatusCode returns the status code carried by err, if any
+func StatusCode(err error) (int, bool) {
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.StatusCode(), true
+	}
+	return 0, false
+}
+
+// ServerErrors counts 5xx coded errors and errors without a code as failures.
+// Coded errors outside the 5xx range (such as 400 or 404) are the caller's
+// fault and say nothing about the health of the dependency, and neither does
+// a call the caller cancelled. Deadline errors still count.
+func ServerErrors(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	code, ok := StatusCode(err)
+	if !ok {
+		return true
+	}
+	return code >= 500 && code < 600
+}
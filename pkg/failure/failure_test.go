@@ -0,0 +1,102 @@
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/cbreak"
+)
+
+// statusError is an error carrying a status code
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func TestServerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"400", statusError(400), false},
+		{"404", statusError(404), false},
+		{"429", statusError(429), false},
+		{"499", statusError(499), false},
+		{"500", statusError(500), true},
+		{"503", statusError(503), true},
+		{"599", statusError(599), true},
+		{"600", statusError(600), false},
+		{"wrapped 404", fmt.Errorf("fetching user: %w", statusError(404)), false},
+		{"wrapped 502", fmt.Errorf("fetching user: %w", statusError(502)), true},
+		{"joined 404", errors.Join(errors.New("retry failed"), statusError(404)), false},
+		{"uncoded", errors.New("connection refused"), true},
+		{"canceled", context.Canceled, false},
+		{"wrapped canceled", fmt.Errorf("query: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServerErrors(tt.err); got != tt.want {
+				t.Errorf("ServerErrors(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	if code, ok := StatusCode(fmt.Errorf("wrapped: %w", statusError(418))); !ok || code != 418 {
+		t.Errorf("StatusCode = %d, %t; want 418, true", code, ok)
+	}
+	if _, ok := StatusCode(errors.New("plain")); ok {
+		t.Error("StatusCode of an uncoded error reported a code")
+	}
+}
+
+// drive runs every error in errs through a breaker classifying with
+// ServerErrors and returns its final state
+func drive(t *testing.T, errs []error) cbreak.State {
+	t.Helper()
+	config := cbreak.DefaultConfig("failure-test")
+	config.FailureThreshold = 3
+	config.FailureRateThreshold = 100
+	config.Timeout = time.Hour
+	config.ErrorClassifier = ServerErrors
+	breaker, err := cbreak.NewBreaker[string](config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer breaker.Shutdown()
+
+	for _, e := range errs {
+		_, got := breaker.Execute(context.Background(), func() (string, error) {
+			return "", e
+		})
+		if got == nil {
+			t.Fatalf("Execute swallowed %v", e)
+		}
+	}
+	return breaker.GetState()
+}
+
+func TestClientErrorsKeepBreakerClosed(t *testing.T) {
+	var errs []error
+	for i := 0; i < 20; i++ {
+		errs = append(errs, statusError(400+i%30))
+	}
+	if state := drive(t, errs); state != cbreak.Closed {
+		t.Errorf("breaker driven by 4xx errors is %v, want closed", state)
+	}
+}
+
+func TestServerErrorsOpenBreaker(t *testing.T) {
+	errs := []error{statusError(404), statusError(500), statusError(502), statusError(503)}
+	if state := drive(t, errs); state != cbreak.Open {
+		t.Errorf("breaker driven by 5xx errors is %v, want open", state)
+	}
+}
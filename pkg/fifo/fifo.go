@@ -0,0 +1,111 @@
+// Package fifo is a hand-written FIFO (First In, First Out) eviction policy
+// for gencache, kept small to show what implementing policy.Policy takes.
+//
+// The policy only keeps the insertion order; the cache decides when to evict
+// and calls Evict to pick the victim, so the cache must be created with
+// WithMaxSize set to the policy's capacity:
+//
+//	p := fifo.New[string, string](100)
+//	cache := gencache.New[string, string](
+//		gencache.WithMaxSize[string, string](p.Capacity()),
+//		gencache.WithPolicy[string, string](p),
+//	)
+package fifo
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Policy evicts the key that was inserted first. It is safe for concurrent use.
+type Policy[K comparable, V any] struct {
+	mu       sync.Mutex
+	order    *list.List // keys, oldest at the front
+	index    map[K]*list.Element
+	capacity int
+}
+
+// New creates a FIFO policy for a cache holding capacity entries
+func New[K comparable, V any](capacity int) *Policy[K, V] {
+	return &Policy[K, V]{
+		order:    list.New(),
+		index:    make(map[K]*list.Element, capacity),
+		capacity: capacity,
+	}
+}
+
+// OnGet is called when an item is retrieved from the cache. Reads do not
+// change the eviction order.
+func (p *Policy[K, V]) OnGet(key K, value V) {}
+
+// OnSet is called when an item is added to the cache. Updating a key keeps
+// its original position in the queue.
+func (p *Policy[K, V]) OnSet(key K, value V, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.index[key]; exists {
+		return
+	}
+	p.index[key] = p.order.PushBack(key)
+}
+
+// OnDelete is called when an item is removed from the cache
+func (p *Policy[K, V]) OnDelete(key K) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, exists := p.index[key]; exists {
+		p.order.Remove(element)
+		delete(p.index, key)
+	}
+}
+
+// OnClear is called when the cache is cleared
+func (p *Policy[K, V]) OnClear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.order.Init()
+	p.index = make(map[K]*list.Element, p.capacity)
+}
+
+// Evict removes and returns the oldest key
+func (p *Policy[K, V]) Evict() (K, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element := p.order.Front()
+	if element == nil {
+		var zero K
+		return zero, false
+	}
+	key := p.order.Remove(element).(K)
+	delete(p.index, key)
+	return key, true
+}
+
+// Size returns the number of keys tracked by the policy
+func (p *Policy[K, V]) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.order.Len()
+}
+
+// Capacity returns the maximum number of keys the policy is sized for
+func (p *Policy[K, V]) Capacity() int {
+	return p.capacity
+}
+
+// Keys returns the tracked keys, oldest first
+func (p *Policy[K, V]) Keys() []K {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := make([]K, 0, p.order.Len())
+	for element := p.order.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(K))
+	}
+	return keys
+}
@@ -0,0 +1,126 @@
+package fifo
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
+)
+
+// Policy must plug into gencache.WithPolicy
+var _ policy.Policy[string, string] = (*Policy[string, string])(nil)
+
+// newCache creates a cache bounded by the capacity of p
+func newCache(p *Policy[string, string]) gencache.Cache[string, string] {
+	return gencache.New[string, string](
+		gencache.WithMaxSize[string, string](p.Capacity()),
+		gencache.WithPolicy[string, string](p),
+	)
+}
+
+func TestEvictionOrder(t *testing.T) {
+	p := New[string, string](3)
+	for _, key := range []string{"a", "b", "c", "a"} {
+		p.OnSet(key, key, time.Minute)
+	}
+	p.OnGet("a", "a")
+	if got, want := p.Keys(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("Keys() = %v, want %v", got, want)
+	}
+
+	p.OnDelete("b")
+	if key, ok := p.Evict(); !ok || key != "a" {
+		t.Errorf("Evict() = %q, %t, want a, true", key, ok)
+	}
+	if key, ok := p.Evict(); !ok || key != "c" {
+		t.Errorf("Evict() = %q, %t, want c, true", key, ok)
+	}
+	if key, ok := p.Evict(); ok {
+		t.Errorf("Evict() on an empty policy = %q, true", key)
+	}
+
+	p.OnSet("d", "d", time.Minute)
+	p.OnClear()
+	if size := p.Size(); size != 0 {
+		t.Errorf("Size() after OnClear = %d, want 0", size)
+	}
+}
+
+func TestCacheEvictsOldest(t *testing.T) {
+	p := New[string, string](3)
+	cache := newCache(p)
+	defer cache.Close()
+
+	for _, key := range []string{"key1", "key2", "key3", "key4"} {
+		if err := cache.Set(key, key, time.Minute); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+	if _, err := cache.Get("key1"); err == nil {
+		t.Error("key1 survived the overflow")
+	}
+	if got, want := p.Keys(), []string{"key2", "key3", "key4"}; !slices.Equal(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+}
+
+// TestConcurrentStress runs concurrent sets, gets and deletes against a small
+// cache and checks after every round that the policy tracks exactly the keys
+// the cache holds. Run it with -race to check the locking.
+func TestConcurrentStress(t *testing.T) {
+	const (
+		capacity = 16
+		keySpace = 64
+		workers  = 8
+		ops      = 500
+		rounds   = 20
+	)
+
+	p := New[string, string](capacity)
+	cache := newCache(p)
+	defer cache.Close()
+
+	for round := 1; round <= rounds; round++ {
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(seed))
+				for i := 0; i < ops; i++ {
+					key := fmt.Sprintf("key%d", rng.Intn(keySpace))
+					switch n := rng.Intn(10); {
+					case n < 5:
+						_ = cache.Set(key, key, time.Minute)
+					case n < 8:
+						_, _ = cache.Get(key)
+					default:
+						_ = cache.Delete(key)
+					}
+				}
+			}(int64(round*workers + w))
+		}
+		wg.Wait()
+
+		// Compare the policy's bookkeeping with the keys the cache actually holds
+		var present []string
+		for i := 0; i < keySpace; i++ {
+			key := fmt.Sprintf("key%d", i)
+			if _, err := cache.Get(key); err == nil {
+				present = append(present, key)
+			}
+		}
+		tracked := p.Keys()
+		slices.Sort(tracked)
+		slices.Sort(present)
+		if !slices.Equal(tracked, present) || len(tracked) > capacity {
+			t.Fatalf("round %d: policy tracks %v, cache holds %v (capacity %d)",
+				round, tracked, present, capacity)
+		}
+	}
+}
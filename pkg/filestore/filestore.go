@@ -0,0 +1,702 @@
+// Package filestore is a gencache store.Store that keeps one file per entry
+// and encodes values with a pluggable codec.
+//
+// Every file starts with a small header recording the codec and the schema
+// version of the store that wrote it. Reopening a store with a different
+// codec or schema version does not misread old entries: they are reported
+// as mismatches and treated as misses until they are overwritten.
+//
+// Entries are checksummed and written to a temporary file that is renamed
+// into place, so a crash mid-write leaves the previous entry intact. Entries
+// that fail their checksum are moved to a quarantine directory for
+// inspection instead of being decoded.
+//
+// By default a store assumes it is the only user of its directory. With
+// WithSharedDirectory, processes on the same machine can share one
+// directory: operations take advisory file locks and every change bumps an
+// on-disk generation counter.
+//
+// Example usage:
+//
+//	users, err := filestore.New[string, *User]("/var/cache/users", codec.JSON[*User](),
+//	    filestore.WithSchemaVersion(2),
+//	)
+//	cache := gencache.New[string, *User](gencache.WithStore[string, *User](users))
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/gencache/store"
+)
+
+// Errors returned by Load and Inspect
+var (
+	ErrNotFound       = errors.New("filestore: entry not found")
+	ErrExpired        = errors.New("filestore: entry expired")
+	ErrCorrupt        = errors.New("filestore: corrupt entry")
+	ErrCodecMismatch  = errors.New("filestore: entry written with another codec")
+	ErrSchemaMismatch = errors.New("filestore: entry written with another schema version")
+	ErrFull           = errors.New("filestore: store is full")
+	ErrClosed         = errors.New("filestore: store is closed")
+)
+
+// DefaultExtension is the file extension of entries unless WithExtension is given
+const DefaultExtension = ".entry"
+
+// QuarantineDir is the subdirectory corrupt entries are moved to unless
+// WithQuarantine is given
+const QuarantineDir = "quarantine"
+
+// tempPrefix starts the names of files being written. Temp files older than
+// staleTempAge were left by a writer that died and are removed by New.
+const (
+	tempPrefix   = ".tmp-"
+	staleTempAge = time.Minute
+)
+
+// Stats counts how reads were served
+type Stats struct {
+	Hits       int64 // entries decoded successfully
+	Misses     int64 // keys with no entry
+	Expired    int64 // entries found expired and removed
+	Mismatches int64 // entries written with another codec or schema version
+	Corrupt    int64 // entries that could not be parsed or decoded
+	Quarantine int64 // corrupt entries moved out of the store
+}
+
+// stats holds the live counters behind Stats
+type stats struct {
+	hits, misses, expired, mismatches, corrupt, quarantine atomic.Int64
+}
+
+// Store keeps entries in a directory, one file per key. It is safe for
+// concurrent use within a process, and across processes when opened with
+// WithSharedDirectory.
+type Store[K comparable, V any] struct {
+	dir        string
+	ext        string
+	codec      codec.Codec[V]
+	schema     uint32
+	maxSize    int
+	quarantine string
+	clock      clock.Clock
+
+	mu      sync.RWMutex
+	closed  bool
+	changed bool // the directory changed under the current write lock
+	stats   stats
+
+	shared    *sharedDir // nil unless opened with WithSharedDirectory
+	readersMu sync.Mutex
+	readers   int // goroutines holding the shared file lock
+}
+
+var _ store.Store[string, string] = (*Store[string, string])(nil)
+
+// Option configures a Store
+type Option func(*options)
+
+type options struct {
+	ext        string
+	schema     uint32
+	maxSize    int
+	quarantine *string
+	shared     bool
+	clock      clock.Clock
+}
+
+// WithExtension sets the file extension of entries (defaults to DefaultExtension)
+func WithExtension(ext string) Option {
+	return func(o *options) {
+		o.ext = ext
+	}
+}
+
+// WithSchemaVersion sets the schema version recorded in new entries and
+// required of existing ones (defaults to 1). Bump it whenever the encoded
+// form of V changes incompatibly.
+func WithSchemaVersion(version uint32) Option {
+	return func(o *options) {
+		o.schema = version
+	}
+}
+
+// WithMaxSize limits the number of entries; zero means unlimited
+func WithMaxSize(n int) Option {
+	return func(o *options) {
+		o.maxSize = n
+	}
+}
+
+// WithQuarantine sets the directory corrupt entries are moved to (defaults
+// to QuarantineDir inside the store directory). An empty dir deletes corrupt
+// entries instead.
+func WithQuarantine(dir string) Option {
+	return func(o *options) {
+		o.quarantine = &dir
+	}
+}
+
+// WithSharedDirectory makes the store safe to use from several processes
+// sharing dir. Reads take a shared flock and writes an exclusive one, which
+// serializes reads and writes within this process as well. Only supported
+// on unix systems.
+func WithSharedDirectory() Option {
+	return func(o *options) {
+		o.shared = true
+	}
+}
+
+// WithClock sets the clock used to expire entries
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New opens the store in dir, creating the directory if needed, and encodes
+// values with c. Temp files left behind by interrupted writes are removed.
+func New[K comparable, V any](dir string, c codec.Codec[V], opts ...Option) (*Store[K, V], error) {
+	o := options{
+		ext:    DefaultExtension,
+		schema: 1,
+		clock:  clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	quarantine := filepath.Join(dir, QuarantineDir)
+	if o.quarantine != nil {
+		quarantine = *o.quarantine
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("filestore: creating %s: %w", dir, err)
+	}
+	s := &Store[K, V]{
+		dir:        dir,
+		ext:        o.ext,
+		codec:      c,
+		schema:     o.schema,
+		maxSize:    o.maxSize,
+		quarantine: quarantine,
+		clock:      o.clock,
+	}
+	if o.shared {
+		shared, err := openShared(dir)
+		if err != nil {
+			return nil, err
+		}
+		s.shared = shared
+	}
+
+	if err := s.lock(); err != nil {
+		s.Close(context.Background())
+		return nil, err
+	}
+	s.removeStaleTemps()
+	s.unlock()
+	return s, nil
+}
+
+// Load returns the value of key, or an error saying why it could not be
+// read: ErrNotFound, ErrExpired, ErrCodecMismatch, ErrSchemaMismatch or
+// ErrCorrupt. Expired entries are removed and corrupt ones quarantined.
+func (s *Store[K, V]) Load(ctx context.Context, key K) (V, error) {
+	var zero V
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	if err := s.rlock(); err != nil {
+		return zero, err
+	}
+	value, err := s.read(key)
+	s.runlock()
+
+	switch {
+	case err == nil:
+		s.stats.hits.Add(1)
+	case errors.Is(err, ErrNotFound):
+		s.stats.misses.Add(1)
+	case errors.Is(err, ErrExpired):
+		s.stats.expired.Add(1)
+		s.discard(key, ErrExpired)
+	case errors.Is(err, ErrCodecMismatch), errors.Is(err, ErrSchemaMismatch):
+		s.stats.mismatches.Add(1)
+	case errors.Is(err, ErrCorrupt):
+		s.stats.corrupt.Add(1)
+		s.discard(key, ErrCorrupt)
+	}
+	return value, err
+}
+
+// Scrub checks every entry and quarantines the corrupt ones, returning how
+// many were quarantined. Expired entries are removed.
+func (s *Store[K, V]) Scrub(ctx context.Context) (int, error) {
+	if err := s.lock(); err != nil {
+		return 0, err
+	}
+	defer s.unlock()
+
+	names, err := s.entryNames()
+	if err != nil {
+		return 0, err
+	}
+	quarantined := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return quarantined, err
+		}
+		path := filepath.Join(s.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		h, _, err := parseEntry(data)
+		switch {
+		case err != nil:
+			s.stats.corrupt.Add(1)
+			if s.quarantineFile(name) == nil {
+				quarantined++
+			}
+		case !h.Expires.IsZero() && !s.clock.Now().Before(h.Expires):
+			s.stats.expired.Add(1)
+			if os.Remove(path) == nil {
+				s.changed = true
+			}
+		}
+	}
+	return quarantined, nil
+}
+
+// Inspect returns the header of the entry stored for key
+func (s *Store[K, V]) Inspect(key K) (Header, error) {
+	if err := s.rlock(); err != nil {
+		return Header{}, err
+	}
+	defer s.runlock()
+
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return Header{}, ErrNotFound
+	} else if err != nil {
+		return Header{}, err
+	}
+	h, _, err := parseEntry(data)
+	return h, err
+}
+
+// Get returns the value of key. Entries that cannot be read for any reason
+// are reported as missing; use Load to find out why.
+func (s *Store[K, V]) Get(ctx context.Context, key K) (V, bool) {
+	value, err := s.Load(ctx, key)
+	return value, err == nil
+}
+
+// Set stores value under key. A ttl of zero or less never expires.
+func (s *Store[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+	return s.write(key, value, ttl)
+}
+
+// Update replaces the value of key with the result of fn, atomically with
+// respect to every other operation on the store, including those of other
+// processes in shared mode. fn receives the current value and whether there
+// is one; expired, mismatched and corrupt entries count as absent. If fn
+// returns an error, nothing is written.
+func (s *Store[K, V]) Update(ctx context.Context, key K, ttl time.Duration, fn func(value V, found bool) (V, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	current, err := s.read(key)
+	if errors.Is(err, ErrCorrupt) {
+		s.stats.corrupt.Add(1)
+		_ = s.quarantineFile(filepath.Base(s.path(key)))
+	}
+	value, err := fn(current, err == nil)
+	if err != nil {
+		return err
+	}
+	return s.write(key, value, ttl)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store[K, V]) Delete(ctx context.Context, key K) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+	return s.remove(key)
+}
+
+// Clear removes every entry
+func (s *Store[K, V]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+
+	names, err := s.entryNames()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	s.changed = true
+	return errors.Join(errs...)
+}
+
+// Size returns the number of entries, including expired ones not yet removed
+func (s *Store[K, V]) Size(ctx context.Context) int {
+	if s.rlock() != nil {
+		return 0
+	}
+	defer s.runlock()
+	names, _ := s.entryNames()
+	return len(names)
+}
+
+// Capacity returns the maximum number of entries, or zero if unlimited
+func (s *Store[K, V]) Capacity(ctx context.Context) int {
+	return s.maxSize
+}
+
+// Keys returns the keys of all entries. Keys that are not strings are parsed
+// back with fmt.Sscan, so they must format and scan symmetrically.
+func (s *Store[K, V]) Keys(ctx context.Context) []K {
+	if s.rlock() != nil {
+		return nil
+	}
+	defer s.runlock()
+
+	names, _ := s.entryNames()
+	keys := make([]K, 0, len(names))
+	for _, name := range names {
+		if key, ok := s.keyOf(name); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// MemoryUsage returns the total size of the entry files in bytes
+func (s *Store[K, V]) MemoryUsage(ctx context.Context) int64 {
+	if s.rlock() != nil {
+		return 0
+	}
+	defer s.runlock()
+
+	names, _ := s.entryNames()
+	var total int64
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(s.dir, name)); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// MaxMemory returns zero: the store does not limit its disk usage
+func (s *Store[K, V]) MaxMemory(ctx context.Context) int64 {
+	return 0
+}
+
+// GetMany returns the values of the keys that could be read
+func (s *Store[K, V]) GetMany(ctx context.Context, keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := s.Get(ctx, key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// SetMany stores every entry, stopping at the first error
+func (s *Store[K, V]) SetMany(ctx context.Context, entries map[K]V, ttl time.Duration) error {
+	for key, value := range entries {
+		if err := s.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMany removes every key, stopping at the first error
+func (s *Store[K, V]) DeleteMany(ctx context.Context, keys []K) error {
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close marks the store closed and releases the lock file of a shared
+// store. Entries stay on disk for the next New.
+func (s *Store[K, V]) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.shared != nil {
+		return s.shared.close()
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the counters
+func (s *Store[K, V]) Stats() Stats {
+	return Stats{
+		Hits:       s.stats.hits.Load(),
+		Misses:     s.stats.misses.Load(),
+		Expired:    s.stats.expired.Load(),
+		Mismatches: s.stats.mismatches.Load(),
+		Corrupt:    s.stats.corrupt.Load(),
+		Quarantine: s.stats.quarantine.Load(),
+	}
+}
+
+// read decodes the entry of key. It must be called with the store locked.
+func (s *Store[K, V]) read(key K) (V, error) {
+	var value V
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return value, ErrNotFound
+	} else if err != nil {
+		return value, err
+	}
+
+	h, payload, err := parseEntry(data)
+	if err != nil {
+		return value, err
+	}
+	if h.Codec != s.codec.ID() {
+		return value, fmt.Errorf("%w: codec %d, want %d (%s)", ErrCodecMismatch, h.Codec, s.codec.ID(), s.codec.Name())
+	}
+	if h.Schema != s.schema {
+		return value, fmt.Errorf("%w: version %d, want %d", ErrSchemaMismatch, h.Schema, s.schema)
+	}
+	if !h.Expires.IsZero() && !s.clock.Now().Before(h.Expires) {
+		return value, ErrExpired
+	}
+	if err := s.codec.Unmarshal(payload, &value); err != nil {
+		return value, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return value, nil
+}
+
+// write encodes and stores an entry. It must be called with the store locked.
+func (s *Store[K, V]) write(key K, value V, ttl time.Duration) error {
+	path := s.path(key)
+	if s.maxSize > 0 {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if names, _ := s.entryNames(); len(names) >= s.maxSize {
+				return ErrFull
+			}
+		}
+	}
+
+	payload, err := s.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("filestore: encoding %v: %w", key, err)
+	}
+	h := Header{Format: formatVersion, Codec: s.codec.ID(), Schema: s.schema}
+	if ttl > 0 {
+		h.Expires = s.clock.Now().Add(ttl)
+	}
+	if err := s.writeFile(path, encodeEntry(h, payload)); err != nil {
+		return err
+	}
+	s.changed = true
+	return nil
+}
+
+// writeFile replaces path with data atomically: data is written and synced
+// to a temp file in the same directory, which is then renamed over path.
+// Readers see either the old entry or the new one, never a partial write.
+func (s *Store[K, V]) writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// discard removes an expired entry or quarantines a corrupt one, after
+// checking under the write lock that it was not replaced since it was read
+func (s *Store[K, V]) discard(key K, reason error) {
+	if s.lock() != nil {
+		return
+	}
+	defer s.unlock()
+	if _, err := s.read(key); !errors.Is(err, reason) {
+		return
+	}
+	if reason == ErrCorrupt {
+		_ = s.quarantineFile(filepath.Base(s.path(key)))
+		return
+	}
+	_ = s.remove(key)
+}
+
+// quarantineFile moves the entry file name out of the store, or deletes it
+// if quarantine is disabled. It must be called with the store locked.
+func (s *Store[K, V]) quarantineFile(name string) error {
+	path := filepath.Join(s.dir, name)
+	if s.quarantine == "" {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		s.changed = true
+		return nil
+	}
+	if err := os.MkdirAll(s.quarantine, 0o755); err != nil {
+		return err
+	}
+	// Keep every quarantined copy of a key apart
+	target := filepath.Join(s.quarantine, fmt.Sprintf("%s.%d", name, s.clock.Now().UnixNano()))
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	s.changed = true
+	s.stats.quarantine.Add(1)
+	return nil
+}
+
+// removeStaleTemps deletes temp files old enough that their writer must have
+// died before renaming them
+func (s *Store[K, V]) removeStaleTemps() {
+	paths, _ := filepath.Glob(filepath.Join(s.dir, tempPrefix+"*"))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleTempAge {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// syncDir flushes a directory so a rename within it survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// remove deletes the entry of key. It must be called with the store locked.
+func (s *Store[K, V]) remove(key K) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	s.changed = true
+	return nil
+}
+
+// Path returns the file holding the entry of key
+func (s *Store[K, V]) Path(key K) string {
+	return s.path(key)
+}
+
+// path returns the file holding the entry of key
+func (s *Store[K, V]) path(key K) string {
+	return filepath.Join(s.dir, url.PathEscape(fmt.Sprint(key))+s.ext)
+}
+
+// keyOf parses the key back out of an entry file name
+func (s *Store[K, V]) keyOf(name string) (K, bool) {
+	var key K
+	raw, err := url.PathUnescape(strings.TrimSuffix(name, s.ext))
+	if err != nil {
+		return key, false
+	}
+	if k, ok := any(&key).(*string); ok {
+		*k = raw
+		return key, true
+	}
+	_, err = fmt.Sscan(raw, &key)
+	return key, err == nil
+}
+
+// entryNames lists the entry files in the directory
+func (s *Store[K, V]) entryNames() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		name := file.Name()
+		if !file.IsDir() && strings.HasSuffix(name, s.ext) && !strings.HasPrefix(name, tempPrefix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
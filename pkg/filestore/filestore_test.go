@@ -0,0 +1,270 @@
+package filestore
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+)
+
+// newStore opens a string store in a fresh directory
+func newStore(t *testing.T, opts ...Option) *Store[string, string] {
+	t.Helper()
+	s, err := New[string, string](t.TempDir(), codec.JSON[string](), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close(context.Background()) })
+	return s
+}
+
+// entryV1 returns a version 1 entry file: the header without payload length
+// or checksum, then the payload
+func entryV1(codecID byte, schema uint32, expires time.Time, payload []byte) []byte {
+	buf := append([]byte(magic), formatV1, codecID)
+	buf = binary.BigEndian.AppendUint32(buf, schema)
+	var nanos int64
+	if !expires.IsZero() {
+		nanos = expires.UnixNano()
+	}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(nanos))
+	return append(buf, payload...)
+}
+
+// quarantined lists the files in dir, which may not exist
+func quarantined(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names
+}
+
+func TestTruncatedEntry(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	if err := s.Set(ctx, "k", "a value long enough to cut", 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(s.Path("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut inside the magic, the v1 header, the v2 fields and the payload
+	for _, size := range []int{0, 3, headerSizeV1 - 1, headerSizeV1 + 2, headerSize, len(data) - 1} {
+		if err := os.WriteFile(s.Path("k"), data[:size], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Load(ctx, "k"); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%d of %d bytes: err = %v, want ErrCorrupt", size, len(data), err)
+		}
+		if _, err := os.Stat(s.Path("k")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%d of %d bytes: entry left in the store", size, len(data))
+		}
+	}
+}
+
+func TestBitFlips(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, WithQuarantine(""))
+	if err := s.Set(ctx, "k", "value", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(s.Path("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every single-bit error anywhere in the file is detected
+	flips := 0
+	for i := range data {
+		for bit := 0; bit < 8; bit++ {
+			damaged := append([]byte(nil), data...)
+			damaged[i] ^= 1 << bit
+			if err := os.WriteFile(s.Path("k"), damaged, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := s.Load(ctx, "k"); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("bit %d of byte %d: got %q, %v, want ErrCorrupt", bit, i, value, err)
+			}
+			flips++
+		}
+	}
+	if st := s.Stats(); st.Corrupt != int64(flips) || st.Hits != 0 {
+		t.Errorf("stats = %+v, want %d corrupt reads and no hits", st, flips)
+	}
+}
+
+func TestV1Entry(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	s := newStore(t, WithClock(clk))
+	id := codec.JSON[string]().ID()
+
+	if err := os.WriteFile(s.Path("old"), entryV1(id, 1, time.Time{}, []byte(`"from v1"`)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := s.Load(ctx, "old"); err != nil || value != "from v1" {
+		t.Fatalf("Load = %q, %v, want the v1 value", value, err)
+	}
+	if h, err := s.Inspect("old"); err != nil || h.Format != formatV1 {
+		t.Errorf("Inspect = %+v, %v, want format %d", h, err, formatV1)
+	}
+
+	// v1 headers still carry the expiry and the schema version
+	expired := entryV1(id, 1, clk.Now().Add(-time.Second), []byte(`"stale"`))
+	if err := os.WriteFile(s.Path("expired"), expired, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(ctx, "expired"); !errors.Is(err, ErrExpired) {
+		t.Errorf("expired v1 entry: err = %v, want ErrExpired", err)
+	}
+	if err := os.WriteFile(s.Path("schema"), entryV1(id, 2, time.Time{}, []byte(`"v"`)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(ctx, "schema"); !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("v1 entry of schema 2: err = %v, want ErrSchemaMismatch", err)
+	}
+
+	// Rewriting a v1 entry upgrades it
+	if err := s.Update(ctx, "old", 0, func(value string, found bool) (string, error) {
+		return value + ", rewritten", nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := s.Inspect("old"); err != nil || h.Format != formatVersion {
+		t.Errorf("after Update: Inspect = %+v, %v, want format %d", h, err, formatVersion)
+	}
+	if value, err := s.Load(ctx, "old"); err != nil || value != "from v1, rewritten" {
+		t.Errorf("Load after Update = %q, %v", value, err)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t)
+	if err := s.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.Path("k"), []byte("not an entry"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Load(ctx, "k"); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("err = %v, want ErrCorrupt", err)
+	}
+	names := quarantined(t, filepath.Join(s.dir, QuarantineDir))
+	if len(names) != 1 {
+		t.Fatalf("quarantine holds %v, want one file", names)
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, QuarantineDir, names[0]))
+	if err != nil || string(data) != "not an entry" {
+		t.Errorf("quarantined copy = %q, %v, want the damaged file", data, err)
+	}
+	if _, err := s.Load(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("after quarantine: err = %v, want ErrNotFound", err)
+	}
+	if keys := s.Keys(ctx); len(keys) != 0 {
+		t.Errorf("Keys() = %v, the quarantine directory is not an entry", keys)
+	}
+	if st := s.Stats(); st.Corrupt != 1 || st.Quarantine != 1 || st.Misses != 1 {
+		t.Errorf("stats = %+v, want one corrupt, one quarantined and one miss", st)
+	}
+}
+
+func TestScrubQuarantinesElsewhere(t *testing.T) {
+	ctx := context.Background()
+	elsewhere := filepath.Join(t.TempDir(), "bad")
+	s := newStore(t, WithQuarantine(elsewhere))
+	for _, key := range []string{"a", "b", "c"} {
+		if err := s.Set(ctx, key, key, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Truncate(s.Path("b"), 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.Scrub(ctx); err != nil || n != 1 {
+		t.Fatalf("Scrub = %d, %v, want one entry quarantined", n, err)
+	}
+	if names := quarantined(t, elsewhere); len(names) != 1 {
+		t.Errorf("%s holds %v, want one file", elsewhere, names)
+	}
+	if names := quarantined(t, filepath.Join(s.dir, QuarantineDir)); len(names) != 0 {
+		t.Errorf("default quarantine used: %v", names)
+	}
+	if n := s.Size(ctx); n != 2 {
+		t.Errorf("Size() = %d after Scrub, want 2", n)
+	}
+}
+
+func TestQuarantineDisabled(t *testing.T) {
+	ctx := context.Background()
+	s := newStore(t, WithQuarantine(""))
+	if err := s.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.Path("k"), []byte("GZFS garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Load(ctx, "k"); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("err = %v, want ErrCorrupt", err)
+	}
+	if _, err := os.Stat(s.Path("k")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("corrupt entry was not deleted")
+	}
+	if names := quarantined(t, filepath.Join(s.dir, QuarantineDir)); len(names) != 0 {
+		t.Errorf("quarantine holds %v with quarantine disabled", names)
+	}
+	if st := s.Stats(); st.Corrupt != 1 || st.Quarantine != 0 {
+		t.Errorf("stats = %+v, want one corrupt and none quarantined", st)
+	}
+}
+
+func TestStaleTempsRemoved(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stale := filepath.Join(dir, tempPrefix+"stale")
+	fresh := filepath.Join(dir, tempPrefix+"fresh")
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTempAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New[string, string](dir, codec.JSON[string]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(ctx)
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Error("stale temp file was not removed")
+	}
+	// A fresh temp file may belong to a writer that is still running
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("fresh temp file was removed: %v", err)
+	}
+	if n := s.Size(ctx); n != 0 {
+		t.Errorf("Size() = %d, temp files are not entries", n)
+	}
+}
@@ -0,0 +1,95 @@
+package filestore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// magic starts every entry file
+const magic = "GZFS"
+
+// Layout versions of entry files. Version 1 entries carry no checksum and
+// are still read; new entries are always written as version 2.
+const (
+	formatV1      = 1
+	formatV2      = 2
+	formatVersion = formatV2
+)
+
+// Header sizes: magic, format version, codec ID, schema version, expiry;
+// version 2 adds the payload length and a checksum
+const (
+	headerSizeV1 = len(magic) + 1 + 1 + 4 + 8
+	headerSize   = headerSizeV1 + 4 + 4
+)
+
+// castagnoli is the CRC-32C table used for entry checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Header describes how an entry was written
+type Header struct {
+	Format  byte      // layout version of the entry file
+	Codec   byte      // ID of the codec that encoded the value
+	Schema  uint32    // schema version of the store that wrote the entry
+	Expires time.Time // zero if the entry never expires
+}
+
+// encodeEntry returns the file contents for payload: the header, then the
+// payload, with a checksum covering both
+func encodeEntry(h Header, payload []byte) []byte {
+	buf := make([]byte, 0, headerSize+len(payload))
+	buf = append(buf, magic...)
+	buf = append(buf, formatVersion, h.Codec)
+	buf = binary.BigEndian.AppendUint32(buf, h.Schema)
+	var expires int64
+	if !h.Expires.IsZero() {
+		expires = h.Expires.UnixNano()
+	}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(expires))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+
+	sum := crc32.Update(crc32.Checksum(buf, castagnoli), castagnoli, payload)
+	buf = binary.BigEndian.AppendUint32(buf, sum)
+	return append(buf, payload...)
+}
+
+// parseEntry decodes the header at the start of data and returns the
+// payload following it. Version 2 entries whose length or checksum do not
+// match are reported as ErrCorrupt before any header field is trusted.
+func parseEntry(data []byte) (Header, []byte, error) {
+	if len(data) < headerSizeV1 || string(data[:len(magic)]) != magic {
+		return Header{}, nil, fmt.Errorf("%w: missing header", ErrCorrupt)
+	}
+
+	format := data[len(magic)]
+	var payload []byte
+	switch format {
+	case formatV1:
+		payload = data[headerSizeV1:]
+	case formatV2:
+		if len(data) < headerSize {
+			return Header{}, nil, fmt.Errorf("%w: truncated header", ErrCorrupt)
+		}
+		size := binary.BigEndian.Uint32(data[headerSizeV1:])
+		if uint64(len(data)-headerSize) != uint64(size) {
+			return Header{}, nil, fmt.Errorf("%w: payload is %d bytes, header says %d", ErrCorrupt, len(data)-headerSize, size)
+		}
+		want := binary.BigEndian.Uint32(data[headerSizeV1+4:])
+		payload = data[headerSize:]
+		sum := crc32.Update(crc32.Checksum(data[:headerSizeV1+4], castagnoli), castagnoli, payload)
+		if sum != want {
+			return Header{}, nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+		}
+	default:
+		return Header{}, nil, fmt.Errorf("%w: unsupported format version %d", ErrCorrupt, format)
+	}
+
+	fields := data[len(magic):]
+	h := Header{Format: format, Codec: fields[1], Schema: binary.BigEndian.Uint32(fields[2:6])}
+	if expires := int64(binary.BigEndian.Uint64(fields[6:14])); expires != 0 {
+		h.Expires = time.Unix(0, expires)
+	}
+	return h, payload, nil
+}
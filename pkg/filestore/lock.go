@@ -0,0 +1,142 @@
+package filestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Names of the files a shared store keeps next to its entries
+const (
+	lockFile       = ".lock"
+	generationFile = ".generation"
+)
+
+// sharedDir coordinates stores in several processes using one directory.
+// Readers hold a shared advisory lock on the lock file and writers an
+// exclusive one, and every change bumps the generation counter on disk.
+type sharedDir struct {
+	lock *os.File
+	dir  string
+}
+
+// openShared opens the lock file of dir, creating it if needed
+func openShared(dir string) (*sharedDir, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: opening lock file: %w", err)
+	}
+	return &sharedDir{lock: f, dir: dir}, nil
+}
+
+// generation reads the counter; a missing file is generation zero
+func (d *sharedDir) generation() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(d.dir, generationFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("%w: generation file is %d bytes", ErrCorrupt, len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (d *sharedDir) close() error {
+	return d.lock.Close()
+}
+
+// rlock locks the store for reading. In shared mode the first concurrent
+// reader in this process takes the shared file lock and the last one
+// releases it, since flock locks belong to the open file, not a goroutine.
+func (s *Store[K, V]) rlock() error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrClosed
+	}
+	if s.shared == nil {
+		return nil
+	}
+
+	s.readersMu.Lock()
+	defer s.readersMu.Unlock()
+	if s.readers == 0 {
+		if err := flock(s.shared.lock, false); err != nil {
+			s.mu.RUnlock()
+			return fmt.Errorf("filestore: locking for reading: %w", err)
+		}
+	}
+	s.readers++
+	return nil
+}
+
+// runlock releases a lock taken by rlock
+func (s *Store[K, V]) runlock() {
+	if s.shared != nil {
+		s.readersMu.Lock()
+		if s.readers--; s.readers == 0 {
+			_ = funlock(s.shared.lock)
+		}
+		s.readersMu.Unlock()
+	}
+	s.mu.RUnlock()
+}
+
+// lock locks the store for writing, taking the exclusive file lock in
+// shared mode
+func (s *Store[K, V]) lock() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	if s.shared != nil {
+		if err := flock(s.shared.lock, true); err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("filestore: locking for writing: %w", err)
+		}
+	}
+	return nil
+}
+
+// unlock releases a lock taken by lock, first bumping the generation if
+// anything in the directory changed
+func (s *Store[K, V]) unlock() {
+	if s.shared != nil {
+		if s.changed {
+			_ = s.bumpGeneration()
+		}
+		_ = funlock(s.shared.lock)
+	}
+	s.changed = false
+	s.mu.Unlock()
+}
+
+// bumpGeneration increments the counter on disk. It must be called with the
+// store locked for writing.
+func (s *Store[K, V]) bumpGeneration() error {
+	gen, err := s.shared.generation()
+	if err != nil {
+		return err
+	}
+	return s.writeFile(filepath.Join(s.dir, generationFile), binary.BigEndian.AppendUint64(nil, gen+1))
+}
+
+// Generation returns a counter bumped by every change made to a shared
+// store by any process, so a process can tell whether values it decoded
+// earlier may be out of date. It is always zero for a store not opened with
+// WithSharedDirectory.
+func (s *Store[K, V]) Generation() (uint64, error) {
+	if err := s.rlock(); err != nil {
+		return 0, err
+	}
+	defer s.runlock()
+	if s.shared == nil {
+		return 0, nil
+	}
+	return s.shared.generation()
+}
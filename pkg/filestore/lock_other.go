@@ -0,0 +1,17 @@
+//go:build !unix
+
+package filestore
+
+import (
+	"errors"
+	"os"
+)
+
+// flock is not available: shared stores are only supported on unix
+func flock(f *os.File, exclusive bool) error {
+	return errors.ErrUnsupported
+}
+
+func funlock(f *os.File) error {
+	return errors.ErrUnsupported
+}
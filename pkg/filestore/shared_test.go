@@ -0,0 +1,116 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/gozephyr/examples/pkg/codec"
+)
+
+// Shape of the multi-process test: each worker process runs goroutines
+// that increment the shared counter iterations times
+const (
+	processes  = 4
+	goroutines = 2
+	iterations = 50
+	counterKey = "counter"
+)
+
+// childEnv tells a re-executed test binary to act as a worker using the
+// store directory named by its value
+const childEnv = "FILESTORE_TEST_SHARED_DIR"
+
+// TestMain lets TestSharedUpdates re-execute the test binary as a worker
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(childEnv); dir != "" {
+		if err := worker(dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// openCounters opens the shared counter store in dir
+func openCounters(dir string) (*Store[string, int], error) {
+	return New[string, int](dir, codec.JSON[int](), WithSharedDirectory())
+}
+
+// worker increments the counter from several goroutines, reading it back
+// between updates, and fails on any read that is not a complete entry
+func worker(dir string) error {
+	ctx := context.Background()
+	counts, err := openCounters(dir)
+	if err != nil {
+		return err
+	}
+	defer counts.Close(ctx)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				err := counts.Update(ctx, counterKey, 0, func(n int, found bool) (int, error) {
+					return n + 1, nil
+				})
+				if err != nil {
+					errs <- fmt.Errorf("update %d: %w", i, err)
+					return
+				}
+				if _, err := counts.Load(ctx, counterKey); err != nil {
+					errs <- fmt.Errorf("read after update %d: %w", i, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func TestSharedUpdates(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	counts, err := openCounters(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer counts.Close(ctx)
+	if err := counts.Set(ctx, counterKey, 0, 0); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("shared stores are not supported on this platform")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for p := 0; p < processes; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^$")
+			cmd.Env = append(os.Environ(), childEnv+"="+dir)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("worker %d: %v\n%s", p, err, out)
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := processes * goroutines * iterations
+	if n, err := counts.Load(ctx, counterKey); err != nil || n != want {
+		t.Errorf("counter = %d, %v, want %d: updates were lost", n, err, want)
+	}
+	if st := counts.Stats(); st.Corrupt != 0 {
+		t.Errorf("stats = %+v, a worker left a corrupt entry", st)
+	}
+}
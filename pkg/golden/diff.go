@@ -0,0 +1,75 @@
+package golden
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around a change
+const contextLines = 2
+
+// diff returns a line diff between want and got, showing removed lines
+// with "-", added lines with "+" and a little surrounding context
+func diff(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table producing one edit per line
+	type edit struct {
+		op   byte
+		line string
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+
+	// Only print changes and the context around them
+	var sb strings.Builder
+	lastPrinted := -1
+	for k, e := range edits {
+		near := false
+		for n := max(0, k-contextLines); n <= min(len(edits)-1, k+contextLines); n++ {
+			if edits[n].op != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if lastPrinted >= 0 && k > lastPrinted+1 {
+			sb.WriteString("  ...\n")
+		}
+		fmt.Fprintf(&sb, "%c %s\n", e.op, e.line)
+		lastPrinted = k
+	}
+	return sb.String()
+}
@@ -0,0 +1,88 @@
+// Package golden compares the output of an example with the golden file
+// checked in next to it, so upstream API changes in gencache or cbreak that
+// break an example are caught by go test. Every example has the same
+// golden_test.go, a single call to Run with its entry function:
+//
+//	func TestGolden(t *testing.T) {
+//		golden.Run(t, run)
+//	}
+//
+// The fixture must not vary between examples; the package's own test fails
+// if one is missing or edited. Setup an example needs, such as dispatching
+// to a worker process, belongs in the example itself.
+//
+// Run executes the example in-process against the fake clock from
+// pkg/clock, in parallel with other tests. The example logs through a
+// logger of its own that writes to a buffer, prints without colors and
+// timestamps every message with a clock frozen at clock.Epoch, so the
+// output needs no rewriting before it is compared.
+//
+// Usage:
+//
+//	go test ./cbreak/... ./gencache/...                  # compare every example
+//	go test ./gencache/advanced/tiered                   # compare one example
+//	go test ./cbreak/... ./gencache/... -args -update    # regenerate golden files
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/logger"
+)
+
+// File is the path of the golden file relative to an example directory
+const File = "testdata/output.golden"
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// fakeClock selects the fake clock for clock.FromEnv, once per test binary
+var fakeClock sync.Once
+
+// Run runs entry with a logger writing to a buffer and compares what it
+// logged with File, or rewrites File when the test binary is run with
+// -update. The test fails if entry reports a failed check.
+func Run(t *testing.T, entry func(log *logger.Logger) bool) {
+	t.Helper()
+	// Examples pick their clock with clock.FromEnv. t.Setenv would rule out
+	// t.Parallel, and every example in the binary wants the fake clock anyway.
+	fakeClock.Do(func() { os.Setenv(clock.EnvVar, "fake") })
+	t.Parallel()
+
+	var out bytes.Buffer
+	log := logger.New(
+		logger.WithWriter(&out),
+		logger.WithColor(logger.ColorNever),
+		logger.WithClock(clock.NewFake(clock.Epoch)),
+	)
+
+	ok := entry(log)
+	got := out.Bytes()
+	if !ok {
+		t.Errorf("example reported a failed check; output:\n%s", got)
+	}
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(File), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(File, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated %s", File)
+		return
+	}
+
+	want, err := os.ReadFile(File)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("output differs from %s (run with -update to accept it):\n%s", File, diff(string(want), string(got)))
+	}
+}
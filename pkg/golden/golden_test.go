@@ -0,0 +1,53 @@
+package golden
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixture is the whole golden test of an example, identical in every
+// example directory
+const fixture = `package main
+
+import (
+	"testing"
+
+	"github.com/gozephyr/examples/pkg/golden"
+)
+
+func TestGolden(t *testing.T) {
+	golden.Run(t, run)
+}
+`
+
+func TestEveryExampleHasFixture(t *testing.T) {
+	examples := 0
+	for _, root := range []string{"../../cbreak", "../../gencache"} {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || d.Name() != "main.go" {
+				return err
+			}
+			examples++
+			dir := filepath.Dir(path)
+			data, err := os.ReadFile(filepath.Join(dir, "golden_test.go"))
+			switch {
+			case err != nil:
+				t.Errorf("%s: %v", dir, err)
+			case string(data) != fixture:
+				t.Errorf("%s: golden_test.go differs from the shared fixture", dir)
+			}
+			if _, err := os.Stat(filepath.Join(dir, File)); err != nil {
+				t.Errorf("%s: %v", dir, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if examples == 0 {
+		t.Fatal("no examples found")
+	}
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// Environment variables consulted by ColorAuto
+const (
+	// NoColorEnvVar disables colors when set to any non-empty value, see https://no-color.org
+	NoColorEnvVar = "NO_COLOR"
+
+	// ForceColorEnvVar enables colors even when the writer is not a terminal.
+	// A value of "0" or "false" disables them instead.
+	ForceColorEnvVar = "FORCE_COLOR"
+)
+
+// ColorMode controls whether a Handler prints terminal colors
+type ColorMode int
+
+const (
+	// ColorAuto prints colors when the writer is a terminal, unless
+	// NO_COLOR or FORCE_COLOR says otherwise
+	ColorAuto ColorMode = iota
+
+	// ColorAlways always prints colors
+	ColorAlways
+
+	// ColorNever never prints colors
+	ColorNever
+)
+
+// String returns the name of the mode
+func (m ColorMode) String() string {
+	switch m {
+	case ColorAuto:
+		return "auto"
+	case ColorAlways:
+		return "always"
+	case ColorNever:
+		return "never"
+	default:
+		return "ColorMode(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
+// useColor resolves mode for a handler writing to w
+func useColor(mode ColorMode, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv(NoColorEnvVar) != "" {
+		return false
+	}
+	if force, ok := os.LookupEnv(ForceColorEnvVar); ok {
+		if enabled, err := strconv.ParseBool(force); err == nil {
+			return enabled
+		}
+		return true
+	}
+	return isTerminal(w)
+}
+
+// isTerminal reports whether w is a terminal. Other character devices,
+// such as /dev/null, are not.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unset marks an environment variable that is not set at all
+const unset = "<unset>"
+
+// setEnv sets key for the duration of the test, or unsets it for value unset
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	// t.Setenv restores the original value when the test ends
+	t.Setenv(key, value)
+	if value == unset {
+		os.Unsetenv(key)
+	}
+}
+
+// writers returns one writer of each kind useColor sees that is not a
+// terminal, including a character device
+func writers(t *testing.T) map[string]io.Writer {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close(); w.Close() })
+
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { null.Close() })
+
+	return map[string]io.Writer{
+		"buffer":    &bytes.Buffer{},
+		"file":      file,
+		"pipe":      w,
+		"/dev/null": null,
+	}
+}
+
+func TestUseColorNonTerminal(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       ColorMode
+		noColor    string
+		forceColor string
+		want       bool
+	}{
+		{"auto", ColorAuto, unset, unset, false},
+		{"auto NO_COLOR", ColorAuto, "1", unset, false},
+		{"auto FORCE_COLOR", ColorAuto, unset, "1", true},
+		{"auto FORCE_COLOR empty", ColorAuto, unset, "", true},
+		{"auto FORCE_COLOR=0", ColorAuto, unset, "0", false},
+		{"auto FORCE_COLOR=false", ColorAuto, unset, "false", false},
+		{"auto NO_COLOR and FORCE_COLOR", ColorAuto, "1", "1", false},
+		{"auto NO_COLOR empty", ColorAuto, "", unset, false},
+		{"auto NO_COLOR empty and FORCE_COLOR", ColorAuto, "", "1", true},
+		{"always", ColorAlways, unset, unset, true},
+		{"always NO_COLOR", ColorAlways, "1", unset, true},
+		{"always FORCE_COLOR=0", ColorAlways, unset, "0", true},
+		{"never", ColorNever, unset, unset, false},
+		{"never FORCE_COLOR", ColorNever, unset, "1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, NoColorEnvVar, tt.noColor)
+			setEnv(t, ForceColorEnvVar, tt.forceColor)
+			for kind, w := range writers(t) {
+				if got := useColor(tt.mode, w); got != tt.want {
+					t.Errorf("useColor(%v, %s) = %t, want %t", tt.mode, kind, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerColorOutput(t *testing.T) {
+	setEnv(t, NoColorEnvVar, unset)
+
+	tests := []struct {
+		mode       ColorMode
+		forceColor string
+		want       bool
+	}{
+		{ColorAuto, unset, false},
+		{ColorAuto, "1", true},
+		{ColorAlways, unset, true},
+		{ColorNever, "1", false},
+	}
+	for _, tt := range tests {
+		setEnv(t, ForceColorEnvVar, tt.forceColor)
+		var buf bytes.Buffer
+		log := New(WithWriter(&buf), WithColor(tt.mode))
+		log.Info("hello")
+		log.Section("title")
+
+		colored := strings.Contains(buf.String(), "\033[")
+		if colored != tt.want {
+			t.Errorf("%v with FORCE_COLOR=%s: colored output = %t, want %t:\n%q",
+				tt.mode, tt.forceColor, colored, tt.want, buf.String())
+		}
+		if !strings.Contains(buf.String(), "hello") {
+			t.Errorf("%v: message missing from output:\n%q", tt.mode, buf.String())
+		}
+	}
+}
+
+func TestColorModeString(t *testing.T) {
+	for mode, want := range map[ColorMode]string{
+		ColorAuto:    "auto",
+		ColorAlways:  "always",
+		ColorNever:   "never",
+		ColorMode(7): "ColorMode(7)",
+	} {
+		if got := mode.String(); got != want {
+			t.Errorf("ColorMode(%d).String() = %q, want %q", int(mode), got, want)
+		}
+	}
+}
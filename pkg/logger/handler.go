@@ -0,0 +1,231 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PrefixKey is the attribute printed in the prefix position by Handler.
+// Logger attaches its prefix under this key, so other handlers such as
+// slog.JSONHandler receive it as an ordinary attribute.
+const PrefixKey = "prefix"
+
+// SlogLevelSuccess is the slog level used for Success messages. It sits
+// between slog.LevelInfo and slog.LevelWarn, so handlers from log/slog
+// print it as "INFO+2" unless configured with ReplaceLevel.
+const SlogLevelSuccess = slog.Level(2)
+
+// ReplaceLevel is a slog.HandlerOptions.ReplaceAttr function that prints
+// SlogLevelSuccess as "SUCCESS":
+//
+//	slog.NewJSONHandler(w, &slog.HandlerOptions{ReplaceAttr: logger.ReplaceLevel})
+func ReplaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 || a.Key != slog.LevelKey {
+		return a
+	}
+	if level, ok := a.Value.Any().(slog.Level); ok && level == SlogLevelSuccess {
+		return slog.String(slog.LevelKey, SUCCESS)
+	}
+	return a
+}
+
+// HandlerOptions configures a Handler
+type HandlerOptions struct {
+	// Level is the minimum level handled (defaults to slog.LevelDebug)
+	Level slog.Leveler
+
+	// Prefix is printed before every message that carries no PrefixKey attribute
+	Prefix string
+
+	// Color controls whether terminal colors are printed (defaults to ColorAuto)
+	Color ColorMode
+}
+
+// Handler is a slog.Handler producing the colored format of this package:
+//
+//	[15:04:05] INFO prefix message key=value
+type Handler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	opts   HandlerOptions
+	color  bool
+	attrs  []slog.Attr
+	groups []string
+}
+
+// NewHandler creates a Handler writing to w
+func NewHandler(w io.Writer, opts *HandlerOptions) *Handler {
+	h := &Handler{
+		mu: &sync.Mutex{},
+		w:  w,
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelDebug
+	}
+	h.color = useColor(h.opts.Color, w)
+	return h
+}
+
+// Enabled reports whether records at level are printed
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle prints a record
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	prefix := h.opts.Prefix
+	var fields strings.Builder
+
+	appendAttr := func(groups []string, a slog.Attr) {
+		if len(groups) == 0 && a.Key == PrefixKey {
+			prefix = a.Value.String()
+			return
+		}
+		writeAttr(&fields, groups, a)
+	}
+	for _, a := range h.attrs {
+		appendAttr(nil, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(h.groups, a)
+		return true
+	})
+
+	if prefix != "" {
+		prefix += " "
+	}
+	color := levelColor(r.Level)
+	line := fmt.Sprintf("%s %s %s%s%s\n",
+		h.paint(color, "["+r.Time.Format("15:04:05")+"]"),
+		h.paint(color, levelName(r.Level)),
+		prefix, r.Message, fields.String())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line)
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := h.clone()
+	for _, a := range attrs {
+		if len(h.groups) > 0 {
+			a = slog.Group(strings.Join(h.groups, "."), a)
+		}
+		h2.attrs = append(h2.attrs, a)
+	}
+	return h2
+}
+
+// WithGroup returns a handler that qualifies record attributes with name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.clone()
+	h2.groups = append(h2.groups, name)
+	return h2
+}
+
+// clone copies the handler, sharing its writer and lock
+func (h *Handler) clone() *Handler {
+	return &Handler{
+		mu:     h.mu,
+		w:      h.w,
+		opts:   h.opts,
+		color:  h.color,
+		attrs:  append([]slog.Attr(nil), h.attrs...),
+		groups: append([]string(nil), h.groups...),
+	}
+}
+
+// writeHeader prints a section header underlined with rule
+func (h *Handler) writeHeader(title, rule, color string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(h.w, "\n%s\n", h.paint(color, title))
+	fmt.Fprintf(h.w, "%s\n", h.paint(color, rule+repeat(rule, len(title))+rule))
+}
+
+// paint wraps s in color when the handler prints colors
+func (h *Handler) paint(color, s string) string {
+	if !h.color {
+		return s
+	}
+	return color + s + Reset
+}
+
+// writeAttr renders a as " key=value", flattening groups into dotted keys
+// and quoting values that contain spaces or are empty
+func writeAttr(sb *strings.Builder, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			writeAttr(sb, groups, ga)
+		}
+		return
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	sb.WriteString(" ")
+	sb.WriteString(key)
+	sb.WriteString("=")
+	sb.WriteString(value)
+}
+
+// levelName returns the name printed for a slog level
+func levelName(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < SlogLevelSuccess:
+		return INFO
+	case level < slog.LevelWarn:
+		return SUCCESS
+	case level < slog.LevelError:
+		return WARN
+	default:
+		return ERROR
+	}
+}
+
+// levelColor returns the terminal color used for a slog level
+func levelColor(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return Gray
+	case level < SlogLevelSuccess:
+		return Cyan
+	case level < slog.LevelWarn:
+		return Green
+	case level < slog.LevelError:
+		return Yellow
+	default:
+		return Red
+	}
+}
@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/gozephyr/examples/pkg/clock"
+)
+
+func TestHandlerAttrsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(NewHandler(&buf, &HandlerOptions{Prefix: "default", Color: ColorNever}))
+
+	log := base.With("service", "api").WithGroup("req").With("id", 7)
+	log.Info("handled", "status", 200, slog.Group("peer", "addr", "10.0.0.1:80"))
+	base.With("service", "api").Info("prefixed", PrefixKey, "custom")
+	base.With(PrefixKey, "from-attrs").WithGroup("g").Info("attrs prefix")
+	// Inside a group the key is an ordinary attribute
+	log.Info("grouped prefix", PrefixKey, "not-a-prefix")
+
+	want := []string{
+		"INFO default handled service=api req.id=7 req.status=200 req.peer.addr=10.0.0.1:80",
+		"INFO custom prefixed service=api",
+		"INFO from-attrs attrs prefix",
+		"INFO default grouped prefix service=api req.id=7 req.prefix=not-a-prefix",
+	}
+	got := lines(&buf)
+	for i := range got {
+		// Drop the timestamp, which comes from the real clock
+		got[i] = got[i][len("[15:04:05] "):]
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestHandlerLevelNames(t *testing.T) {
+	log, buf := newTestLogger(WithPrefix("p"))
+	log.Debug("d")
+	log.Info("i")
+	log.Success("s")
+	log.Warn("w")
+	log.Error("e")
+
+	want := []string{
+		"[00:00:00] DEBUG p d",
+		"[00:00:00] INFO p i",
+		"[00:00:00] SUCCESS p s",
+		"[00:00:00] WARN p w",
+		"[00:00:00] ERROR p e",
+	}
+	if got := lines(buf); !slices.Equal(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestFromHandlerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: ReplaceLevel})
+	log := FromHandler(handler)
+	log.SetClock(clock.NewFake(clock.Epoch))
+	log.SetPrefix("svc")
+
+	log.With("breaker", "api").Success("closed after %d probes", 3)
+	log.Warn("slow")
+	log.Section("Results")
+
+	var records []map[string]any
+	for _, line := range lines(&buf) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3:\n%s", len(records), buf.String())
+	}
+
+	checks := []map[string]any{
+		{"level": "SUCCESS", "msg": "closed after 3 probes", PrefixKey: "svc", "breaker": "api"},
+		{"level": "WARN", "msg": "slow", PrefixKey: "svc"},
+		{"level": "INFO", "msg": "Results", PrefixKey: "svc", "kind": "section"},
+	}
+	for i, want := range checks {
+		for key, value := range want {
+			if got := records[i][key]; got != value {
+				t.Errorf("record %d: %s = %v, want %v", i, key, got, value)
+			}
+		}
+	}
+	if ts := records[0][slog.TimeKey]; ts != clock.Epoch.Format("2006-01-02T15:04:05Z07:00") {
+		t.Errorf("time = %v, want the logger's clock", ts)
+	}
+}
+
+func TestReplaceLevelLeavesOtherAttrs(t *testing.T) {
+	for _, a := range []slog.Attr{
+		slog.Any(slog.LevelKey, slog.LevelInfo),
+		slog.Any(slog.LevelKey, slog.LevelWarn),
+		slog.String("other", "x"),
+	} {
+		if got := ReplaceLevel(nil, a); !got.Equal(a) {
+			t.Errorf("ReplaceLevel(%v) = %v, want it unchanged", a, got)
+		}
+	}
+	// A user attribute named "level" inside a group is not the record level
+	a := slog.Any(slog.LevelKey, SlogLevelSuccess)
+	if got := ReplaceLevel([]string{"g"}, a); !got.Equal(a) {
+		t.Errorf("ReplaceLevel in a group = %v, want it unchanged", got)
+	}
+}
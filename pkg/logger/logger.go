@@ -1,9 +1,16 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
-	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
 )
 
 // Colors for terminal output
@@ -28,9 +35,131 @@ const (
 	DEBUG   = "DEBUG"
 )
 
-// Logger provides attractive logging functionality
+// FormatEnvVar selects the handler used by Get. Set it to "json" to log
+// through slog.JSONHandler instead of the colored Handler.
+const FormatEnvVar = "ZEPHYR_LOG_FORMAT"
+
+// Level is the severity of a log message. Messages below the logger's
+// minimum level are discarded.
+type Level int
+
+// Levels in increasing order of severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelSuccess
+	LevelWarn
+	LevelError
+)
+
+// String returns the name printed for the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return DEBUG
+	case LevelInfo:
+		return INFO
+	case LevelSuccess:
+		return SUCCESS
+	case LevelWarn:
+		return WARN
+	case LevelError:
+		return ERROR
+	default:
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// SlogLevel returns the equivalent slog level
+func (l Level) SlogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelSuccess:
+		return SlogLevelSuccess
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// badKey is used for a field value that has no key
+const badKey = "!BADKEY"
+
+// Field is a key/value pair attached to every message of a logger
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Logger provides attractive logging functionality. A Logger is safe for
+// concurrent use, including changing its prefix or level while logging.
 type Logger struct {
-	prefix string
+	mu      sync.RWMutex
+	prefix  string
+	level   Level
+	fields  []Field
+	handler slog.Handler
+	clock   clock.Clock
+}
+
+// options holds the settings applied by New
+type options struct {
+	writer  io.Writer
+	handler slog.Handler
+	prefix  string
+	level   Level
+	color   ColorMode
+	clock   clock.Clock
+}
+
+// Option configures a Logger created by New
+type Option func(*options)
+
+// WithWriter makes the logger print the colored format to w
+func WithWriter(w io.Writer) Option {
+	return func(o *options) {
+		o.writer = w
+	}
+}
+
+// WithHandler makes the logger emit its messages as slog records through
+// handler. It takes precedence over WithWriter and WithColor.
+func WithHandler(handler slog.Handler) Option {
+	return func(o *options) {
+		o.handler = handler
+	}
+}
+
+// WithPrefix sets the prefix printed before every message
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithLevel sets the minimum level of messages that are printed
+func WithLevel(level Level) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithColor sets whether terminal colors are printed (defaults to ColorAuto)
+func WithColor(mode ColorMode) Option {
+	return func(o *options) {
+		o.color = mode
+	}
+}
+
+// WithClock sets the clock that timestamps messages (defaults to the real clock)
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
 }
 
 var (
@@ -38,70 +167,193 @@ var (
 	once     sync.Once
 )
 
-// Get returns the singleton logger instance
+// New creates an independent logger. Without options it prints the colored
+// format to os.Stdout at LevelDebug, with colors only when stdout is a terminal.
+func New(opts ...Option) *Logger {
+	o := options{writer: os.Stdout, clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.handler == nil {
+		o.handler = NewHandler(o.writer, &HandlerOptions{Color: o.color})
+	}
+	return &Logger{
+		prefix:  o.prefix,
+		level:   o.level,
+		handler: o.handler,
+		clock:   o.clock,
+	}
+}
+
+// Get returns the default logger shared by the whole process. Code that
+// may run alongside other users of the logger, such as examples driven
+// from tests, should create its own with New.
 func Get() *Logger {
 	once.Do(func() {
-		instance = &Logger{
-			prefix: "gozephyr",
+		opts := []Option{WithPrefix("gozephyr")}
+		if os.Getenv(FormatEnvVar) == "json" {
+			opts = append(opts, WithHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+				Level:       slog.LevelDebug,
+				ReplaceAttr: ReplaceLevel,
+			})))
 		}
+		instance = New(opts...)
 	})
 	return instance
 }
 
+// FromHandler creates a logger that emits its messages as slog records
+// through handler. Success messages have level SlogLevelSuccess; give
+// handlers from log/slog ReplaceLevel to print it by name.
+func FromHandler(handler slog.Handler) *Logger {
+	return New(WithHandler(handler))
+}
+
 // SetPrefix sets the prefix for the logger
 func (l *Logger) SetPrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.prefix = prefix
 }
 
-// formatMessage formats the log message with timestamp and color
-func (l *Logger) formatMessage(level, message string, color string) string {
-	timestamp := time.Now().Format("15:04:05")
-	return fmt.Sprintf("%s[%s]%s %s%s%s %s%s",
-		color, timestamp, Reset,
-		color, level, Reset,
-		l.prefix, message)
+// Prefix returns the prefix of the logger
+func (l *Logger) Prefix() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.prefix
+}
+
+// SetLevel sets the minimum level of messages that are printed
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetHandler replaces the slog handler the logger writes through
+func (l *Logger) SetHandler(handler slog.Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handler = handler
+}
+
+// SetClock replaces the clock that timestamps messages
+func (l *Logger) SetClock(clk clock.Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clk
+}
+
+// Handler returns the slog handler the logger writes through
+func (l *Logger) Handler() slog.Handler {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.handler
+}
+
+// Enabled reports whether messages at level are printed
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.RLock()
+	minLevel, handler := l.level, l.handler
+	l.mu.RUnlock()
+	return level >= minLevel && handler.Enabled(context.Background(), level.SlogLevel())
+}
+
+// With returns a child logger that adds the given key/value pairs to every
+// message. The child inherits the prefix, level, fields and handler of l.
+//
+//	log.With("breaker", name, "state", state).Warn("Circuit opened")
+func (l *Logger) With(args ...any) *Logger {
+	fields := make([]Field, len(l.fields), len(l.fields)+len(args)/2+1)
+	copy(fields, l.fields)
+	for len(args) > 0 {
+		if len(args) == 1 {
+			fields = append(fields, Field{Key: badKey, Value: args[0]})
+			break
+		}
+		key, ok := args[0].(string)
+		if !ok {
+			key = fmt.Sprint(args[0])
+		}
+		fields = append(fields, Field{Key: key, Value: args[1]})
+		args = args[2:]
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return &Logger{
+		prefix:  l.prefix,
+		level:   l.level,
+		fields:  fields,
+		handler: l.handler,
+		clock:   l.clock,
+	}
+}
+
+// log emits a message at level if it is enabled
+func (l *Logger) log(level Level, format string, args ...interface{}) {
+	l.mu.RLock()
+	prefix, minLevel, handler, clk := l.prefix, l.level, l.handler, l.clock
+	l.mu.RUnlock()
+
+	ctx := context.Background()
+	if level < minLevel || !handler.Enabled(ctx, level.SlogLevel()) {
+		return
+	}
+
+	record := slog.NewRecord(clk.Now(), level.SlogLevel(), fmt.Sprintf(format, args...), 0)
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		record.AddAttrs(slog.String(PrefixKey, prefix))
+	}
+	for _, f := range l.fields {
+		record.AddAttrs(slog.Any(f.Key, f.Value))
+	}
+	_ = handler.Handle(ctx, record)
 }
 
 // Info logs an informational message
 func (l *Logger) Info(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	fmt.Println(l.formatMessage(INFO, message, Cyan))
+	l.log(LevelInfo, format, args...)
 }
 
 // Success logs a success message
 func (l *Logger) Success(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	fmt.Println(l.formatMessage(SUCCESS, message, Green))
+	l.log(LevelSuccess, format, args...)
 }
 
 // Error logs an error message
 func (l *Logger) Error(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	fmt.Println(l.formatMessage(ERROR, message, Red))
+	l.log(LevelError, format, args...)
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	fmt.Println(l.formatMessage(WARN, message, Yellow))
+	l.log(LevelWarn, format, args...)
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	fmt.Println(l.formatMessage(DEBUG, message, Gray))
+	l.log(LevelDebug, format, args...)
 }
 
 // Section prints a section header
 func (l *Logger) Section(title string) {
-	fmt.Printf("\n%s%s%s\n", Purple, title, Reset)
-	fmt.Printf("%s%s%s\n", Purple, "="+repeat("=", len(title))+"=", Reset)
+	l.header(title, "=", Purple, "section")
 }
 
 // SubSection prints a subsection header
 func (l *Logger) SubSection(title string) {
-	fmt.Printf("\n%s%s%s\n", Blue, title, Reset)
-	fmt.Printf("%s%s%s\n", Blue, "-"+repeat("-", len(title))+"-", Reset)
+	l.header(title, "-", Blue, "subsection")
+}
+
+// header prints a colored, underlined title. Handlers other than Handler
+// receive the title as an Info record tagged with its kind.
+func (l *Logger) header(title, rule, color, kind string) {
+	if h, ok := l.Handler().(*Handler); ok {
+		h.writeHeader(title, rule, color)
+		return
+	}
+	l.With("kind", kind).Info("%s", title)
 }
 
 // repeat returns a string repeated n times
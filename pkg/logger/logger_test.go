@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gozephyr/examples/pkg/clock"
+)
+
+// newTestLogger returns a color-free logger writing to a buffer, with its
+// clock frozen at clock.Epoch
+func newTestLogger(opts ...Option) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	opts = append([]Option{
+		WithWriter(&buf),
+		WithColor(ColorNever),
+		WithClock(clock.NewFake(clock.Epoch)),
+	}, opts...)
+	return New(opts...), &buf
+}
+
+// lines splits logged output into lines
+func lines(buf *bytes.Buffer) []string {
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func TestLevelFiltering(t *testing.T) {
+	log, buf := newTestLogger(WithLevel(LevelSuccess))
+	log.Debug("debug")
+	log.Info("info")
+	log.Success("success")
+	log.Warn("warn")
+	log.Error("error")
+
+	want := []string{
+		"[00:00:00] SUCCESS success",
+		"[00:00:00] WARN warn",
+		"[00:00:00] ERROR error",
+	}
+	if got := lines(buf); !slices.Equal(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+	if log.Enabled(LevelInfo) || !log.Enabled(LevelWarn) {
+		t.Error("Enabled disagrees with the minimum level")
+	}
+
+	buf.Reset()
+	log.SetLevel(LevelError)
+	log.Warn("dropped")
+	log.Error("kept")
+	if got := buf.String(); got != "[00:00:00] ERROR kept\n" {
+		t.Errorf("after SetLevel(LevelError) logged %q", got)
+	}
+}
+
+func TestWithFieldsInOrder(t *testing.T) {
+	log, buf := newTestLogger(WithPrefix("app"))
+	child := log.With("breaker", "api", "state", "open")
+	child.With("failures", 3, "dangling").Warn("circuit %s", "opened")
+	log.Info("parent")
+
+	want := []string{
+		"[00:00:00] WARN app circuit opened breaker=api state=open failures=3 !BADKEY=dangling",
+		"[00:00:00] INFO app parent",
+	}
+	if got := lines(buf); !slices.Equal(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestConcurrentSetPrefix(t *testing.T) {
+	log, buf := newTestLogger()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				log.SetPrefix(fmt.Sprintf("worker-%d", i))
+				log.Info("message %d", j)
+				_ = log.Prefix()
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := lines(buf)
+	if len(got) != 800 {
+		t.Fatalf("logged %d lines, want 800", len(got))
+	}
+	for _, line := range got {
+		if !strings.HasPrefix(line, "[00:00:00] INFO worker-") {
+			t.Fatalf("interleaved or unprefixed line %q", line)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Package readthrough wraps a gencache cache so misses are filled by a
+// loader function.
+//
+// Concurrent misses for the same key share one call to the loader. Loader
+// errors are remembered for a short time, so a failing backend is not hit
+// on every request for a key it cannot serve. A loader that panics fails
+// the load with ErrLoaderPanic instead of crashing the program.
+//
+// A miss that races with the end of a load, looking the key up just before
+// the loaded value is stored, may load the key once more. Avoiding that
+// would take a second lookup under the lock on every miss.
+package readthrough
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+// ErrLoaderPanic is returned, wrapped with the panic value, to the callers
+// of a load whose loader panicked
+var ErrLoaderPanic = errors.New("readthrough: loader panicked")
+
+// Loader fetches the value of a key missing from the cache
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// call is a load in progress shared by every caller waiting for the key
+type call[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// minSweep is the smallest number of remembered errors worth sweeping
+const minSweep = 64
+
+// negativeEntry is a remembered loader error
+type negativeEntry struct {
+	err     error
+	expires time.Time
+}
+
+// Cache is a read-through cache. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	cache       gencache.Cache[K, V]
+	load        Loader[K, V]
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       clock.Clock
+
+	mu        sync.Mutex
+	calls     map[K]*call[V]
+	negative  map[K]negativeEntry
+	nextSweep int // sweep expired errors once negative grows this large
+	loads     int64
+}
+
+// Option configures a Cache
+type Option func(*options)
+
+type options struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       clock.Clock
+}
+
+// WithTTL sets how long loaded values stay in the cache (defaults to 1m)
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithNegativeTTL sets how long a loader error is returned without calling
+// the loader again (defaults to 5s). Zero disables negative caching.
+func WithNegativeTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = d
+	}
+}
+
+// WithClock sets the clock used to expire negative entries
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New wraps cache so that misses are filled by load
+func New[K comparable, V any](cache gencache.Cache[K, V], load Loader[K, V], opts ...Option) *Cache[K, V] {
+	o := options{
+		ttl:         time.Minute,
+		negativeTTL: 5 * time.Second,
+		clock:       clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Cache[K, V]{
+		cache:       cache,
+		load:        load,
+		ttl:         o.ttl,
+		negativeTTL: o.negativeTTL,
+		clock:       o.clock,
+		calls:       make(map[K]*call[V]),
+		negative:    make(map[K]negativeEntry),
+		nextSweep:   minSweep,
+	}
+}
+
+// Get returns the value of key, loading it on a miss. If a load of the key
+// is already running, Get waits for it instead of starting another. A
+// recently failed load returns the same error without calling the loader.
+//
+// Get returns ctx.Err() if ctx is done before the value is available. The
+// load itself is only cancelled once every caller waiting for it has gone.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	if value, err := c.cache.GetWithContext(ctx, key); err == nil {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	if entry, ok := c.negative[key]; ok {
+		if c.clock.Now().Before(entry.expires) {
+			c.mu.Unlock()
+			var zero V
+			return zero, entry.err
+		}
+		delete(c.negative, key)
+	}
+
+	cl, ok := c.calls[key]
+	if !ok {
+		cl = c.startLoad(ctx, key)
+	}
+	cl.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		cl.waiters--
+		if cl.waiters == 0 {
+			// Nobody wants the value any more; later callers start a new load
+			cl.cancel()
+			if c.calls[key] == cl {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// startLoad runs the loader for key in the background. It must be called
+// with c.mu held.
+func (c *Cache[K, V]) startLoad(ctx context.Context, key K) *call[V] {
+	// The load outlives the caller that started it if others are waiting
+	loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	cl := &call[V]{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	c.calls[key] = cl
+	c.loads++
+
+	go func() {
+		defer cancel()
+		value, err := c.callLoader(loadCtx, key)
+
+		c.mu.Lock()
+		if err == nil {
+			// A value the cache refuses is still returned to the waiting callers
+			_ = c.cache.Set(key, value, c.ttl)
+		} else if c.negativeTTL > 0 && loadCtx.Err() == nil {
+			// Remember the failure, unless the load was abandoned by its callers
+			c.remember(key, err)
+		}
+		cl.value, cl.err = value, err
+		if c.calls[key] == cl {
+			delete(c.calls, key)
+		}
+		c.mu.Unlock()
+
+		close(cl.done)
+	}()
+	return cl
+}
+
+// callLoader calls the loader, turning a panic into an error so the
+// waiting callers are released
+func (c *Cache[K, V]) callLoader(ctx context.Context, key K) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero V
+			value, err = zero, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+	}()
+	return c.load(ctx, key)
+}
+
+// remember records a loader error for key. Errors of keys that are never
+// requested again are swept out whenever the number remembered has doubled.
+// It must be called with c.mu held.
+func (c *Cache[K, V]) remember(key K, err error) {
+	now := c.clock.Now()
+	c.negative[key] = negativeEntry{err: err, expires: now.Add(c.negativeTTL)}
+	if len(c.negative) < c.nextSweep {
+		return
+	}
+	for k, entry := range c.negative {
+		if !now.Before(entry.expires) {
+			delete(c.negative, k)
+		}
+	}
+	c.nextSweep = max(2*len(c.negative), minSweep)
+}
+
+// Forget removes key from the cache and drops any remembered error, so the
+// next Get loads it again
+func (c *Cache[K, V]) Forget(key K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.negative, key)
+	return c.cache.Delete(key)
+}
+
+// Loads returns the number of times the loader has been called
+func (c *Cache[K, V]) Loads() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loads
+}
+
+// Waiters returns the number of callers waiting for a load of key
+func (c *Cache[K, V]) Waiters(key K) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cl, ok := c.calls[key]; ok {
+		return cl.waiters
+	}
+	return 0
+}
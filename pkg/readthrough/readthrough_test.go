@@ -0,0 +1,227 @@
+package readthrough
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrentMissesShareOneLoad(t *testing.T) {
+	const callers = 50
+	inner := gencache.New[string, string]()
+	defer inner.Close()
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	c := New(inner, func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value of " + key, nil
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.Get(context.Background(), "user:1")
+			if err == nil && value != "value of user:1" {
+				err = fmt.Errorf("got %q", value)
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	// Let the load finish only once every caller is waiting for it
+	waitFor(t, "every caller to wait", func() bool { return c.Waiters("user:1") == callers })
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+	if n := c.Loads(); n != 1 {
+		t.Errorf("Loads() = %d, want 1", n)
+	}
+	// Each caller looks the key up once before waiting
+	if misses := inner.Stats().Misses.Load(); misses != callers {
+		t.Errorf("cache counted %d misses, want %d", misses, callers)
+	}
+
+	// The loaded value is now served from the cache
+	if _, err := c.Get(context.Background(), "user:1"); err != nil || calls.Load() != 1 {
+		t.Errorf("Get after the load: %v, %d loads", err, calls.Load())
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	inner := gencache.New[string, string]()
+	defer inner.Close()
+
+	errDown := errors.New("backend down")
+	var calls atomic.Int64
+	c := New(inner, func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		return "", errDown
+	}, WithNegativeTTL(5*time.Second), WithClock(clk))
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(context.Background(), "k"); !errors.Is(err, errDown) {
+			t.Fatalf("Get %d: err = %v, want %v", i, err, errDown)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times within the negative TTL, want 1", n)
+	}
+
+	clk.Advance(5 * time.Second)
+	if _, err := c.Get(context.Background(), "k"); !errors.Is(err, errDown) {
+		t.Fatalf("err = %v, want %v", err, errDown)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times after the negative TTL, want 2", n)
+	}
+
+	// k was never cached, so only the remembered error is dropped
+	_ = c.Forget("k")
+	_, _ = c.Get(context.Background(), "k")
+	if n := calls.Load(); n != 3 {
+		t.Errorf("loader called %d times after Forget, want 3", n)
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	const callers = 10
+	inner := gencache.New[string, string]()
+	defer inner.Close()
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	c := New(inner, func(ctx context.Context, key string) (string, error) {
+		if calls.Add(1) == 1 {
+			<-release
+			panic("loader bug")
+		}
+		return "recovered", nil
+	}, WithNegativeTTL(0))
+
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := c.Get(context.Background(), "k")
+			errs <- err
+		}()
+	}
+	waitFor(t, "every caller to wait", func() bool { return c.Waiters("k") == callers })
+	close(release)
+	for i := 0; i < callers; i++ {
+		if err := <-errs; !errors.Is(err, ErrLoaderPanic) {
+			t.Errorf("caller %d: err = %v, want ErrLoaderPanic", i, err)
+		}
+	}
+
+	// The failed load is gone, so the next Get loads the key again
+	if n := c.Waiters("k"); n != 0 {
+		t.Errorf("%d waiters left on the panicked load", n)
+	}
+	if value, err := c.Get(context.Background(), "k"); err != nil || value != "recovered" {
+		t.Errorf("Get after the panic = %q, %v", value, err)
+	}
+}
+
+func TestNegativeEntriesAreSwept(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	inner := gencache.New[string, string]()
+	defer inner.Close()
+
+	c := New(inner, func(ctx context.Context, key string) (string, error) {
+		return "", errors.New("not found")
+	}, WithNegativeTTL(time.Second), WithClock(clk))
+
+	// Every key fails once and is never requested again
+	for i := 0; i < 10_000; i++ {
+		_, _ = c.Get(context.Background(), fmt.Sprintf("missing%d", i))
+		if i%10 == 9 {
+			clk.Advance(time.Second)
+		}
+	}
+
+	c.mu.Lock()
+	remembered := len(c.negative)
+	c.mu.Unlock()
+	if remembered > 2*minSweep {
+		t.Errorf("%d errors remembered, want at most %d", remembered, 2*minSweep)
+	}
+}
+
+func TestAbandonedCallerLeavesLoadToOthers(t *testing.T) {
+	inner := gencache.New[string, string]()
+	defer inner.Close()
+
+	release := make(chan struct{})
+	var loadErr atomic.Value
+	c := New(inner, func(ctx context.Context, key string) (string, error) {
+		<-release
+		if err := ctx.Err(); err != nil {
+			loadErr.Store(err)
+		}
+		return "v", nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "k")
+		first <- err
+	}()
+	waitFor(t, "the first caller", func() bool { return c.Waiters("k") == 1 })
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.Background(), "k")
+		second <- err
+	}()
+	waitFor(t, "the second caller", func() bool { return c.Waiters("k") == 2 })
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller: err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("second caller: %v", err)
+	}
+	if err := loadErr.Load(); err != nil {
+		t.Errorf("load was cancelled while a caller still waited: %v", err)
+	}
+	if n := c.Loads(); n != 1 {
+		t.Errorf("Loads() = %d, want 1", n)
+	}
+}
@@ -0,0 +1,402 @@
+// Package refresh wraps a gencache cache so hot entries are refreshed
+// instead of vanishing at expiry.
+//
+// Every entry has a soft TTL and a hard TTL. Until the soft TTL passes the
+// entry is fresh. Between the soft and hard TTL it is stale: Get returns it
+// immediately and reloads it in the background (stale-while-revalidate).
+// After the hard TTL it is gone and Get loads it synchronously. With
+// WithRefreshAhead, frequently read entries are reloaded shortly before
+// they go stale, so callers never see them stale at all.
+//
+// A key whose refresh failed is not refreshed again until the retry
+// interval has passed, so a failing backend is not called on every stale
+// read. A loader that panics fails the load with ErrLoaderPanic.
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+// ErrLoaderPanic is returned, wrapped with the panic value, to the callers
+// of a load whose loader panicked
+var ErrLoaderPanic = errors.New("refresh: loader panicked")
+
+// minSweep is the smallest number of retry deadlines worth sweeping
+const minSweep = 64
+
+// Loader fetches the current value of a key
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Entry is a cached value with its freshness deadlines
+type Entry[V any] struct {
+	Value   V
+	Stale   time.Time // soft expiry: refresh after this
+	Expires time.Time // hard expiry: never serve after this
+}
+
+// Metrics counts what Get served and how refreshes went
+type Metrics struct {
+	FreshHits       int64 // entries served before their soft TTL
+	StaleHits       int64 // entries served stale while a refresh ran
+	Misses          int64 // absent or hard-expired entries loaded synchronously
+	Refreshes       int64 // background refreshes that stored a new value
+	RefreshFailures int64 // background refreshes whose loader failed
+	AheadRefreshes  int64 // refreshes started ahead of the soft TTL
+}
+
+// metrics holds the live counters behind Metrics
+type metrics struct {
+	freshHits, staleHits, misses               atomic.Int64
+	refreshes, refreshFailures, aheadRefreshes atomic.Int64
+}
+
+// flight is a load in progress shared by every caller of the key
+type flight[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Cache serves values with stale-while-revalidate semantics. It is safe
+// for concurrent use. Close stops refresh-ahead and waits for background
+// refreshes.
+type Cache[K comparable, V any] struct {
+	cache          gencache.Cache[K, Entry[V]]
+	load           Loader[K, V]
+	softTTL        time.Duration
+	hardTTL        time.Duration
+	refreshTimeout time.Duration
+	retryInterval  time.Duration
+	ahead          bool // refresh-ahead is on, so reads are counted
+	clock          clock.Clock
+
+	mu        sync.Mutex
+	flights   map[K]*flight[V]
+	reads     map[K]int       // reads per key since the last refresh-ahead scan
+	retryAt   map[K]time.Time // no refresh of the key before this, after a failure
+	nextSweep int             // sweep passed deadlines once retryAt grows this large
+	metrics   metrics
+
+	background sync.WaitGroup
+	stop       chan struct{}
+	closeOnce  sync.Once
+}
+
+// Option configures a Cache
+type Option func(*options)
+
+type options struct {
+	softTTL        time.Duration
+	hardTTL        time.Duration
+	refreshTimeout time.Duration
+	retryInterval  time.Duration
+	aheadInterval  time.Duration
+	aheadMinReads  int
+	clock          clock.Clock
+}
+
+// WithTTL sets the soft and hard TTL of entries (defaults to 1m and 5m).
+// The hard TTL must be at least the soft TTL.
+func WithTTL(soft, hard time.Duration) Option {
+	return func(o *options) {
+		o.softTTL, o.hardTTL = soft, max(hard, soft)
+	}
+}
+
+// WithRefreshTimeout bounds each background refresh (defaults to 10s)
+func WithRefreshTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.refreshTimeout = d
+	}
+}
+
+// WithRetryInterval sets how long a key whose refresh failed is served
+// stale before it is refreshed again (defaults to 5s)
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.retryInterval = d
+	}
+}
+
+// WithRefreshAhead checks every interval for keys read at least minReads
+// times since the previous check, and refreshes those that would go stale
+// before the next check
+func WithRefreshAhead(interval time.Duration, minReads int) Option {
+	return func(o *options) {
+		o.aheadInterval, o.aheadMinReads = interval, max(minReads, 1)
+	}
+}
+
+// WithClock sets the clock used for TTLs and refresh-ahead checks
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New wraps cache so that values come from load and are refreshed in the
+// background. Entries are stored in cache with the hard TTL.
+func New[K comparable, V any](cache gencache.Cache[K, Entry[V]], load Loader[K, V], opts ...Option) *Cache[K, V] {
+	o := options{
+		softTTL:        time.Minute,
+		hardTTL:        5 * time.Minute,
+		refreshTimeout: 10 * time.Second,
+		retryInterval:  5 * time.Second,
+		clock:          clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Cache[K, V]{
+		cache:          cache,
+		load:           load,
+		softTTL:        o.softTTL,
+		hardTTL:        o.hardTTL,
+		refreshTimeout: o.refreshTimeout,
+		retryInterval:  o.retryInterval,
+		ahead:          o.aheadInterval > 0,
+		clock:          o.clock,
+		flights:        make(map[K]*flight[V]),
+		retryAt:        make(map[K]time.Time),
+		nextSweep:      minSweep,
+		stop:           make(chan struct{}),
+	}
+	if c.ahead {
+		c.reads = make(map[K]int)
+		// Stop counting reads of keys the cache no longer holds. gencache
+		// emits events synchronously, and never while c.mu is held since c
+		// does not call into the cache under it.
+		cache.OnEvent(func(event gencache.CacheEvent[K, Entry[V]]) {
+			switch event.Type {
+			case gencache.EventTypeDelete, gencache.EventTypeEviction, gencache.EventTypeExpiration:
+				c.forget(event.Key)
+			}
+		})
+
+		// Start the ticker before returning so time advanced by the caller counts
+		ticker := c.clock.NewTicker(o.aheadInterval)
+		c.background.Add(1)
+		go c.refreshAhead(ticker, o.aheadInterval, o.aheadMinReads)
+	}
+	return c
+}
+
+// Get returns the value of key. A fresh entry is returned as is; a stale
+// one is returned and refreshed in the background; a missing or expired
+// one is loaded before Get returns.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	now := c.clock.Now()
+	if entry, err := c.cache.GetWithContext(ctx, key); err == nil && now.Before(entry.Expires) {
+		if c.ahead {
+			c.mu.Lock()
+			c.reads[key]++
+			c.mu.Unlock()
+		}
+
+		if now.Before(entry.Stale) {
+			c.metrics.freshHits.Add(1)
+		} else {
+			c.metrics.staleHits.Add(1)
+			c.refresh(key)
+		}
+		return entry.Value, nil
+	}
+
+	c.metrics.misses.Add(1)
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		f = c.startLoad(key, false)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Set stores value as a fresh entry
+func (c *Cache[K, V]) Set(key K, value V) error {
+	c.mu.Lock()
+	delete(c.retryAt, key)
+	c.mu.Unlock()
+
+	now := c.clock.Now()
+	return c.cache.Set(key, Entry[V]{
+		Value:   value,
+		Stale:   now.Add(c.softTTL),
+		Expires: now.Add(c.hardTTL),
+	}, c.hardTTL)
+}
+
+// Metrics returns a snapshot of the counters
+func (c *Cache[K, V]) Metrics() Metrics {
+	return Metrics{
+		FreshHits:       c.metrics.freshHits.Load(),
+		StaleHits:       c.metrics.staleHits.Load(),
+		Misses:          c.metrics.misses.Load(),
+		Refreshes:       c.metrics.refreshes.Load(),
+		RefreshFailures: c.metrics.refreshFailures.Load(),
+		AheadRefreshes:  c.metrics.aheadRefreshes.Load(),
+	}
+}
+
+// Wait blocks until no load or refresh is running
+func (c *Cache[K, V]) Wait() {
+	for {
+		c.mu.Lock()
+		var pending *flight[V]
+		for _, f := range c.flights {
+			pending = f
+			break
+		}
+		c.mu.Unlock()
+		if pending == nil {
+			return
+		}
+		<-pending.done
+	}
+}
+
+// Close stops refresh-ahead and waits for background refreshes to finish
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	c.background.Wait()
+	c.Wait()
+}
+
+// forget drops the read count of key
+func (c *Cache[K, V]) forget(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.reads, key)
+}
+
+// refresh reloads key in the background unless a load is already running
+// or a failed refresh of the key is waiting out the retry interval
+func (c *Cache[K, V]) refresh(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.flights[key]; ok {
+		return false
+	}
+	if retryAt, ok := c.retryAt[key]; ok {
+		if c.clock.Now().Before(retryAt) {
+			return false
+		}
+		delete(c.retryAt, key)
+	}
+	c.startLoad(key, true)
+	return true
+}
+
+// startLoad runs the loader for key in the background and stores the result.
+// Refreshes are counted in the metrics; loads for a miss are not. It must be
+// called with c.mu held.
+func (c *Cache[K, V]) startLoad(key K, refresh bool) *flight[V] {
+	f := &flight[V]{done: make(chan struct{})}
+	c.flights[key] = f
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.refreshTimeout)
+		defer cancel()
+		f.value, f.err = c.callLoader(ctx, key)
+
+		// A failed refresh leaves the old entry, served stale until its hard TTL
+		if f.err == nil {
+			_ = c.Set(key, f.value)
+		}
+		if refresh && f.err == nil {
+			c.metrics.refreshes.Add(1)
+		} else if refresh {
+			c.metrics.refreshFailures.Add(1)
+		}
+
+		c.mu.Lock()
+		if refresh && f.err != nil {
+			c.deferRetry(key)
+		}
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+// callLoader calls the loader, turning a panic into an error so the
+// waiting callers are released
+func (c *Cache[K, V]) callLoader(ctx context.Context, key K) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero V
+			value, err = zero, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+	}()
+	return c.load(ctx, key)
+}
+
+// deferRetry holds off refreshing key for the retry interval. Deadlines of
+// keys that are never read again are swept out whenever the number kept
+// has doubled. It must be called with c.mu held.
+func (c *Cache[K, V]) deferRetry(key K) {
+	now := c.clock.Now()
+	c.retryAt[key] = now.Add(c.retryInterval)
+	if len(c.retryAt) < c.nextSweep {
+		return
+	}
+	for k, retryAt := range c.retryAt {
+		if !now.Before(retryAt) {
+			delete(c.retryAt, k)
+		}
+	}
+	c.nextSweep = max(2*len(c.retryAt), minSweep)
+}
+
+// refreshAhead periodically refreshes frequently read keys that are about
+// to go stale
+func (c *Cache[K, V]) refreshAhead(ticker clock.Ticker, interval time.Duration, minReads int) {
+	defer c.background.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
+		}
+
+		c.mu.Lock()
+		reads := c.reads
+		c.reads = make(map[K]int, len(reads))
+		c.mu.Unlock()
+
+		deadline := c.clock.Now().Add(interval)
+		for key, n := range reads {
+			if n < minReads {
+				continue
+			}
+			entry, err := c.cache.Get(key)
+			if err != nil || entry.Stale.After(deadline) {
+				continue
+			}
+			if c.refresh(key) {
+				c.metrics.aheadRefreshes.Add(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+// load returns the key as its value
+func load(ctx context.Context, key string) (string, error) {
+	return "value of " + key, nil
+}
+
+// readCounts returns how many keys have their reads counted
+func readCounts(c *Cache[string, string]) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.reads)
+}
+
+// readAll reads every key once, loading it on first use
+func readAll(t *testing.T, c *Cache[string, string], keys []string) {
+	t.Helper()
+	for _, key := range keys {
+		if _, err := c.Get(context.Background(), key); err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+	}
+}
+
+func TestReadsNotCountedWithoutRefreshAhead(t *testing.T) {
+	inner := gencache.New[string, Entry[string]]()
+	defer inner.Close()
+	c := New(inner, load)
+	defer c.Close()
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	readAll(t, c, keys)
+	readAll(t, c, keys)
+
+	if n := readCounts(c); n != 0 {
+		t.Errorf("read counts kept for %d keys without refresh-ahead", n)
+	}
+	if m := c.Metrics(); m.FreshHits != int64(len(keys)) {
+		t.Errorf("FreshHits = %d, want %d", m.FreshHits, len(keys))
+	}
+}
+
+func TestReadCountsForgottenWithTheirKeys(t *testing.T) {
+	const capacity = 10
+	clk := clock.NewFake(clock.Epoch)
+	inner := gencache.New[string, Entry[string]](gencache.WithMaxSize[string, Entry[string]](capacity))
+	defer inner.Close()
+	c := New(inner, load, WithRefreshAhead(time.Hour, 1), WithClock(clk))
+	defer c.Close()
+
+	// Read each key twice so the second read is a hit that gets counted,
+	// while later keys evict the earlier ones
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		readAll(t, c, []string{key, key})
+	}
+	if n := readCounts(c); n > capacity {
+		t.Errorf("read counts kept for %d keys, cache holds at most %d", n, capacity)
+	}
+
+	if err := inner.Delete("key99"); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	_, counted := c.reads["key99"]
+	c.mu.Unlock()
+	if counted {
+		t.Error("read count of a deleted key was kept")
+	}
+}
+
+// scripted is a loader whose result the test sets, counting its calls
+type scripted struct {
+	calls   atomic.Int64
+	value   atomic.Value // string returned on success
+	fail    atomic.Bool
+	release chan struct{} // when not nil, each call waits for it
+}
+
+var errDown = errors.New("backend down")
+
+func (s *scripted) load(ctx context.Context, key string) (string, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if s.fail.Load() {
+		return "", errDown
+	}
+	return s.value.Load().(string), nil
+}
+
+// newScripted returns a cache with a 1m soft and 5m hard TTL on a fake clock
+// holding k = "v1", and the loader behind it
+func newScripted(t *testing.T, opts ...Option) (*Cache[string, string], *scripted, *clock.Fake) {
+	t.Helper()
+	clk := clock.NewFake(clock.Epoch)
+	loader := &scripted{}
+	loader.value.Store("v2")
+	inner := gencache.New[string, Entry[string]]()
+	t.Cleanup(func() { inner.Close() })
+
+	opts = append([]Option{WithTTL(time.Minute, 5*time.Minute), WithClock(clk)}, opts...)
+	c := New(inner, loader.load, opts...)
+	t.Cleanup(c.Close)
+	if err := c.Set("k", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	return c, loader, clk
+}
+
+// get reads k and fails the test on error
+func get(t *testing.T, c *Cache[string, string]) string {
+	t.Helper()
+	value, err := c.Get(context.Background(), "k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	return value
+}
+
+func TestStaleServedWhileRefreshing(t *testing.T) {
+	c, loader, clk := newScripted(t)
+	loader.release = make(chan struct{})
+
+	if v := get(t, c); v != "v1" {
+		t.Fatalf("fresh Get = %q", v)
+	}
+	clk.Advance(90 * time.Second)
+
+	// The refresh is blocked, yet Get returns at once with the stale value
+	if v := get(t, c); v != "v1" {
+		t.Errorf("stale Get = %q, want v1", v)
+	}
+	if v := get(t, c); v != "v1" {
+		t.Errorf("second stale Get = %q, want v1", v)
+	}
+	close(loader.release)
+	c.Wait()
+
+	if v := get(t, c); v != "v2" {
+		t.Errorf("Get after the refresh = %q, want v2", v)
+	}
+	if n := loader.calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want one refresh", n)
+	}
+	m := c.Metrics()
+	if m.FreshHits != 2 || m.StaleHits != 2 || m.Refreshes != 1 || m.Misses != 0 {
+		t.Errorf("metrics = %+v, want 2 fresh, 2 stale, 1 refresh", m)
+	}
+}
+
+func TestLoadAfterHardTTL(t *testing.T) {
+	c, loader, clk := newScripted(t)
+	clk.Advance(5 * time.Minute)
+
+	if v := get(t, c); v != "v2" {
+		t.Errorf("Get after the hard TTL = %q, want the loaded v2", v)
+	}
+	if m := c.Metrics(); m.Misses != 1 || m.StaleHits != 0 {
+		t.Errorf("metrics = %+v, want one miss and no stale hit", m)
+	}
+
+	loader.fail.Store(true)
+	clk.Advance(5 * time.Minute)
+	if _, err := c.Get(context.Background(), "k"); !errors.Is(err, errDown) {
+		t.Errorf("err = %v, want the loader error instead of the expired value", err)
+	}
+}
+
+func TestFailedRefreshKeepsValueAndBacksOff(t *testing.T) {
+	c, loader, clk := newScripted(t, WithRetryInterval(10*time.Second))
+	loader.fail.Store(true)
+	clk.Advance(90 * time.Second)
+
+	if v := get(t, c); v != "v1" {
+		t.Fatalf("stale Get = %q, want v1", v)
+	}
+	c.Wait()
+	// The old value is still served, and the loader is left alone
+	for i := 0; i < 5; i++ {
+		if v := get(t, c); v != "v1" {
+			t.Fatalf("Get after a failed refresh = %q, want v1", v)
+		}
+		c.Wait()
+	}
+	if n := loader.calls.Load(); n != 1 {
+		t.Errorf("loader called %d times within the retry interval, want 1", n)
+	}
+	if m := c.Metrics(); m.RefreshFailures != 1 || m.Refreshes != 0 {
+		t.Errorf("metrics = %+v, want one failed refresh", m)
+	}
+
+	loader.fail.Store(false)
+	clk.Advance(10 * time.Second)
+	if v := get(t, c); v != "v1" {
+		t.Errorf("Get that retries = %q, want the stale v1", v)
+	}
+	c.Wait()
+	if v := get(t, c); v != "v2" {
+		t.Errorf("Get after the retry = %q, want v2", v)
+	}
+	if m := c.Metrics(); m.RefreshFailures != 1 || m.Refreshes != 1 {
+		t.Errorf("metrics = %+v, want one failed and one successful refresh", m)
+	}
+}
+
+func TestRefreshAheadReloadsHotKey(t *testing.T) {
+	// One check at 1m, with k going stale at 1m30s, before the next check
+	c, loader, clk := newScripted(t, WithTTL(90*time.Second, 5*time.Minute), WithRefreshAhead(time.Minute, 2))
+	get(t, c)
+	get(t, c)
+
+	clk.Advance(time.Minute)
+	waitFor(t, "the refresh-ahead check", func() bool { return c.Metrics().AheadRefreshes == 1 })
+	c.Wait()
+
+	// k was reloaded at 1m, so it is still fresh past its original soft TTL
+	clk.Advance(40 * time.Second)
+	if v := get(t, c); v != "v2" {
+		t.Errorf("Get = %q, want the value reloaded ahead", v)
+	}
+	m := c.Metrics()
+	if m.StaleHits != 0 || m.Refreshes != 1 || m.FreshHits != 3 {
+		t.Errorf("metrics = %+v, want no stale hit and one refresh", m)
+	}
+	if n := loader.calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+}
+
+func TestLoaderPanic(t *testing.T) {
+	c, loader, clk := newScripted(t)
+	c.load = func(ctx context.Context, key string) (string, error) {
+		panic("loader bug")
+	}
+
+	if _, err := c.Get(context.Background(), "missing"); !errors.Is(err, ErrLoaderPanic) {
+		t.Errorf("err = %v, want ErrLoaderPanic", err)
+	}
+
+	clk.Advance(90 * time.Second)
+	if v := get(t, c); v != "v1" {
+		t.Errorf("stale Get = %q, want v1", v)
+	}
+	c.Wait()
+	if m := c.Metrics(); m.RefreshFailures != 1 {
+		t.Errorf("RefreshFailures = %d after a panicking refresh, want 1", m.RefreshFailures)
+	}
+
+	c.load = loader.load
+	if v, err := c.Get(context.Background(), "missing"); err != nil || v != "v2" {
+		t.Errorf("Get after the panic = %q, %v; want a new load", v, err)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
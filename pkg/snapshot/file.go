@@ -0,0 +1,304 @@
+package snapshot
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	cacheerrors "github.com/gozephyr/gencache/errors"
+)
+
+// magic starts every snapshot file
+const magic = "GZSN"
+
+// formatVersion is the layout version of snapshot files
+const formatVersion = 1
+
+// headerSize: magic, format version, key codec ID, value codec ID, time the
+// snapshot was taken
+const headerSize = len(magic) + 1 + 1 + 1 + 8
+
+// Record types. Each entry is written as recordEntry, the key length and
+// key, the value length and value, and the TTL left in nanoseconds (zero
+// for the cache's default TTL). The file ends with recordEnd, the number of
+// entries and a CRC-32C of everything before it.
+const (
+	recordEnd   = 0
+	recordEntry = 1
+)
+
+// castagnoli is the CRC-32C table used for snapshot checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Save writes every live entry to the snapshot file and returns how many
+// were written. Values are read back through Get, one at a time, and
+// gencache has no read without side effects: each snapshot adds a hit per
+// entry to the cache's Stats and an access to its eviction policy (see
+// WithInterval). The previous snapshot is replaced only once the new one is
+// complete.
+func (c *Cache[K, V]) Save(ctx context.Context) (int, error) {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	n, size, err := c.save(ctx)
+	if err != nil {
+		c.metrics.failures.Add(1)
+		return 0, err
+	}
+	c.metrics.snapshots.Add(1)
+	c.metrics.entries.Store(int64(n))
+	c.metrics.bytes.Store(size)
+	c.metrics.last.Store(c.clock.Now().UnixNano())
+	return n, nil
+}
+
+// save streams the snapshot to a temp file and renames it over the
+// previous one, returning the number of entries and the file size
+func (c *Cache[K, V]) save(ctx context.Context) (n int, size int64, err error) {
+	dir := filepath.Dir(c.path)
+	f, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(c.path)+"-")
+	if err != nil {
+		return 0, 0, fmt.Errorf("snapshot: creating temp file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	sum := crc32.New(castagnoli)
+	w := bufio.NewWriter(io.MultiWriter(f, sum))
+	now := c.clock.Now()
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, formatVersion, c.keys.ID(), c.values.ID())
+	header = binary.BigEndian.AppendUint64(header, uint64(now.UnixNano()))
+	if _, err := w.Write(header); err != nil {
+		return 0, 0, err
+	}
+
+	var record []byte
+	for _, e := range c.liveEntries(now) {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		value, err := c.Cache.GetWithContext(ctx, e.key)
+		if err != nil {
+			// Evicted by the cache since it was stored
+			c.forget(e)
+			continue
+		}
+		// Use the expiry recorded for the value just read, in case the
+		// entry was stored again since it was listed
+		expires, ok := c.expiry(e.key)
+		if !ok {
+			continue
+		}
+		ttl, live := entryTTL(expires, now)
+		if !live {
+			continue
+		}
+
+		key, err := c.keys.Marshal(e.key)
+		if err != nil {
+			return 0, 0, fmt.Errorf("snapshot: encoding key %v: %w", e.key, err)
+		}
+		data, err := c.values.Marshal(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("snapshot: encoding value of %v: %w", e.key, err)
+		}
+		record = append(record[:0], recordEntry)
+		record = binary.AppendUvarint(record, uint64(len(key)))
+		record = append(record, key...)
+		record = binary.AppendUvarint(record, uint64(len(data)))
+		record = append(record, data...)
+		record = binary.AppendUvarint(record, uint64(ttl))
+		if _, err := w.Write(record); err != nil {
+			return 0, 0, err
+		}
+		n++
+	}
+
+	record = append(record[:0], recordEnd)
+	record = binary.AppendUvarint(record, uint64(n))
+	if _, err := w.Write(record); err != nil {
+		return 0, 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return 0, 0, err
+	}
+	if _, err := f.Write(binary.BigEndian.AppendUint32(nil, sum.Sum32())); err != nil {
+		return 0, 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(f.Name(), c.path); err != nil {
+		return 0, 0, err
+	}
+	syncDir(dir)
+	return n, info.Size(), nil
+}
+
+// syncDir makes a rename in dir durable where the platform allows it
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
+
+// Restore loads the snapshot file into the cache. Each TTL is shortened by
+// the time since the snapshot was taken. Entries that expired in the
+// meantime are skipped, and so are those left with less than the cache's
+// minimum TTL, which it refuses to store; they are counted as Dropped. A
+// missing snapshot file is not an error. The whole
+// file is checked against its checksum before any entry is stored, so a
+// damaged snapshot restores nothing.
+func (c *Cache[K, V]) Restore(ctx context.Context) (Restored, error) {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Restored{}, nil
+	} else if err != nil {
+		return Restored{}, err
+	}
+	defer f.Close()
+
+	size, err := verify(f)
+	if err != nil {
+		return Restored{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Restored{}, err
+	}
+	r := bufio.NewReader(io.LimitReader(f, size))
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(magic)]) != magic {
+		return Restored{}, fmt.Errorf("%w: missing header", ErrCorrupt)
+	}
+	fields := header[len(magic):]
+	if fields[0] != formatVersion {
+		return Restored{}, fmt.Errorf("%w: unsupported format version %d", ErrCorrupt, fields[0])
+	}
+	if fields[1] != c.keys.ID() || fields[2] != c.values.ID() {
+		return Restored{}, fmt.Errorf("%w: codec IDs %d/%d, want %d/%d", ErrCodecMismatch,
+			fields[1], fields[2], c.keys.ID(), c.values.ID())
+	}
+
+	result := Restored{SavedAt: time.Unix(0, int64(binary.BigEndian.Uint64(fields[3:])))}
+	result.Downtime = max(c.clock.Now().Sub(result.SavedAt), 0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		kind, err := r.ReadByte()
+		if err != nil {
+			return result, fmt.Errorf("%w: missing end record", ErrCorrupt)
+		}
+		if kind == recordEnd {
+			count, err := binary.ReadUvarint(r)
+			if err != nil || count != uint64(result.Entries+result.Expired+result.Dropped) {
+				return result, fmt.Errorf("%w: entry count does not match", ErrCorrupt)
+			}
+			return result, nil
+		}
+		if kind != recordEntry {
+			return result, fmt.Errorf("%w: unknown record type %d", ErrCorrupt, kind)
+		}
+
+		keyData, err := readBytes(r, size)
+		if err != nil {
+			return result, err
+		}
+		valueData, err := readBytes(r, size)
+		if err != nil {
+			return result, err
+		}
+		left, err := binary.ReadUvarint(r)
+		if err != nil {
+			return result, fmt.Errorf("%w: truncated entry", ErrCorrupt)
+		}
+
+		ttl := time.Duration(left)
+		if ttl > 0 {
+			if ttl -= result.Downtime; ttl <= 0 {
+				result.Expired++
+				continue
+			}
+		}
+		var key K
+		if err := c.keys.Unmarshal(keyData, &key); err != nil {
+			return result, fmt.Errorf("%w: decoding key: %v", ErrCorrupt, err)
+		}
+		var value V
+		if err := c.values.Unmarshal(valueData, &value); err != nil {
+			return result, fmt.Errorf("%w: decoding value of %v: %v", ErrCorrupt, key, err)
+		}
+		if err := c.SetWithContext(ctx, key, value, ttl); err != nil {
+			// Entries about to expire can be below the cache's minimum TTL
+			if errors.Is(err, cacheerrors.ErrTTLTooShort) {
+				result.Dropped++
+				continue
+			}
+			return result, fmt.Errorf("snapshot: restoring %v: %w", key, err)
+		}
+		result.Entries++
+	}
+}
+
+// verify checks the checksum at the end of the snapshot, streaming the
+// file once, and returns the size of the data it covers
+func verify(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size() - 4
+	if size < int64(headerSize) {
+		return 0, fmt.Errorf("%w: file is %d bytes", ErrCorrupt, info.Size())
+	}
+
+	sum := crc32.New(castagnoli)
+	if _, err := io.CopyN(sum, f, size); err != nil {
+		return 0, err
+	}
+	var want [4]byte
+	if _, err := io.ReadFull(f, want[:]); err != nil {
+		return 0, err
+	}
+	if sum.Sum32() != binary.BigEndian.Uint32(want[:]) {
+		return 0, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	return size, nil
+}
+
+// readBytes reads a uvarint length and that many bytes, refusing lengths
+// longer than the file
+func readBytes(r *bufio.Reader, limit int64) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(limit) {
+		return nil, fmt.Errorf("%w: truncated entry", ErrCorrupt)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("%w: truncated entry", ErrCorrupt)
+	}
+	return b, nil
+}
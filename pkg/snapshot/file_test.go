@@ -0,0 +1,183 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/gencache"
+)
+
+// saved fills a cache on clk with a, b (no TTL) and c, saves it to a new
+// file and returns the path
+func saved(t *testing.T, clk *clock.Fake) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	c := newCache(t, clk, path)
+	for key, ttl := range map[string]time.Duration{"a": 10 * time.Minute, "b": 0, "c": time.Hour} {
+		if err := c.Set(key, "value of "+key, ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := c.Save(context.Background()); err != nil || n != 3 {
+		t.Fatalf("Save = %d, %v; want 3 entries", n, err)
+	}
+	return path
+}
+
+func TestSaveRestoreRoundTrip(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	path := saved(t, clk)
+
+	clk.Advance(3 * time.Minute)
+	c := newCache(t, clk, path)
+	restored, err := c.Restore(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Entries != 3 || restored.Expired != 0 || restored.Dropped != 0 {
+		t.Errorf("restored = %+v, want 3 entries", restored)
+	}
+	if !restored.SavedAt.Equal(clock.Epoch) || restored.Downtime != 3*time.Minute {
+		t.Errorf("saved at %v with downtime %v, want %v and 3m", restored.SavedAt, restored.Downtime, clock.Epoch)
+	}
+
+	// The downtime is taken off every TTL
+	for key, want := range map[string]time.Duration{"a": 7 * time.Minute, "b": 0, "c": 57 * time.Minute} {
+		value, err := c.Get(key)
+		if err != nil || value != "value of "+key {
+			t.Errorf("Get %s = %q, %v", key, value, err)
+		}
+		if ttl, ok := c.TTL(key); !ok || ttl != want {
+			t.Errorf("TTL %s = %v, %t; want %v", key, ttl, ok, want)
+		}
+	}
+
+	// An entry that expired while the process was down is skipped
+	clk.Advance(10 * time.Minute)
+	restored, err = newCache(t, clk, path).Restore(context.Background())
+	if err != nil || restored.Entries != 2 || restored.Expired != 1 {
+		t.Errorf("restore after 13m = %+v, %v; want 2 entries and 1 expired", restored, err)
+	}
+}
+
+func TestRestoreMissingFile(t *testing.T) {
+	c := newCache(t, clock.NewFake(clock.Epoch), filepath.Join(t.TempDir(), "none.snap"))
+	if restored, err := c.Restore(context.Background()); err != nil || restored != (Restored{}) {
+		t.Errorf("Restore = %+v, %v; want nothing restored and no error", restored, err)
+	}
+}
+
+func TestRestoreCorrupt(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	path := saved(t, clk)
+	good, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flip := func(i int) []byte {
+		b := append([]byte(nil), good...)
+		b[i] ^= 1
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"flipped magic", flip(0)},
+		{"flipped entry byte", flip(headerSize + 3)},
+		{"flipped end record", flip(len(good) - 6)},
+		{"flipped checksum", flip(len(good) - 1)},
+		{"truncated", good[:len(good)-10]},
+		{"header only", good[:headerSize]},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			c := newCache(t, clk, path)
+			restored, err := c.Restore(context.Background())
+			if !errors.Is(err, ErrCorrupt) {
+				t.Errorf("err = %v, want ErrCorrupt", err)
+			}
+			if restored.Entries != 0 || c.Stats().Size.Load() != 0 {
+				t.Errorf("restored %d entries from a damaged snapshot", restored.Entries)
+			}
+		})
+	}
+}
+
+func TestRestoreCodecMismatch(t *testing.T) {
+	clk := clock.NewFake(clock.Epoch)
+	path := saved(t, clk)
+
+	c := New(gencache.New[string, string](), path, codec.JSON[string](), codec.Gob[string](), WithClock(clk))
+	t.Cleanup(func() { c.Close() })
+	if _, err := c.Restore(context.Background()); !errors.Is(err, ErrCodecMismatch) {
+		t.Errorf("err = %v, want ErrCodecMismatch", err)
+	}
+	if n := c.Stats().Size.Load(); n != 0 {
+		t.Errorf("cache holds %d entries after a codec mismatch", n)
+	}
+}
+
+// failingCodec encodes with JSON, failing once it has encoded after values
+type failingCodec struct {
+	codec.Codec[string]
+	after int
+}
+
+var errEncode = errors.New("encode failed")
+
+func (f *failingCodec) Marshal(v string) ([]byte, error) {
+	if f.after == 0 {
+		return nil, errEncode
+	}
+	f.after--
+	return f.Codec.Marshal(v)
+}
+
+func TestFailedSaveKeepsPreviousSnapshot(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	path := saved(t, clk)
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The next save dies after writing two of its entries
+	values := &failingCodec{Codec: codec.JSON[string](), after: 2}
+	c := New(gencache.New[string, string](), path, codec.JSON[string](), values, WithClock(clk))
+	for _, key := range []string{"x", "y", "z"} {
+		if err := c.Set(key, "new", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Save(ctx); !errors.Is(err, errEncode) {
+		t.Fatalf("Save err = %v, want the encoding error", err)
+	}
+	if m := c.Metrics(); m.Failures != 1 || m.Snapshots != 0 {
+		t.Errorf("metrics = %+v, want one failure", m)
+	}
+	c.Cache.Close()
+
+	if current, err := os.ReadFile(path); err != nil || string(current) != string(previous) {
+		t.Errorf("snapshot file changed by a failed save (err %v)", err)
+	}
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("%d files left in the snapshot directory, want only the snapshot", len(files))
+	}
+	restored, err := newCache(t, clk, path).Restore(ctx)
+	if err != nil || restored.Entries != 3 {
+		t.Errorf("Restore = %+v, %v; want the 3 entries of the previous snapshot", restored, err)
+	}
+}
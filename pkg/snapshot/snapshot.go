@@ -0,0 +1,291 @@
+// Package snapshot saves the live entries of an in-memory gencache cache to
+// a single file and restores them on startup, so a restart does not begin
+// with a cold cache.
+//
+// gencache does not expose the expiry of its entries, so Cache records the
+// TTL of every Set and writes each entry with the TTL it has left. Restore
+// subtracts the time that passed since the snapshot was taken, so entries
+// expire when they would have if the process had kept running; entries that
+// expired in the meantime are skipped.
+//
+// Snapshots are streamed entry by entry to a temporary file that is renamed
+// over the previous snapshot, so a crash while saving leaves the previous
+// snapshot intact. With WithInterval a snapshot is also taken periodically
+// in the background, and Close takes a final one.
+//
+// Example usage:
+//
+//	sessions := snapshot.New(gencache.New[string, Session](), "/var/cache/sessions.snap",
+//		codec.JSON[string](), codec.JSON[Session](), snapshot.WithInterval(time.Minute))
+//	defer sessions.Close()
+//	if _, err := sessions.Restore(ctx); err != nil {
+//		log.Printf("starting with an empty cache: %v", err)
+//	}
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/gencache"
+)
+
+// Errors returned by Restore
+var (
+	ErrCorrupt       = errors.New("snapshot: corrupt snapshot file")
+	ErrCodecMismatch = errors.New("snapshot: snapshot was written with a different codec")
+)
+
+// Restored describes what Restore loaded
+type Restored struct {
+	Entries  int           // entries stored in the cache
+	Expired  int           // entries that expired while the process was down
+	Dropped  int           // live entries with less than the cache's minimum TTL left
+	SavedAt  time.Time     // when the snapshot was taken; zero if there was none
+	Downtime time.Duration // time since the snapshot, subtracted from every TTL
+}
+
+// Metrics counts snapshots taken by Save, the background loop and Close
+type Metrics struct {
+	Snapshots    int64     // snapshots written
+	Failures     int64     // snapshots that failed; the previous file was kept
+	Entries      int64     // entries in the last snapshot
+	Bytes        int64     // size of the last snapshot
+	LastSnapshot time.Time // when the last snapshot was written
+}
+
+// metrics holds the live counters behind Metrics
+type metrics struct {
+	snapshots, failures, entries, bytes atomic.Int64
+	last                                atomic.Int64 // unix nanoseconds
+}
+
+// Cache is a gencache cache that can be saved to and restored from a
+// snapshot file. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	gencache.Cache[K, V]
+	path   string
+	keys   codec.Codec[K]
+	values codec.Codec[V]
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	expires map[K]time.Time // zero for entries stored with the cache's default TTL
+
+	saveMu  sync.Mutex // one snapshot at a time
+	metrics metrics
+
+	background sync.WaitGroup
+	stop       chan struct{}
+	closeOnce  sync.Once
+	closeErr   error
+}
+
+// Option configures a Cache
+type Option func(*options)
+
+type options struct {
+	interval time.Duration
+	clock    clock.Clock
+}
+
+// WithInterval takes a snapshot in the background every interval. Save
+// reads every entry through the cache, and gencache has no read that leaves
+// its eviction policy or its Stats alone, so each snapshot counts as one hit
+// on every entry. Keep the interval long next to the time entries take to
+// age out, or snapshots will keep cold entries alive under LRU and LFU and
+// inflate the cache's hit ratio.
+func WithInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+// WithClock sets the clock used for TTLs and the snapshot interval
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New wraps cache so it can be saved to path. Keys and values are encoded
+// with the given codecs. Entries must be stored through the returned Cache
+// to be included in snapshots.
+func New[K comparable, V any](cache gencache.Cache[K, V], path string, keys codec.Codec[K], values codec.Codec[V], opts ...Option) *Cache[K, V] {
+	o := options{clock: clock.Real()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Cache[K, V]{
+		Cache:   cache,
+		path:    path,
+		keys:    keys,
+		values:  values,
+		clock:   o.clock,
+		expires: make(map[K]time.Time),
+		stop:    make(chan struct{}),
+	}
+	if o.interval > 0 {
+		// Create the ticker before returning so ticks from a fake clock
+		// advanced right after New are not missed
+		ticker := c.clock.NewTicker(o.interval)
+		c.background.Add(1)
+		go c.saveEvery(ticker)
+	}
+	return c
+}
+
+// saveEvery takes a snapshot on every tick until Close
+func (c *Cache[K, V]) saveEvery(ticker clock.Ticker) {
+	defer c.background.Done()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
+			// Failures are counted in Metrics; the previous snapshot is kept
+			_, _ = c.Save(context.Background())
+		}
+	}
+}
+
+// Set stores value with ttl
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) error {
+	return c.SetWithContext(context.Background(), key, value, ttl)
+}
+
+// SetWithContext stores value with ttl and records when it expires
+func (c *Cache[K, V]) SetWithContext(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.Cache.SetWithContext(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.clock.Now().Add(ttl)
+	}
+	c.expires[key] = expires
+	return nil
+}
+
+// Delete removes key
+func (c *Cache[K, V]) Delete(key K) error {
+	return c.DeleteWithContext(context.Background(), key)
+}
+
+// DeleteWithContext removes key
+func (c *Cache[K, V]) DeleteWithContext(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.expires, key)
+	return c.Cache.DeleteWithContext(ctx, key)
+}
+
+// Clear removes every entry
+func (c *Cache[K, V]) Clear() error {
+	return c.ClearWithContext(context.Background())
+}
+
+// ClearWithContext removes every entry
+func (c *Cache[K, V]) ClearWithContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.expires)
+	return c.Cache.ClearWithContext(ctx)
+}
+
+// TTL returns the time key has left before it expires, and false if key
+// was not stored through c or has expired. Entries stored with the cache's
+// default TTL report zero.
+func (c *Cache[K, V]) TTL(key K) (time.Duration, bool) {
+	expires, ok := c.expiry(key)
+	if !ok {
+		return 0, false
+	}
+	return entryTTL(expires, c.clock.Now())
+}
+
+// Metrics returns a snapshot of the counters
+func (c *Cache[K, V]) Metrics() Metrics {
+	m := Metrics{
+		Snapshots: c.metrics.snapshots.Load(),
+		Failures:  c.metrics.failures.Load(),
+		Entries:   c.metrics.entries.Load(),
+		Bytes:     c.metrics.bytes.Load(),
+	}
+	if last := c.metrics.last.Load(); last != 0 {
+		m.LastSnapshot = time.Unix(0, last)
+	}
+	return m
+}
+
+// Close stops background snapshots, takes a final snapshot and closes the
+// underlying cache
+func (c *Cache[K, V]) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		c.background.Wait()
+		_, err := c.Save(context.Background())
+		c.closeErr = errors.Join(err, c.Cache.Close())
+	})
+	return c.closeErr
+}
+
+// entryTTL returns the TTL left for an entry expiring at expires, and
+// whether the entry is still live. Entries stored with the cache's default
+// TTL have no recorded expiry and report a TTL of zero.
+func entryTTL(expires, now time.Time) (time.Duration, bool) {
+	if expires.IsZero() {
+		return 0, true
+	}
+	ttl := expires.Sub(now)
+	return ttl, ttl > 0
+}
+
+// liveEntry is a key listed for a snapshot with its recorded expiry
+type liveEntry[K comparable] struct {
+	key     K
+	expires time.Time
+}
+
+// liveEntries lists the entries that have not expired, forgetting the
+// expired ones
+func (c *Cache[K, V]) liveEntries(now time.Time) []liveEntry[K] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]liveEntry[K], 0, len(c.expires))
+	for key, expires := range c.expires {
+		if _, live := entryTTL(expires, now); !live {
+			delete(c.expires, key)
+			continue
+		}
+		entries = append(entries, liveEntry[K]{key, expires})
+	}
+	return entries
+}
+
+// expiry returns the recorded expiry of key, and false if it was deleted
+func (c *Cache[K, V]) expiry(key K) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires, ok := c.expires[key]
+	return expires, ok
+}
+
+// forget drops an entry the cache evicted, unless it was stored again
+// since it was listed
+func (c *Cache[K, V]) forget(e liveEntry[K]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if expires, ok := c.expires[e.key]; ok && expires.Equal(e.expires) {
+		delete(c.expires, e.key)
+	}
+}
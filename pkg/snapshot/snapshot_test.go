@@ -0,0 +1,79 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/examples/pkg/codec"
+	"github.com/gozephyr/gencache"
+)
+
+// newCache returns a string cache saved to path and following clk
+func newCache(t *testing.T, clk clock.Clock, path string) *Cache[string, string] {
+	t.Helper()
+	c := New(gencache.New[string, string](), path, codec.JSON[string](), codec.JSON[string](), WithClock(clk))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestSaveCountsReads(t *testing.T) {
+	ctx := context.Background()
+	c := newCache(t, clock.Real(), filepath.Join(t.TempDir(), "cache.snap"))
+	for i := 0; i < 10; i++ {
+		if err := c.Set(fmt.Sprintf("k%d", i), "v", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An entry the cache dropped behind the wrapper's back
+	if err := c.Cache.Delete("k0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.Save(ctx); err != nil || n != 9 {
+		t.Fatalf("Save = %d, %v, want 9 entries", n, err)
+	}
+	// Snapshot reads are ordinary hits, and the dropped entry a miss
+	stats := c.Stats()
+	if hits, misses := stats.Hits.Load(), stats.Misses.Load(); hits != 10 || misses != 1 {
+		t.Errorf("after Save: %d hits and %d misses, want 10 and 1", hits, misses)
+	}
+	if n, err := c.Save(ctx); err != nil || n != 9 {
+		t.Fatalf("second Save = %d, %v", n, err)
+	}
+	if hits := stats.Hits.Load(); hits != 19 {
+		t.Errorf("after a second Save: %d hits, want 19", hits)
+	}
+}
+
+func TestRestoreBelowMinTTL(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	path := filepath.Join(t.TempDir(), "cache.snap")
+
+	c := newCache(t, clk, path)
+	for key, ttl := range map[string]time.Duration{"short": 10 * time.Second, "long": time.Hour, "gone": 5 * time.Second} {
+		if err := c.Set(key, "v", ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// short has half a second left: live, but below gencache's minimum TTL
+	clk.Advance(9500 * time.Millisecond)
+	restored, err := newCache(t, clk, path).Restore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Entries != 1 || restored.Expired != 1 || restored.Dropped != 1 {
+		t.Errorf("restored = %+v, want one entry, one expired and one dropped", restored)
+	}
+}
@@ -0,0 +1,385 @@
+// Package tiered composes two gencache caches into a two-level cache: a
+// small, fast L1 (typically in memory) in front of a larger, slower L2
+// (typically backed by store.NewFileStore).
+//
+// Reads try L1, then L2; an L2 hit is promoted into L1. Writes go to both
+// tiers, either synchronously (write-through) or to L1 first with L2 updated
+// by a background flush (write-back). Deletes remove the key from both tiers
+// and from any pending write-back, so a deleted key never reappears.
+package tiered
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+	cacheerrors "github.com/gozephyr/gencache/errors"
+)
+
+// Mode selects how writes reach L2
+type Mode int
+
+const (
+	// WriteThrough writes L2 before L1; Set returns once both are updated
+	WriteThrough Mode = iota
+	// WriteBack writes L1 and queues the write; L2 is updated by Flush
+	WriteBack
+)
+
+// String returns the name of the mode
+func (m Mode) String() string {
+	switch m {
+	case WriteThrough:
+		return "write-through"
+	case WriteBack:
+		return "write-back"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrClosed is returned by operations on a closed cache
+var ErrClosed = errors.New("tiered cache is closed")
+
+// Metrics counts hits per tier and the outcome of write-back flushes
+type Metrics struct {
+	L1Hits       int64 // reads served by L1
+	L2Hits       int64 // reads served by L2 and promoted to L1
+	Misses       int64 // reads found in neither tier
+	Flushed      int64 // queued writes stored in L2
+	FlushErrors  int64 // queued writes L2 refused; they stay queued
+	FlushExpired int64 // queued writes whose TTL ran out before they were flushed
+	PendingFlush int64 // writes queued and not yet stored in L2
+}
+
+// metrics holds the live counters behind Metrics
+type metrics struct {
+	l1Hits, l2Hits, misses, flushed, flushErrors, flushExpired atomic.Int64
+}
+
+// pending is a write queued for L2
+type pending[V any] struct {
+	value   V
+	expires time.Time // zero for the cache's default TTL
+	seq     uint64    // distinguishes rewrites of the key made during a flush
+}
+
+// ttl returns the TTL p has left at now, and false once it has expired
+func (p pending[V]) ttl(now time.Time) (time.Duration, bool) {
+	if p.expires.IsZero() {
+		return 0, true
+	}
+	left := p.expires.Sub(now)
+	return left, left > 0
+}
+
+// Cache is a two-level cache. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	l1, l2       gencache.Cache[K, V]
+	mode         Mode
+	promotionTTL time.Duration
+	clock        clock.Clock
+
+	mu      sync.Mutex
+	dirty   map[K]pending[V] // write-back queue, the newest value of each key
+	seq     uint64
+	closed  bool
+	metrics metrics
+
+	// L2 access is serialized here: store.FileStore rejects writes that
+	// overlap other operations instead of waiting for them
+	l2mu sync.RWMutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Option configures a Cache
+type Option func(*options)
+
+type options struct {
+	mode          Mode
+	flushInterval time.Duration
+	promotionTTL  time.Duration
+	clock         clock.Clock
+}
+
+// WithWriteBack queues writes to L2 and flushes them every interval. A zero
+// interval disables the background flush; call Flush manually.
+func WithWriteBack(interval time.Duration) Option {
+	return func(o *options) {
+		o.mode = WriteBack
+		o.flushInterval = interval
+	}
+}
+
+// WithPromotionTTL sets the TTL of values promoted from L2 to L1 (defaults
+// to 1m). L2 does not report how long its values have left, so a promoted
+// value may outlive its L2 copy by up to this long.
+func WithPromotionTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.promotionTTL = d
+	}
+}
+
+// WithClock sets the clock driving the background flush and measuring the
+// TTL queued writes have left
+func WithClock(clk clock.Clock) Option {
+	return func(o *options) {
+		o.clock = clk
+	}
+}
+
+// New creates a cache with l1 in front of l2. Writes are write-through
+// unless WithWriteBack is given. Close stops the background flush and
+// flushes pending writes; it does not close l1 or l2.
+func New[K comparable, V any](l1, l2 gencache.Cache[K, V], opts ...Option) *Cache[K, V] {
+	o := options{
+		mode:         WriteThrough,
+		promotionTTL: time.Minute,
+		clock:        clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Cache[K, V]{
+		l1:           l1,
+		l2:           l2,
+		mode:         o.mode,
+		promotionTTL: o.promotionTTL,
+		clock:        o.clock,
+		dirty:        make(map[K]pending[V]),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if o.mode == WriteBack && o.flushInterval > 0 {
+		ticker := c.clock.NewTicker(o.flushInterval)
+		go c.flushLoop(ticker)
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+// Mode returns how writes reach L2
+func (c *Cache[K, V]) Mode() Mode {
+	return c.mode
+}
+
+// Get returns the value of key from L1, or from L2 promoting it into L1.
+// A write still queued for L2 is found even if L1 has evicted it.
+func (c *Cache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	value, l1Err := c.l1.GetWithContext(ctx, key)
+	if l1Err == nil {
+		c.metrics.l1Hits.Add(1)
+		return value, nil
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		var zero V
+		return zero, ErrClosed
+	}
+	if value, ok, err := c.queued(key, l1Err); ok || err != nil {
+		c.mu.Unlock()
+		return value, err
+	}
+	c.mu.Unlock()
+
+	// Hold the L2 lock until the value is promoted, so a write-through Set
+	// or a Delete of the key cannot slip in between and be undone
+	c.l2mu.RLock()
+	defer c.l2mu.RUnlock()
+	value, err := c.l2.GetWithContext(ctx, key)
+	if err != nil {
+		c.metrics.misses.Add(1)
+		return value, err
+	}
+
+	// A write-back Set does not take the L2 lock; it queues the key under
+	// c.mu, so check the queue again before promoting
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if queued, ok, err := c.queued(key, l1Err); ok || err != nil {
+		return queued, err
+	}
+	c.metrics.l2Hits.Add(1)
+	// A failed promotion only costs another L2 read next time
+	_ = c.l1.SetWithContext(ctx, key, value, c.promotionTTL)
+	return value, nil
+}
+
+// queued returns the value of a write of key still queued for L2, and
+// whether there is one. A queued write that has expired hides whatever older
+// value L2 holds, so it is reported as missErr. It must be called with c.mu
+// held.
+func (c *Cache[K, V]) queued(key K, missErr error) (V, bool, error) {
+	p, ok := c.dirty[key]
+	if !ok {
+		var zero V
+		return zero, false, nil
+	}
+	if _, live := p.ttl(c.clock.Now()); !live {
+		c.metrics.misses.Add(1)
+		var zero V
+		return zero, false, missErr
+	}
+	c.metrics.l1Hits.Add(1)
+	return p.value, true, nil
+}
+
+// Set stores value in both tiers. In write-through mode L2 is written first
+// and an L2 error leaves L1 untouched. In write-back mode only L1 is written
+// now and the write is queued for L2.
+func (c *Cache[K, V]) Set(ctx context.Context, key K, value V, ttl time.Duration) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.mode == WriteBack {
+		defer c.mu.Unlock()
+		c.seq++
+		p := pending[V]{value: value, seq: c.seq}
+		if ttl > 0 {
+			p.expires = c.clock.Now().Add(ttl)
+		}
+		c.dirty[key] = p
+		return c.l1.SetWithContext(ctx, key, value, ttl)
+	}
+	c.mu.Unlock()
+
+	// Writing both tiers under the L2 lock keeps concurrent writers of a key
+	// from leaving different values in L1 and L2
+	c.l2mu.Lock()
+	defer c.l2mu.Unlock()
+	if err := c.l2.SetWithContext(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return c.l1.SetWithContext(ctx, key, value, ttl)
+}
+
+// Delete removes key from both tiers and drops any queued write of it
+func (c *Cache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	delete(c.dirty, key)
+	c.mu.Unlock()
+
+	// Holding the L2 lock keeps an in-flight flush from writing the key back
+	c.l2mu.Lock()
+	defer c.l2mu.Unlock()
+	l1Err := c.l1.DeleteWithContext(ctx, key)
+	l2Err := c.l2.DeleteWithContext(ctx, key)
+	return errors.Join(l1Err, l2Err)
+}
+
+// Flush stores every queued write in L2 with the TTL it has left. Writes
+// that expired while queued are dropped and the key removed from L2, so an
+// older value does not resurface. Writes L2 refuses stay queued for the next
+// flush and their errors are returned joined.
+func (c *Cache[K, V]) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := make(map[K]pending[V], len(c.dirty))
+	for key, p := range c.dirty {
+		batch[key] = p
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	c.l2mu.Lock()
+	defer c.l2mu.Unlock()
+	for key, p := range batch {
+		// Skip keys deleted since the batch was taken
+		c.mu.Lock()
+		current, ok := c.dirty[key]
+		c.mu.Unlock()
+		if !ok || current.seq != p.seq {
+			continue
+		}
+
+		// TTLs under the cache's minimum count as expired
+		ttl, live := p.ttl(c.clock.Now())
+		var err error
+		if live {
+			err = c.l2.SetWithContext(ctx, key, p.value, ttl)
+		}
+		switch {
+		case !live || errors.Is(err, cacheerrors.ErrTTLTooShort):
+			c.metrics.flushExpired.Add(1)
+			// L2 may hold an older value of the key; it may also hold none
+			_ = c.l2.DeleteWithContext(ctx, key)
+		case err != nil:
+			c.metrics.flushErrors.Add(1)
+			errs = append(errs, err)
+			continue
+		default:
+			c.metrics.flushed.Add(1)
+		}
+
+		// Only dequeue the key if it was not rewritten during the flush
+		c.mu.Lock()
+		if current, ok := c.dirty[key]; ok && current.seq == p.seq {
+			delete(c.dirty, key)
+		}
+		c.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Metrics returns a snapshot of the counters
+func (c *Cache[K, V]) Metrics() Metrics {
+	c.mu.Lock()
+	pendingFlush := int64(len(c.dirty))
+	c.mu.Unlock()
+
+	return Metrics{
+		L1Hits:       c.metrics.l1Hits.Load(),
+		L2Hits:       c.metrics.l2Hits.Load(),
+		Misses:       c.metrics.misses.Load(),
+		Flushed:      c.metrics.flushed.Load(),
+		FlushErrors:  c.metrics.flushErrors.Load(),
+		FlushExpired: c.metrics.flushExpired.Load(),
+		PendingFlush: pendingFlush,
+	}
+}
+
+// Close stops the background flush and flushes the remaining queued writes
+func (c *Cache[K, V]) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.stop)
+	<-c.done
+	return c.Flush(ctx)
+}
+
+// flushLoop flushes queued writes on every tick until Close
+func (c *Cache[K, V]) flushLoop(ticker clock.Ticker) {
+	defer close(c.done)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
+			// Failed writes stay queued and are retried on the next tick
+			_ = c.Flush(context.Background())
+		}
+	}
+}
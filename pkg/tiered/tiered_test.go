@@ -0,0 +1,156 @@
+package tiered
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/clock"
+	"github.com/gozephyr/gencache"
+)
+
+// slowPromotion is an L1 whose first Set waits for a signal, or at most a
+// while, to widen the window between the L2 read and the promotion in Get
+type slowPromotion struct {
+	gencache.Cache[string, string]
+	entered chan struct{}
+	resume  chan struct{}
+}
+
+func (c *slowPromotion) SetWithContext(ctx context.Context, key, value string, ttl time.Duration) error {
+	select {
+	case c.entered <- struct{}{}:
+		select {
+		case <-c.resume:
+		case <-time.After(100 * time.Millisecond):
+		}
+	default:
+	}
+	return c.Cache.SetWithContext(ctx, key, value, ttl)
+}
+
+func TestDeleteDuringPromotion(t *testing.T) {
+	ctx := context.Background()
+	l1 := &slowPromotion{
+		Cache:   gencache.New[string, string](),
+		entered: make(chan struct{}),
+		resume:  make(chan struct{}),
+	}
+	defer l1.Close()
+	l2 := gencache.New[string, string]()
+	defer l2.Close()
+	if err := l2.Set("k", "v", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	c := New[string, string](l1, l2)
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "k")
+		got <- err
+	}()
+	<-l1.entered
+
+	// The delete must wait for the promotion rather than run before it
+	deleted := make(chan error, 1)
+	go func() { deleted <- c.Delete(ctx, "k") }()
+	select {
+	case <-deleted:
+		t.Error("Delete finished while Get was promoting the key")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(l1.resume)
+
+	if err := <-got; err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	<-deleted
+	if value, err := l1.Get("k"); err == nil {
+		t.Errorf("L1 holds %q after the delete; the promotion undid it", value)
+	}
+}
+
+// newWriteBack returns a write-back cache without a background flush, with
+// both tiers and the cache following clk
+func newWriteBack(t *testing.T, clk *clock.Fake) (*Cache[string, string], gencache.Cache[string, string]) {
+	t.Helper()
+	l1 := clock.WrapCache(clk, gencache.New[string, string]())
+	l2 := clock.WrapCache(clk, gencache.New[string, string]())
+	t.Cleanup(func() {
+		l1.Close()
+		l2.Close()
+	})
+	return New(l1, l2, WithWriteBack(0), WithClock(clk)), l2
+}
+
+func TestFlushKeepsDeadline(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	c, l2 := newWriteBack(t, clk)
+
+	if err := c.Set(ctx, "k", "v", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(6 * time.Second)
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l2.Get("k"); err != nil {
+		t.Fatalf("k missing from L2 after the flush: %v", err)
+	}
+
+	// The write expires 10s after the Set, not 10s after the flush
+	clk.Advance(5 * time.Second)
+	if value, err := l2.Get("k"); err == nil {
+		t.Errorf("L2 still serves %q past the TTL of the write", value)
+	}
+}
+
+func TestQueuedWriteExpires(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	c, l2 := newWriteBack(t, clk)
+
+	// An older value is already in L2
+	if err := l2.Set("k", "old", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "k", "new", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(6 * time.Second)
+
+	if value, err := c.Get(ctx, "k"); err == nil {
+		t.Errorf("Get returned %q for a write that expired in the queue", value)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := l2.Get("k"); err == nil {
+		t.Errorf("L2 serves %q after the newer write expired", value)
+	}
+	if m := c.Metrics(); m.FlushExpired != 1 || m.Flushed != 0 || m.PendingFlush != 0 {
+		t.Errorf("metrics = %+v, want one expired write and nothing flushed or queued", m)
+	}
+}
+
+func TestQueuedWriteBelowMinTTL(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(clock.Epoch)
+	c, l2 := newWriteBack(t, clk)
+
+	if err := c.Set(ctx, "k", "v", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// Half a second left is below gencache's minimum TTL
+	clk.Advance(4500 * time.Millisecond)
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if _, err := l2.Get("k"); err == nil {
+		t.Error("a write with less than the minimum TTL left reached L2")
+	}
+	if m := c.Metrics(); m.FlushExpired != 1 || m.PendingFlush != 0 {
+		t.Errorf("metrics = %+v, want the write dropped as expired", m)
+	}
+}
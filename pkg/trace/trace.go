@@ -0,0 +1,192 @@
+// Package trace reads cache access traces: streams of keys replayed against
+// a cache to measure how a policy behaves on a real workload.
+//
+// Supported formats:
+//
+//   - text: one access per line. The first whitespace-separated field is
+//     the key; blank lines and lines starting with # are skipped.
+//   - arc: the block traces published with the ARC paper. Each line holds
+//     "start count ignored request", accessing blocks start to start+count-1.
+//   - lirs: the traces published with the LIRS paper, one block number per
+//     line. A line holding only * ends the trace.
+package trace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format identifies a trace file format
+type Format string
+
+// Supported formats
+const (
+	Text Format = "text"
+	ARC  Format = "arc"
+	LIRS Format = "lirs"
+)
+
+// Formats lists every supported format
+var Formats = []Format{Text, ARC, LIRS}
+
+// ErrUnknownFormat is returned for a format that is not supported
+var ErrUnknownFormat = errors.New("trace: unknown format")
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, name)
+}
+
+// Scanner reads the keys of a trace one access at a time
+//
+//	s := trace.NewScanner(r, trace.ARC)
+//	for s.Scan() {
+//		replay(s.Key())
+//	}
+//	if err := s.Err(); err != nil { ... }
+type Scanner struct {
+	lines  *bufio.Scanner
+	format Format
+	line   int
+	key    string
+	err    error
+	done   bool
+
+	// Remaining blocks of the current arc line
+	next, end int64
+}
+
+// NewScanner returns a Scanner reading a trace in format from r
+func NewScanner(r io.Reader, format Format) *Scanner {
+	return &Scanner{
+		lines:  bufio.NewScanner(r),
+		format: format,
+	}
+}
+
+// Scan advances to the next access. It returns false at the end of the
+// trace or on an error, which Err reports.
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	if s.next < s.end {
+		s.key = strconv.FormatInt(s.next, 10)
+		s.next++
+		return true
+	}
+
+	for s.lines.Scan() {
+		s.line++
+		line := strings.TrimSpace(s.lines.Text())
+		if line == "" {
+			continue
+		}
+
+		var ok bool
+		switch s.format {
+		case Text:
+			ok = s.scanText(line)
+		case ARC:
+			ok = s.scanARC(line)
+		case LIRS:
+			ok = s.scanLIRS(line)
+		default:
+			s.fail(fmt.Errorf("%w %q", ErrUnknownFormat, s.format))
+			return false
+		}
+		if s.done {
+			return false
+		}
+		if ok {
+			return true
+		}
+	}
+
+	s.done = true
+	if err := s.lines.Err(); err != nil {
+		s.err = fmt.Errorf("trace: reading line %d: %w", s.line+1, err)
+	}
+	return false
+}
+
+// Key returns the key of the current access
+func (s *Scanner) Key() string {
+	return s.key
+}
+
+// Err returns the first error encountered while reading the trace
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// scanText reads a line of the text format
+func (s *Scanner) scanText(line string) bool {
+	if strings.HasPrefix(line, "#") {
+		return false
+	}
+	s.key = strings.Fields(line)[0]
+	return true
+}
+
+// scanARC reads a line of the ARC format
+func (s *Scanner) scanARC(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		s.fail(fmt.Errorf("trace: line %d: want \"start count ...\", got %q", s.line, line))
+		return false
+	}
+	start, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		s.fail(fmt.Errorf("trace: line %d: bad start block: %w", s.line, err))
+		return false
+	}
+	count, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || count < 1 {
+		s.fail(fmt.Errorf("trace: line %d: bad block count %q", s.line, fields[1]))
+		return false
+	}
+
+	s.key = strconv.FormatInt(start, 10)
+	s.next, s.end = start+1, start+count
+	return true
+}
+
+// scanLIRS reads a line of the LIRS format
+func (s *Scanner) scanLIRS(line string) bool {
+	if line == "*" {
+		s.done = true
+		return false
+	}
+	if _, err := strconv.ParseInt(line, 10, 64); err != nil {
+		s.fail(fmt.Errorf("trace: line %d: bad block number %q", s.line, line))
+		return false
+	}
+	s.key = line
+	return true
+}
+
+// fail stops the scanner with err
+func (s *Scanner) fail(err error) {
+	s.err = err
+	s.done = true
+}
+
+// ReadAll reads every key of a trace in format from r
+func ReadAll(r io.Reader, format Format) ([]string, error) {
+	var keys []string
+	s := NewScanner(r, format)
+	for s.Scan() {
+		keys = append(keys, s.Key())
+	}
+	return keys, s.Err()
+}
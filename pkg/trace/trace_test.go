@@ -0,0 +1,138 @@
+package trace
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// scanAll reads every key of input in format
+func scanAll(t *testing.T, input string, format Format) ([]string, error) {
+	t.Helper()
+	return ReadAll(strings.NewReader(input), format)
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"text", "arc", "LIRS"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("ParseFormat(%q): %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("csv"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("ParseFormat(csv) = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestText(t *testing.T) {
+	keys, err := scanAll(t, "# comment\nkey1\n\n  key2 extra fields\nkey1\n", Text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"key1", "key2", "key1"}; !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestARC(t *testing.T) {
+	keys, err := scanAll(t, "50 3 0 0\n\n7 1 0 1\n", ARC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"50", "51", "52", "7"}; !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+}
+
+func TestARCErrors(t *testing.T) {
+	for _, input := range []string{
+		"50\n",
+		"x 3 0 0\n",
+		"50 0 0 0\n",
+		"50 -1 0 0\n",
+		"1 1 0 0\n50 y 0 0\n",
+	} {
+		if keys, err := scanAll(t, input, ARC); err == nil {
+			t.Errorf("%q: got keys %v and no error", input, keys)
+		} else if !strings.Contains(err.Error(), "line ") {
+			t.Errorf("%q: error %q does not name the line", input, err)
+		}
+	}
+}
+
+func TestLIRS(t *testing.T) {
+	keys, err := scanAll(t, "2468\n427\n\n2468\n*\n99\n", LIRS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2468", "427", "2468"}; !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v (the trace ends at *)", keys, want)
+	}
+
+	if _, err := scanAll(t, "12\nblock\n", LIRS); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("bad block number: err = %v, want an error on line 2", err)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := scanAll(t, "key\n", Format("csv")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v, want ErrUnknownFormat", err)
+	}
+}
+
+// TestSampleTraces reads the sample traces in testdata. They are synthetic,
+// generated for these tests in the layout of the traces published with the
+// ARC and LIRS papers, and contain no data from those traces.
+func TestSampleTraces(t *testing.T) {
+	tests := []struct {
+		file         string
+		format       Format
+		accesses     int
+		first, last  string
+		distinctKeys int
+	}{
+		// 2000 lines whose block counts add up to 8881 accesses
+		{"testdata/sample.arc", ARC, 8881, "50", "75881", 4654},
+		// 5000 block numbers followed by the * end marker
+		{"testdata/sample.lirs", LIRS, 5000, "2468", "111", 1857},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			f, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			// Stream the file rather than collecting it, as cachesim does
+			s := NewScanner(f, tt.format)
+			n, first, last := 0, "", ""
+			distinct := make(map[string]bool)
+			for s.Scan() {
+				if n == 0 {
+					first = s.Key()
+				}
+				last = s.Key()
+				distinct[last] = true
+				n++
+			}
+			if err := s.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if n != tt.accesses {
+				t.Errorf("%d accesses, want %d", n, tt.accesses)
+			}
+			if first != tt.first {
+				t.Errorf("first key %q, want %q", first, tt.first)
+			}
+			if last != tt.last {
+				t.Errorf("last key %q, want %q", last, tt.last)
+			}
+			if len(distinct) != tt.distinctKeys {
+				t.Errorf("%d distinct keys, want %d", len(distinct), tt.distinctKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package upstream provides a scriptable in-process HTTP server that examples
+// can use as a misbehaving downstream dependency.
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Response describes a single scripted reply
+type Response struct {
+	// Status is the HTTP status code to return (defaults to 200)
+	Status int
+
+	// Delay is how long the handler waits before replying
+	Delay time.Duration
+
+	// RetryAfter sets the Retry-After header when greater than zero
+	RetryAfter time.Duration
+
+	// Body is the response body
+	Body string
+}
+
+// OK returns a 200 response with the given body
+func OK(body string) Response {
+	return Response{Status: http.StatusOK, Body: body}
+}
+
+// Fail returns a response with the given status code
+func Fail(status int) Response {
+	return Response{Status: status, Body: http.StatusText(status)}
+}
+
+// Hang returns a 200 response that is only written after delay
+func Hang(delay time.Duration) Response {
+	return Response{Status: http.StatusOK, Delay: delay, Body: "too late"}
+}
+
+// Unavailable returns a 503 response carrying a Retry-After header
+func Unavailable(retryAfter time.Duration) Response {
+	return Response{
+		Status:     http.StatusServiceUnavailable,
+		RetryAfter: retryAfter,
+		Body:       http.StatusText(http.StatusServiceUnavailable),
+	}
+}
+
+// route holds the script for a single path
+type route struct {
+	responses []Response
+	hits      int
+}
+
+// next returns the response for the current hit. Once the script is
+// exhausted the last response is repeated.
+func (r *route) next() Response {
+	idx := r.hits
+	if idx >= len(r.responses) {
+		idx = len(r.responses) - 1
+	}
+	r.hits++
+	return r.responses[idx]
+}
+
+// Server is a fake upstream with per-path scripted responses
+type Server struct {
+	mu     sync.Mutex
+	routes map[string]*route
+	server *httptest.Server
+}
+
+// New starts a new fake upstream listening on a local port
+func New() *Server {
+	s := &Server{
+		routes: make(map[string]*route),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Script sets the responses played back for path, in order. The last
+// response is repeated for every request after the script runs out.
+func (s *Server) Script(path string, responses ...Response) {
+	if len(responses) == 0 {
+		responses = []Response{Fail(http.StatusNotFound)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes[path] = &route{responses: responses}
+}
+
+// URL returns the absolute URL for path on this server
+func (s *Server) URL(path string) string {
+	return s.server.URL + path
+}
+
+// Hits returns the number of requests received for path
+func (s *Server) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.routes[path]; ok {
+		return r.hits
+	}
+	return 0
+}
+
+// Close shuts down the server, aborting any hanging handlers
+func (s *Server) Close() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+// handle serves the next scripted response for the request path
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	rt, ok := s.routes[r.URL.Path]
+	var resp Response
+	if ok {
+		resp = rt.next()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if resp.Delay > 0 {
+		timer := time.NewTimer(resp.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			// The client gave up; nobody is listening for the reply
+			return
+		}
+	}
+
+	if resp.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(resp.RetryAfter.Seconds())))
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	fmt.Fprint(w, resp.Body)
+}
@@ -0,0 +1,105 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// get requests path and returns the status, Retry-After header and body
+func get(t *testing.T, s *Server, path string) (int, string, string) {
+	t.Helper()
+	resp, err := http.Get(s.URL(path))
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: reading body: %v", path, err)
+	}
+	return resp.StatusCode, resp.Header.Get("Retry-After"), string(body)
+}
+
+func TestScriptPlaysInOrderThenRepeatsLast(t *testing.T) {
+	s := New()
+	defer s.Close()
+	s.Script("/flaky", Unavailable(5*time.Second), Fail(http.StatusInternalServerError), OK("recovered"))
+
+	want := []struct {
+		status     int
+		retryAfter string
+		body       string
+	}{
+		{http.StatusServiceUnavailable, "5", "Service Unavailable"},
+		{http.StatusInternalServerError, "", "Internal Server Error"},
+		{http.StatusOK, "", "recovered"},
+		{http.StatusOK, "", "recovered"},
+	}
+	for i, w := range want {
+		status, retryAfter, body := get(t, s, "/flaky")
+		if status != w.status || retryAfter != w.retryAfter || body != w.body {
+			t.Errorf("request %d: %d, Retry-After %q, %q; want %d, %q, %q",
+				i+1, status, retryAfter, body, w.status, w.retryAfter, w.body)
+		}
+	}
+	if hits := s.Hits("/flaky"); hits != len(want) {
+		t.Errorf("Hits = %d, want %d", hits, len(want))
+	}
+}
+
+func TestUnscriptedPaths(t *testing.T) {
+	s := New()
+	defer s.Close()
+	s.Script("/empty")
+
+	for _, path := range []string{"/missing", "/empty"} {
+		if status, _, _ := get(t, s, path); status != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, status)
+		}
+	}
+	if hits := s.Hits("/missing"); hits != 0 {
+		t.Errorf("Hits of an unscripted path = %d", hits)
+	}
+}
+
+func TestHangWaitsForDelay(t *testing.T) {
+	s := New()
+	defer s.Close()
+	s.Script("/slow", Hang(50*time.Millisecond))
+
+	start := time.Now()
+	status, _, body := get(t, s, "/slow")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("reply after %v, want at least the 50ms delay", elapsed)
+	}
+	if status != http.StatusOK || body != "too late" {
+		t.Errorf("got %d %q", status, body)
+	}
+}
+
+func TestHangStopsWhenClientGivesUp(t *testing.T) {
+	s := New()
+	defer s.Close()
+	s.Script("/timeout", Hang(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL("/timeout"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := http.DefaultClient.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the client deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("request took %v", elapsed)
+	}
+	if hits := s.Hits("/timeout"); hits != 1 {
+		t.Errorf("Hits = %d, want 1", hits)
+	}
+}
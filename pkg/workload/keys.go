@@ -0,0 +1,143 @@
+package workload
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Keys chooses the key of each request. Implementations keep state between
+// calls, so a Keys value must only be used by one Generator.
+type Keys interface {
+	// Next returns the index of the next key, drawing randomness from rng
+	Next(rng *rand.Rand) uint64
+
+	// String describes the distribution, for benchmark and report labels
+	String() string
+}
+
+// uniform picks every key with the same probability
+type uniform struct {
+	n uint64
+}
+
+// Uniform returns keys drawn uniformly from n keys
+func Uniform(n uint64) Keys {
+	return &uniform{n: max(n, 1)}
+}
+
+func (u *uniform) Next(rng *rand.Rand) uint64 {
+	return uint64(rng.Int63n(int64(u.n)))
+}
+
+func (u *uniform) String() string {
+	return fmt.Sprintf("uniform(%d)", u.n)
+}
+
+// MinZipfExponent is the smallest exponent Zipfian uses. rand.Zipf is only
+// defined for exponents above 1.
+const MinZipfExponent = 1.01
+
+// zipfian picks low key indexes far more often than high ones
+type zipfian struct {
+	n    uint64
+	s    float64
+	rng  *rand.Rand // source zipf draws from
+	zipf *rand.Zipf
+}
+
+// Zipfian returns keys drawn from n keys with a Zipf distribution of exponent
+// s. Key 0 is the most popular, key 1 the next and so on. Exponents below
+// MinZipfExponent, and NaN, are raised to it.
+func Zipfian(n uint64, s float64) Keys {
+	if !(s >= MinZipfExponent) {
+		s = MinZipfExponent
+	}
+	return &zipfian{n: max(n, 1), s: s}
+}
+
+func (z *zipfian) Next(rng *rand.Rand) uint64 {
+	// rand.Zipf holds on to its source, so build a new one when rng changes
+	if z.zipf == nil || z.rng != rng {
+		z.rng = rng
+		z.zipf = rand.NewZipf(rng, z.s, 1, z.n-1)
+	}
+	return z.zipf.Uint64()
+}
+
+func (z *zipfian) String() string {
+	return fmt.Sprintf("zipfian(%d, s=%.2f)", z.n, z.s)
+}
+
+// hotspot sends a fixed share of requests to a small set of hot keys
+type hotspot struct {
+	n          uint64
+	hot        uint64
+	hotPercent int
+}
+
+// Hotspot returns keys where hotPercent percent of requests go to the first
+// hot keys and the rest are spread uniformly over the other n-hot keys
+func Hotspot(n, hot uint64, hotPercent int) Keys {
+	n = max(n, 2)
+	return &hotspot{n: n, hot: min(max(hot, 1), n-1), hotPercent: hotPercent}
+}
+
+func (h *hotspot) Next(rng *rand.Rand) uint64 {
+	if rng.Intn(100) < h.hotPercent {
+		return uint64(rng.Int63n(int64(h.hot)))
+	}
+	return h.hot + uint64(rng.Int63n(int64(h.n-h.hot)))
+}
+
+func (h *hotspot) String() string {
+	return fmt.Sprintf("hotspot(%d, %d hot, %d%%)", h.n, h.hot, h.hotPercent)
+}
+
+// sequential walks through the keys in order, wrapping around
+type sequential struct {
+	n    uint64
+	next uint64
+}
+
+// Sequential returns keys 0 to n-1 in order, repeatedly, like a table scan
+func Sequential(n uint64) Keys {
+	return &sequential{n: max(n, 1)}
+}
+
+func (s *sequential) Next(*rand.Rand) uint64 {
+	key := s.next
+	s.next = (s.next + 1) % s.n
+	return key
+}
+
+func (s *sequential) String() string {
+	return fmt.Sprintf("sequential(%d)", s.n)
+}
+
+// shifting draws uniformly from a working set that moves over time
+type shifting struct {
+	workingSet uint64
+	shiftEvery int
+	shiftBy    uint64
+	requests   int
+	offset     uint64
+}
+
+// Shifting returns keys drawn uniformly from a working set of workingSet
+// keys. Every shiftEvery requests the working set moves by shiftBy keys, so
+// old keys go cold and new ones become hot.
+func Shifting(workingSet uint64, shiftEvery int, shiftBy uint64) Keys {
+	return &shifting{workingSet: max(workingSet, 1), shiftEvery: max(shiftEvery, 1), shiftBy: shiftBy}
+}
+
+func (s *shifting) Next(rng *rand.Rand) uint64 {
+	if s.requests > 0 && s.requests%s.shiftEvery == 0 {
+		s.offset += s.shiftBy
+	}
+	s.requests++
+	return s.offset + uint64(rng.Int63n(int64(s.workingSet)))
+}
+
+func (s *shifting) String() string {
+	return fmt.Sprintf("shifting(%d, +%d every %d)", s.workingSet, s.shiftBy, s.shiftEvery)
+}
@@ -0,0 +1,130 @@
+package workload
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestZipfianExponent(t *testing.T) {
+	for _, s := range []float64{-1, 0, 0.5, 1, math.NaN()} {
+		keys := Zipfian(100, s)
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 1000; i++ {
+			if key := keys.Next(rng); key >= 100 {
+				t.Fatalf("s=%v: key %d out of range", s, key)
+			}
+		}
+		if got := keys.(*zipfian).s; got != MinZipfExponent {
+			t.Errorf("Zipfian(100, %v) uses s=%v, want %v", s, got, MinZipfExponent)
+		}
+	}
+}
+
+func TestZipfianFollowsRNG(t *testing.T) {
+	draw := func(keys Keys, rng *rand.Rand) []uint64 {
+		seq := make([]uint64, 20)
+		for i := range seq {
+			seq[i] = keys.Next(rng)
+		}
+		return seq
+	}
+
+	keys := Zipfian(1000, 1.1)
+	draw(keys, rand.New(rand.NewSource(1)))
+	got := draw(keys, rand.New(rand.NewSource(2)))
+	want := draw(Zipfian(1000, 1.1), rand.New(rand.NewSource(2)))
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("draws after switching rng = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	a := New(Zipfian(1000, 1.1), WithSeed(7)).Take(100)
+	b := New(Zipfian(1000, 1.1), WithSeed(7)).Take(100)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("request %d: %v and %v from the same seed", i, a[i], b[i])
+		}
+	}
+}
+
+// counts draws n keys and counts how often each index came up
+func counts(keys Keys, n int) map[uint64]int {
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[uint64]int)
+	for i := 0; i < n; i++ {
+		seen[keys.Next(rng)]++
+	}
+	return seen
+}
+
+func TestUniformCoversKeys(t *testing.T) {
+	const n, draws = 100, 100_000
+	seen := counts(Uniform(n), draws)
+	if len(seen) != n {
+		t.Fatalf("drew %d distinct keys, want all %d", len(seen), n)
+	}
+	for key, count := range seen {
+		if key >= n {
+			t.Fatalf("key %d out of range", key)
+		}
+		// Each key expects 1000 draws; 20% either way is far outside noise
+		if count < 800 || count > 1200 {
+			t.Errorf("key %d drawn %d times, want about %d", key, count, draws/n)
+		}
+	}
+}
+
+func TestZipfianSkew(t *testing.T) {
+	seen := counts(Zipfian(1000, 1.1), 100_000)
+	if seen[0] <= seen[1] || seen[1] <= seen[10] || seen[10] <= seen[100] {
+		t.Errorf("draws of keys 0, 1, 10, 100 = %d, %d, %d, %d, want decreasing",
+			seen[0], seen[1], seen[10], seen[100])
+	}
+}
+
+func TestHotspotShare(t *testing.T) {
+	const draws = 100_000
+	seen := counts(Hotspot(1000, 10, 90), draws)
+	hot := 0
+	for key, count := range seen {
+		if key >= 1000 {
+			t.Fatalf("key %d out of range", key)
+		}
+		if key < 10 {
+			hot += count
+		}
+	}
+	if share := float64(hot) / draws * 100; share < 89 || share > 91 {
+		t.Errorf("hot keys got %.1f%% of requests, want 90%%", share)
+	}
+	if len(seen) < 900 {
+		t.Errorf("only %d distinct keys drawn; cold keys should all appear", len(seen))
+	}
+}
+
+func TestSequentialWraps(t *testing.T) {
+	keys := Sequential(3)
+	var got []uint64
+	for i := 0; i < 7; i++ {
+		got = append(got, keys.Next(nil))
+	}
+	if want := []uint64{0, 1, 2, 0, 1, 2, 0}; !slices.Equal(got, want) {
+		t.Errorf("Sequential(3) = %v, want %v", got, want)
+	}
+}
+
+func TestShiftingMovesWorkingSet(t *testing.T) {
+	keys := Shifting(10, 100, 5)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		offset := uint64(i/100) * 5
+		if key := keys.Next(rng); key < offset || key >= offset+10 {
+			t.Fatalf("request %d drew key %d, want one in [%d, %d)", i, key, offset, offset+10)
+		}
+	}
+}
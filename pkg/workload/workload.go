@@ -0,0 +1,143 @@
+// Package workload generates synthetic cache workloads: streams of reads,
+// writes and deletes whose keys follow a chosen distribution.
+//
+//	gen := workload.New(workload.Zipfian(10_000, 1.1), workload.WithMix(workload.ReadHeavy))
+//	for _, req := range gen.Take(100_000) {
+//		switch req.Op { ... }
+//	}
+//
+// Generators are deterministic for a given seed, so runs are comparable.
+package workload
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// Op is the kind of a request
+type Op int
+
+// Request kinds
+const (
+	Read Op = iota
+	Write
+	Delete
+)
+
+// String returns the name of the operation
+func (o Op) String() string {
+	switch o {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Delete:
+		return "delete"
+	default:
+		return "Op(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// Request is one operation of a workload
+type Request struct {
+	Op  Op
+	Key string
+}
+
+// Mix sets the relative weights of reads, writes and deletes
+type Mix struct {
+	Read   int
+	Write  int
+	Delete int
+}
+
+// Common mixes
+var (
+	ReadOnly   = Mix{Read: 1}
+	ReadHeavy  = Mix{Read: 90, Write: 9, Delete: 1}
+	Balanced   = Mix{Read: 50, Write: 45, Delete: 5}
+	WriteHeavy = Mix{Read: 10, Write: 85, Delete: 5}
+)
+
+// pick chooses an operation according to the weights of the mix
+func (m Mix) pick(rng *rand.Rand) Op {
+	total := m.Read + m.Write + m.Delete
+	if total <= 0 {
+		return Read
+	}
+	switch n := rng.Intn(total); {
+	case n < m.Read:
+		return Read
+	case n < m.Read+m.Write:
+		return Write
+	default:
+		return Delete
+	}
+}
+
+// Generator produces the requests of a workload
+type Generator struct {
+	keys   Keys
+	mix    Mix
+	prefix string
+	rng    *rand.Rand
+}
+
+// Option configures a Generator
+type Option func(*Generator)
+
+// WithMix sets the read/write/delete mix (defaults to ReadHeavy)
+func WithMix(mix Mix) Option {
+	return func(g *Generator) {
+		g.mix = mix
+	}
+}
+
+// WithSeed seeds the generator (defaults to 1)
+func WithSeed(seed int64) Option {
+	return func(g *Generator) {
+		g.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithKeyPrefix sets the string put before each key index (defaults to "key")
+func WithKeyPrefix(prefix string) Option {
+	return func(g *Generator) {
+		g.prefix = prefix
+	}
+}
+
+// New creates a generator drawing keys from keys
+func New(keys Keys, opts ...Option) *Generator {
+	g := &Generator{
+		keys:   keys,
+		mix:    ReadHeavy,
+		prefix: "key",
+		rng:    rand.New(rand.NewSource(1)),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Next returns the next request
+func (g *Generator) Next() Request {
+	op := g.mix.pick(g.rng)
+	key := g.prefix + strconv.FormatUint(g.keys.Next(g.rng), 10)
+	return Request{Op: op, Key: key}
+}
+
+// Take returns the next n requests
+func (g *Generator) Take(n int) []Request {
+	requests := make([]Request, n)
+	for i := range requests {
+		requests[i] = g.Next()
+	}
+	return requests
+}
+
+// String describes the workload
+func (g *Generator) String() string {
+	return g.keys.String()
+}
@@ -0,0 +1,44 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMixRatios(t *testing.T) {
+	const draws = 100_000
+	for _, mix := range []Mix{ReadOnly, ReadHeavy, Balanced, WriteHeavy, {Write: 1, Delete: 1}} {
+		seen := make(map[Op]int)
+		for _, req := range New(Uniform(100), WithMix(mix)).Take(draws) {
+			seen[req.Op]++
+		}
+
+		total := mix.Read + mix.Write + mix.Delete
+		for op, weight := range map[Op]int{Read: mix.Read, Write: mix.Write, Delete: mix.Delete} {
+			want := float64(weight) / float64(total) * 100
+			got := float64(seen[op]) / draws * 100
+			if got < want-1 || got > want+1 {
+				t.Errorf("%+v: %.1f%% %ss, want %.1f%%", mix, got, op, want)
+			}
+		}
+	}
+}
+
+func TestEmptyMixReads(t *testing.T) {
+	for _, req := range New(Uniform(10), WithMix(Mix{})).Take(100) {
+		if req.Op != Read {
+			t.Fatalf("empty mix produced a %s", req.Op)
+		}
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	for _, req := range New(Sequential(5), WithKeyPrefix("user:")).Take(10) {
+		if !strings.HasPrefix(req.Key, "user:") {
+			t.Fatalf("key %q lacks the prefix", req.Key)
+		}
+	}
+	if req := New(Sequential(5)).Next(); req.Key != "key0" {
+		t.Errorf("first key = %q, want key0", req.Key)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math/bits"
+	"time"
+)
+
+// subBits sets the precision of the histogram: every power of two is split
+// into 1<<subBits buckets, so a percentile is within 1/(1<<subBits) = 12.5%
+// of the true latency
+const subBits = 3
+
+// exact is the number of small values that get a bucket of their own
+const exact = 1 << (subBits + 1)
+
+// buckets covers every int64 nanosecond value
+const buckets = (64-subBits-1)<<subBits + exact
+
+// histogram records latencies in log-linear buckets, using the same memory
+// however many requests a trace holds
+type histogram struct {
+	counts [buckets]int64
+	total  int64
+	max    time.Duration
+}
+
+// bucket returns the index of the bucket holding v
+func bucket(v uint64) int {
+	if v < exact {
+		return int(v)
+	}
+	e := bits.Len64(v) - 1
+	return (e-subBits)<<subBits + int(v>>(e-subBits))
+}
+
+// upperBound returns the largest value held by bucket i
+func upperBound(i int) uint64 {
+	if i < exact {
+		return uint64(i)
+	}
+	e := i>>subBits + subBits - 1
+	m := uint64(i&(1<<subBits-1) | 1<<subBits)
+	return (m+1)<<(e-subBits) - 1
+}
+
+// record adds one latency
+func (h *histogram) record(d time.Duration) {
+	h.counts[bucket(uint64(max(d, 0)))]++
+	h.total++
+	h.max = max(h.max, d)
+}
+
+// percentile returns the latency below which p percent of the recorded
+// latencies fall, rounded up to the bound of its bucket
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(float64(h.total-1) * p / 100)
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen > rank {
+			return min(time.Duration(upperBound(i)), h.max)
+		}
+	}
+	return h.max
+}
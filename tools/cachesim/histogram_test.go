@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuckets(t *testing.T) {
+	prev := -1
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 1000, 1 << 40, 1<<63 - 1, 1<<64 - 1} {
+		i := bucket(v)
+		if i < prev || i >= buckets {
+			t.Fatalf("bucket(%d) = %d, after %d of %d", v, i, prev, buckets)
+		}
+		prev = i
+		if upper := upperBound(i); upper < v {
+			t.Errorf("bucket(%d) = %d with upper bound %d", v, i, upper)
+		}
+		if i > 0 && upperBound(i-1) >= v {
+			t.Errorf("%d also fits bucket %d with upper bound %d", v, i-1, upperBound(i-1))
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var h histogram
+	if p := h.percentile(50); p != 0 {
+		t.Errorf("percentile of an empty histogram = %v", p)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	latencies := make([]time.Duration, 100000)
+	for i := range latencies {
+		latencies[i] = time.Duration(rng.ExpFloat64() * float64(50*time.Microsecond))
+		h.record(latencies[i])
+	}
+	slices.Sort(latencies)
+
+	for _, p := range []float64{0, 50, 90, 99, 99.9} {
+		want := latencies[int(float64(len(latencies)-1)*p/100)]
+		got := h.percentile(p)
+		if got < want || float64(got) > float64(want)*(1+1.0/(1<<subBits)) {
+			t.Errorf("p%v = %v, want within %d%% above %v", p, got, 100>>subBits, want)
+		}
+	}
+	if got, want := h.percentile(100), latencies[len(latencies)-1]; got != want {
+		t.Errorf("p100 = %v, want the maximum %v", got, want)
+	}
+}
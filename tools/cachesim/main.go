@@ -0,0 +1,266 @@
+// Command cachesim replays a cache access trace through gencache and reports
+// how each eviction policy performs at each capacity: hit ratio, evictions
+// and the latency of a lookup (plus the insert on a miss).
+//
+// The trace is streamed once, every access being replayed against all the
+// simulated caches in turn, and latencies go to fixed-size histograms, so
+// traces far larger than memory can be replayed.
+//
+// Usage:
+//
+//	go run ./tools/cachesim -trace tools/cachesim/testdata/zipf.trace
+//	go run ./tools/cachesim -trace OLTP.lis -format arc -capacity 1000,10000 -policy lru,arc,tinylfu
+//	go run ./tools/cachesim -trace sprite.trc -format lirs -output csv > results.csv
+//
+// The trace "-" reads from standard input.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gozephyr/examples/pkg/cachepolicy"
+	"github.com/gozephyr/examples/pkg/logger"
+	"github.com/gozephyr/examples/pkg/trace"
+	"github.com/gozephyr/gencache"
+	"github.com/gozephyr/gencache/policy"
+)
+
+// entryTTL is long enough that no entry expires during a replay
+const entryTTL = time.Hour
+
+// policies maps policy names to constructors
+var policies = map[string]func(capacity int) policy.Policy[string, string]{
+	"lru": func(capacity int) policy.Policy[string, string] {
+		return policy.NewLRU[string, string](policy.WithMaxSize(capacity))
+	},
+	"lfu": func(capacity int) policy.Policy[string, string] {
+		return policy.NewLFU[string, string](policy.WithMaxSize(capacity))
+	},
+	"fifo": func(capacity int) policy.Policy[string, string] {
+		return policy.NewFIFO[string, string](policy.WithMaxSize(capacity))
+	},
+	"arc": func(capacity int) policy.Policy[string, string] {
+		return cachepolicy.NewARC[string, string](policy.WithMaxSize(capacity))
+	},
+	"tinylfu": func(capacity int) policy.Policy[string, string] {
+		return cachepolicy.NewWTinyLFU[string, string](policy.WithMaxSize(capacity))
+	},
+}
+
+var (
+	tracePath  = flag.String("trace", "", "trace file to replay (- for standard input)")
+	format     = flag.String("format", "text", "trace format: text, arc or lirs")
+	policyList = flag.String("policy", "lru,lfu,fifo,arc,tinylfu", "comma-separated policies to compare")
+	capacities = flag.String("capacity", "100,1000", "comma-separated cache capacities in entries")
+	output     = flag.String("output", "table", "output format: table or csv")
+)
+
+// result holds the outcome of replaying a trace with one policy and capacity
+type result struct {
+	policy    string
+	capacity  int
+	requests  int
+	hits      int
+	evictions int64
+	latencies histogram
+}
+
+// hitRatio returns the percentage of requests that were hits
+func (r result) hitRatio() float64 {
+	if r.requests == 0 {
+		return 0
+	}
+	return float64(r.hits) / float64(r.requests) * 100
+}
+
+// percentile returns the latency below which p percent of requests completed
+func (r *result) percentile(p float64) time.Duration {
+	return r.latencies.percentile(p)
+}
+
+// simulation is a cache being replayed, together with its result so far
+type simulation struct {
+	cache  gencache.Cache[string, string]
+	result *result
+}
+
+func main() {
+	flag.Parse()
+	log := logger.New(logger.WithWriter(os.Stderr), logger.WithPrefix("cachesim"))
+
+	names, sizes, err := parseFlags()
+	if err != nil {
+		log.Error("%v", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	source := *tracePath
+	if source == "-" {
+		source = "standard input"
+	}
+	log.Info("Replaying %s with %d policies at %d capacities", source, len(names), len(sizes))
+	results, err := replay(names, sizes)
+	if err != nil {
+		log.Error("Error reading trace: %v", err)
+		os.Exit(1)
+	}
+	if len(results) > 0 {
+		log.Info("Replayed %d requests", results[0].requests)
+	}
+
+	if *output == "csv" {
+		err = writeCSV(os.Stdout, results)
+	} else {
+		err = writeTable(os.Stdout, results)
+	}
+	if err != nil {
+		log.Error("Error writing results: %v", err)
+		os.Exit(1)
+	}
+}
+
+// parseFlags validates the flags and returns the policies and capacities to simulate
+func parseFlags() ([]string, []int, error) {
+	if *tracePath == "" {
+		return nil, nil, fmt.Errorf("-trace is required")
+	}
+	if *output != "table" && *output != "csv" {
+		return nil, nil, fmt.Errorf("unknown -output %q", *output)
+	}
+
+	names := strings.Split(*policyList, ",")
+	for _, name := range names {
+		if _, ok := policies[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown policy %q", name)
+		}
+	}
+
+	var sizes []int
+	for _, s := range strings.Split(*capacities, ",") {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 1 {
+			return nil, nil, fmt.Errorf("invalid capacity %q", s)
+		}
+		sizes = append(sizes, size)
+	}
+	return names, sizes, nil
+}
+
+// openTrace opens the trace file, or standard input for "-"
+func openTrace() (*trace.Scanner, io.Closer, error) {
+	f, err := trace.ParseFormat(*format)
+	if err != nil {
+		return nil, nil, err
+	}
+	if *tracePath == "-" {
+		return trace.NewScanner(os.Stdin, f), io.NopCloser(nil), nil
+	}
+	file, err := os.Open(*tracePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return trace.NewScanner(file, f), file, nil
+}
+
+// replay streams the trace once through a cache per policy and capacity,
+// storing each key on a miss
+func replay(names []string, sizes []int) ([]*result, error) {
+	scanner, closer, err := openTrace()
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	var sims []simulation
+	var results []*result
+	for _, size := range sizes {
+		for _, name := range names {
+			cache := gencache.New[string, string](
+				gencache.WithMaxSize[string, string](size),
+				gencache.WithPolicy[string, string](policies[name](size)),
+			)
+			defer cache.Close()
+			r := &result{policy: name, capacity: size}
+			sims = append(sims, simulation{cache, r})
+			results = append(results, r)
+		}
+	}
+
+	for scanner.Scan() {
+		key := scanner.Key()
+		for _, sim := range sims {
+			sim.access(key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, sim := range sims {
+		sim.result.evictions = sim.cache.Stats().Evictions.Load()
+	}
+	return results, nil
+}
+
+// access looks key up, storing it on a miss, and records the outcome
+func (s simulation) access(key string) {
+	start := time.Now()
+	if _, err := s.cache.Get(key); err == nil {
+		s.result.hits++
+	} else {
+		_ = s.cache.Set(key, key, entryTTL)
+	}
+	s.result.latencies.record(time.Since(start))
+	s.result.requests++
+}
+
+// header lists the columns of the output
+var header = []string{"policy", "capacity", "requests", "hits", "hit_ratio", "evictions", "p50", "p90", "p99", "max"}
+
+// writeTable prints results as an aligned table
+func writeTable(w io.Writer, results []*result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f%%\t%d\t%v\t%v\t%v\t%v\t\n",
+			r.policy, r.capacity, r.requests, r.hits, r.hitRatio(), r.evictions,
+			r.percentile(50), r.percentile(90), r.percentile(99), r.percentile(100))
+	}
+	return tw.Flush()
+}
+
+// writeCSV prints results as CSV with latencies in nanoseconds
+func writeCSV(w io.Writer, results []*result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{
+			r.policy,
+			strconv.Itoa(r.capacity),
+			strconv.Itoa(r.requests),
+			strconv.Itoa(r.hits),
+			strconv.FormatFloat(r.hitRatio(), 'f', 4, 64),
+			strconv.FormatInt(r.evictions, 10),
+			strconv.FormatInt(r.percentile(50).Nanoseconds(), 10),
+			strconv.FormatInt(r.percentile(90).Nanoseconds(), 10),
+			strconv.FormatInt(r.percentile(99).Nanoseconds(), 10),
+			strconv.FormatInt(r.percentile(100).Nanoseconds(), 10),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// setFlags sets command-line flags for the duration of the test
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		old := flag.Lookup(name).Value.String()
+		if err := flag.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { flag.Set(name, old) })
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		wantErr bool
+	}{
+		{"defaults", map[string]string{"trace": "t"}, false},
+		{"missing trace", map[string]string{"trace": ""}, true},
+		{"unknown output", map[string]string{"trace": "t", "output": "json"}, true},
+		{"unknown policy", map[string]string{"trace": "t", "policy": "lru,mru"}, true},
+		{"zero capacity", map[string]string{"trace": "t", "capacity": "10,0"}, true},
+		{"bad capacity", map[string]string{"trace": "t", "capacity": "1k"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			names, sizes, err := parseFlags()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && (!slices.Equal(names, []string{"lru", "lfu", "fifo", "arc", "tinylfu"}) || !slices.Equal(sizes, []int{100, 1000})) {
+				t.Errorf("parseFlags() = %v, %v", names, sizes)
+			}
+		})
+	}
+}
+
+// simulate replays trace with the given flags and returns the CSV records
+// below the header
+func simulate(t *testing.T, flags map[string]string) [][]string {
+	t.Helper()
+	setFlags(t, flags)
+	names, sizes, err := parseFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := replay(names, sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v\n%s", err, buf.String())
+	}
+	if len(records) == 0 || !slices.Equal(records[0], header) {
+		t.Fatalf("CSV header = %v, want %v", records, header)
+	}
+	return records[1:]
+}
+
+func TestReplayShortTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short.trace")
+	// With room for two keys, LRU hits the second and third a only
+	if err := os.WriteFile(path, []byte("# comment\na\nb\na\nc\na\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records := simulate(t, map[string]string{"trace": path, "policy": "lru", "capacity": "2,10"})
+
+	want := [][]string{
+		{"lru", "2", "6", "2", "33.3333", "2"},
+		{"lru", "10", "6", "3", "50.0000", "0"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d rows, want %d: %v", len(records), len(want), records)
+	}
+	for i, w := range want {
+		if got := records[i][:len(w)]; !slices.Equal(got, w) {
+			t.Errorf("row %d = %v, want %v", i, got, w)
+		}
+		for _, latency := range records[i][6:] {
+			if _, err := strconv.ParseInt(latency, 10, 64); err != nil {
+				t.Errorf("row %d: latency %q is not in nanoseconds", i, latency)
+			}
+		}
+	}
+}
+
+func TestReplaySampleTrace(t *testing.T) {
+	records := simulate(t, map[string]string{
+		"trace":    "testdata/zipf.trace",
+		"policy":   "lru,tinylfu",
+		"capacity": "100",
+	})
+	if len(records) != 2 {
+		t.Fatalf("got %d rows, want 2: %v", len(records), records)
+	}
+
+	ratio := make(map[string]float64)
+	for _, record := range records {
+		requests, _ := strconv.Atoi(record[2])
+		if requests != 21200 {
+			t.Errorf("%s replayed %d requests, want 21200", record[0], requests)
+		}
+		ratio[record[0]], _ = strconv.ParseFloat(record[4], 64)
+	}
+	// The trace interleaves scans with a skewed working set, which
+	// frequency-based admission handles better than recency
+	if ratio["tinylfu"] <= ratio["lru"] {
+		t.Errorf("tinylfu hit ratio %.2f%% not above lru %.2f%%", ratio["tinylfu"], ratio["lru"])
+	}
+}